@@ -16,6 +16,9 @@ var backupCmd = &cobra.Command{
 每次执行将在指定 --path 下生成 Backups/2006-01-02-150405 格式目录进行备份
 
 bdpan backup --local 本地文件夹 --path 网盘目录
+bdpan backup --local 本地文件夹 --path 网盘目录 --filter-from ~/.config/bdpan/backup.rules
+
+备份时会读取各级目录中的 .bdpanignore，语法与 .gitignore 相同
 	`,
 	Run: func(cmd *cobra.Command, args []string) {
 		backupReq.GlobalReq = *GetGlobalReq()
@@ -25,5 +28,9 @@ bdpan backup --local 本地文件夹 --path 网盘目录
 
 func init() {
 	backupCmd.Flags().StringVarP(&backupReq.Local, "local", "l", "", "本地文件夹")
+	backupCmd.Flags().StringVar(&backupReq.LimitRate, "limit-rate", "", "上传限速，如 512K、2M，0 表示不限速，覆盖配置 limit.upload")
+	backupCmd.Flags().IntVar(&backupReq.Concurrency, "concurrency", 0, "大文件同时上传的分片数，默认读取配置 upload.concurrency")
+	addFilterFlags(backupCmd, &backupReq.Filter)
+	addProgressFlag(backupCmd, &backupReq.Progress)
 	rootCmd.AddCommand(backupCmd)
 }
@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// cacheCmd represents the cache command
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "管理本地缓存",
+	Long: `查看和清理缓存目录中的预览文件，以及下载任务留下的 .bdpan-part 文件
+缓存目录总大小超过配置 cache.max_size 时按最近最少使用自动清理，.bdpan-part 不计入，只通过 bdpan cache prune 清理
+未结束的下载任务使用的 .bdpan-part 不会被清理`,
+	Example: `  bdpan cache stats				查看缓存大小
+  bdpan cache ls				按最近访问时间列出缓存
+  bdpan cache ls --kind part			只列出下载中的 .bdpan-part 文件
+  bdpan cache prune				清理到配置的大小上限
+  bdpan cache prune --max-size 200M		清理到 200M 以内
+  bdpan cache prune --all --dry-run		查看清理全部缓存时会删除的内容`,
+}
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+}
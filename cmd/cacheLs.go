@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/wxnacy/bdpan-cli/internal/dto"
+	"github.com/wxnacy/bdpan-cli/internal/handler"
+)
+
+func init() {
+	var req = dto.NewCacheListReq()
+	var cmd = &cobra.Command{
+		Use:                   "ls",
+		Short:                 "列出缓存",
+		Example:               `  bdpan cache ls --kind preview`,
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			req.GlobalReq = *GetGlobalReq()
+			return handler.GetCacheHandler().CmdList(req)
+		},
+	}
+
+	cmd.Flags().StringVar(&req.Kind, "kind", "", "只列出指定类型: preview、chunks、part、other")
+	cacheCmd.AddCommand(cmd)
+}
@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/wxnacy/bdpan-cli/internal/dto"
+	"github.com/wxnacy/bdpan-cli/internal/handler"
+)
+
+func init() {
+	var req = dto.NewCachePruneReq()
+	var cmd = &cobra.Command{
+		Use:                   "prune",
+		Short:                 "按最近最少使用清理缓存",
+		Example:               `  bdpan cache prune --max-size 500M`,
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			req.GlobalReq = *GetGlobalReq()
+			return handler.GetCacheHandler().CmdPrune(req)
+		},
+	}
+
+	cmd.Flags().StringVar(&req.MaxSize, "max-size", "", "清理到总大小不超过该值，如 500M、2G，默认使用配置 cache.max_size")
+	cmd.Flags().StringVar(&req.Kind, "kind", "", "只清理指定类型: preview、chunks、part、other")
+	cmd.Flags().BoolVar(&req.All, "all", false, "清理全部缓存，未结束的下载任务使用的 .bdpan-part 除外")
+	cmd.Flags().BoolVar(&req.DryRun, "dry-run", false, "只列出将被清理的缓存，不删除")
+	cacheCmd.AddCommand(cmd)
+}
@@ -0,0 +1,20 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/wxnacy/bdpan-cli/internal/handler"
+)
+
+func init() {
+	var cmd = &cobra.Command{
+		Use:                   "stats",
+		Short:                 "查看缓存统计",
+		Example:               `  bdpan cache stats`,
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return handler.GetCacheHandler().CmdStats(GetGlobalReq())
+		},
+	}
+
+	cacheCmd.AddCommand(cmd)
+}
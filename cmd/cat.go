@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/wxnacy/bdpan-cli/internal/dto"
+	"github.com/wxnacy/bdpan-cli/internal/handler"
+)
+
+var catReq = dto.NewCatReq()
+
+var catCmd = &cobra.Command{
+	Use:   "cat",
+	Short: "输出网盘文件内容",
+	Example: `  bdpan cat /apps/logs/app.log | grep ERROR		在网盘日志中查找
+  bdpan cat /apps/backup.tar.gz | tar -xz		不保存直接解压
+  bdpan cat /apps/video.mp4 --offset 1024 --length 4096	读取指定区间
+	`,
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		catReq.GlobalReq = *GetGlobalReq()
+		if len(args) > 0 {
+			catReq.Path = args[0]
+		}
+		handleCmdErr(handler.GetFileHandler().CmdCat(catReq))
+	},
+}
+
+func init() {
+	catCmd.Flags().Int64Var(&catReq.Offset, "offset", 0, "开始读取的位置（字节）")
+	catCmd.Flags().Int64Var(&catReq.Length, "length", -1, "读取的字节数，默认读取到文件末尾")
+	rootCmd.AddCommand(catCmd)
+}
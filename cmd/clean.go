@@ -4,11 +4,9 @@ Copyright 2023 NAME HERE EMAIL ADDRESS
 package cmd
 
 import (
-	"os"
-	"path/filepath"
-
 	"github.com/spf13/cobra"
-	"github.com/wxnacy/bdpan-cli/internal/config"
+	"github.com/wxnacy/bdpan-cli/internal/dto"
+	"github.com/wxnacy/bdpan-cli/internal/handler"
 )
 
 // cleanCmd represents the clean command
@@ -16,22 +14,15 @@ var cleanCmd = &cobra.Command{
 	Use:   "clean",
 	Short: "清理缓存数据",
 	// 功能需求:
-	// - 通过 config.GetCacheDir() 获取 cache 目录
 	// - 删除 cache 内所有内容，但是保留 cache 目录
+	// - 只清理缓存目录，下载目录中的 .bdpan-part 使用 bdpan cache prune 清理
 	Run: func(cmd *cobra.Command, args []string) {
-		dir := config.GetCacheDir()
-		entries, err := os.ReadDir(dir)
-		if err != nil {
-			handleCmdErr(err)
-			return
-		}
-		for _, e := range entries {
-			p := filepath.Join(dir, e.Name())
-			if err := os.RemoveAll(p); err != nil {
-				handleCmdErr(err)
-				return
-			}
-		}
+		req := dto.NewCachePruneReq()
+		req.GlobalReq = *GetGlobalReq()
+		req.All = true
+		req.CacheDirOnly = true
+		err := handler.GetCacheHandler().CmdPrune(req)
+		handleCmdErr(err)
 	},
 }
 
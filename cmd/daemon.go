@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/wxnacy/bdpan-cli/internal/dto"
+	"github.com/wxnacy/bdpan-cli/internal/handler"
+)
+
+var daemonReq = dto.NewDaemonReq()
+
+// daemonCmd represents the daemon command
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "后台执行任务队列",
+	Long: `持续从任务队列中领取通过 --queue 加入的任务并执行
+并发数默认读取配置 daemon.workers，Ctrl+C 退出时未完成的任务会放回队列`,
+	Example: `  bdpan daemon					前台运行
+  bdpan daemon -w 4				同时执行 4 个任务
+  nohup bdpan daemon > /dev/null 2>&1 &		退出终端后继续运行`,
+	DisableFlagsInUseLine: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		daemonReq.GlobalReq = *GetGlobalReq()
+		return handler.GetDaemonHandler().CmdDaemon(daemonReq)
+	},
+}
+
+func init() {
+	daemonCmd.Flags().IntVarP(&daemonReq.Workers, "workers", "w", 0, "同时执行的任务数，默认读取配置 daemon.workers")
+	daemonCmd.Flags().StringVar(&daemonReq.LimitRate, "limit-rate", "", "所有任务共享的下载限速，如 512K、2M，0 表示不限速，覆盖配置 limit.download")
+	rootCmd.AddCommand(daemonCmd)
+}
@@ -20,6 +20,14 @@ var downloadCmd = &cobra.Command{
 	Example: `  bdpan download /apps/video.mp4				下载文件
   bdpan download /apps/video.mp4 -d ~/Downloads			指定下载目录
   bdpan download /apps/video.mp4 -o ~/Downloads/1.mp4		指定下载地址
+  bdpan download /apps/video.mp4 --queue			加入队列，由 bdpan daemon 下载
+  bdpan download /apps/videos -r				递归下载文件夹及所有子文件夹
+  bdpan download /apps/photos -r --include '*.jpg' --max-age 7d	只下载最近 7 天的 jpg 文件
+  bdpan download /apps/videos -r --on-conflict newer		只更新网盘中有改动的文件
+  bdpan download /apps/photos -r --preserve-times		保留照片原来的修改时间
+  bdpan download -i paths.txt -d ~/Downloads			下载列表文件中的所有文件
+  grep '\.pdf$' paths.txt | bdpan download -i -		从标准输入读取下载列表
+  bdpan download /apps/videos -r --progress jsonl 2>progress.log	进度以 JSON 行写入文件，便于脚本解析
 	`,
 	DisableFlagsInUseLine: true,
 	Long:                  ``,
@@ -40,6 +48,14 @@ func init() {
 	downloadCmd.Flags().StringVarP(&downloadReq.OutputPath, "output-path", "o", "", "保存地址。覆盖已存在文件，优先级比 --output-dir 高")
 
 	downloadCmd.Flags().BoolVar(&downloadReq.IsSync, "sync", false, "是否同步进行")
-	downloadCmd.Flags().BoolVarP(&downloadReq.IsRecursion, "recursion", "r", false, "是否递归下载")
+	downloadCmd.Flags().BoolVar(&downloadReq.IsQueue, "queue", false, "只加入任务队列，由 bdpan daemon 在后台下载")
+	downloadCmd.Flags().BoolVarP(&downloadReq.IsRecursion, "recursion", "r", false, "递归下载文件夹中的所有子文件夹，并在本地重建目录结构")
+	downloadCmd.Flags().StringVar(&downloadReq.LimitRate, "limit-rate", "", "下载限速，如 512K、2M，0 表示不限速，覆盖配置 limit.download，不能与 --queue 同时使用")
+	downloadCmd.Flags().StringVar(&downloadReq.OnConflict, "on-conflict", "", "本地已存在同名文件时的处理策略：skip、overwrite、rename、newer（远程更新时覆盖）、size-differs（大小不同时覆盖），默认文件夹下载 skip，单文件下载 rename")
+	downloadCmd.Flags().StringVarP(&downloadReq.InputFile, "input", "i", "", "下载列表文件，- 表示从标准输入读取。每行一个网盘路径，可以用 Tab 分隔指定本地保存路径，# 开头的行忽略")
+	downloadCmd.Flags().StringVar(&downloadReq.PreserveTimes, "preserve-times", "", "将网盘中的修改时间设置到下载的文件和文件夹：none（不设置）、local（上传时本地文件的修改时间）、server（网盘中的修改时间），只指定 --preserve-times 时为 local")
+	downloadCmd.Flags().Lookup("preserve-times").NoOptDefVal = handler.PreserveTimesLocal
+	addFilterFlags(downloadCmd, &downloadReq.Filter)
+	addProgressFlag(downloadCmd, &downloadReq.Progress)
 	rootCmd.AddCommand(downloadCmd)
 }
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/wxnacy/bdpan-cli/internal/dto"
+	"github.com/wxnacy/bdpan-cli/internal/handler"
+)
+
+var headReq = dto.NewCatReq()
+
+var headCmd = &cobra.Command{
+	Use:   "head",
+	Short: "输出网盘文件开头的内容",
+	Example: `  bdpan head /apps/logs/app.log			输出前 10 行
+  bdpan head /apps/logs/app.log -n 100		输出前 100 行
+  bdpan head /apps/video.mp4 --bytes 1024 | xxd	输出前 1024 个字节
+	`,
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		headReq.GlobalReq = *GetGlobalReq()
+		if len(args) > 0 {
+			headReq.Path = args[0]
+		}
+		handleCmdErr(handler.GetFileHandler().CmdHead(headReq))
+	},
+}
+
+func init() {
+	headCmd.Flags().IntVarP(&headReq.Lines, "lines", "n", 10, "输出的行数")
+	headCmd.Flags().Int64Var(&headReq.Bytes, "bytes", 0, "输出的字节数，指定时忽略 --lines")
+	rootCmd.AddCommand(headCmd)
+}
@@ -4,9 +4,12 @@ import (
 	"path/filepath"
 	"time"
 
+	"github.com/wxnacy/bdpan-cli/internal/cache"
 	"github.com/wxnacy/bdpan-cli/internal/config"
 	"github.com/wxnacy/bdpan-cli/internal/handler"
+	"github.com/wxnacy/bdpan-cli/internal/httpclient"
 	"github.com/wxnacy/bdpan-cli/internal/logger"
+	"github.com/wxnacy/bdpan-cli/internal/ratelimit"
 	"github.com/wxnacy/go-tools"
 )
 
@@ -29,6 +32,15 @@ func initConfig() {
 	if errConfig != nil {
 		panic("init config error: " + errConfig.Error())
 	}
+	if err := ratelimit.Init(config.Get().Limit); err != nil {
+		panic("init config error: " + err.Error())
+	}
+	if err := httpclient.Init(config.Get().HTTP); err != nil {
+		panic("init config error: " + err.Error())
+	}
+	if err := cache.Init(config.Get().Cache); err != nil {
+		panic("init config error: " + err.Error())
+	}
 	tools.DirExistsOrCreate(config.GetCacheDir())
 	tools.DirExistsOrCreate(filepath.Dir(config.GetLogFile()))
 }
@@ -14,8 +14,10 @@ import (
 	"github.com/wxnacy/bdpan-cli/cmd/initial"
 	"github.com/wxnacy/bdpan-cli/internal/config"
 	"github.com/wxnacy/bdpan-cli/internal/dto"
+	"github.com/wxnacy/bdpan-cli/internal/filter"
 	"github.com/wxnacy/bdpan-cli/internal/handler"
 	"github.com/wxnacy/bdpan-cli/internal/logger"
+	"github.com/wxnacy/bdpan-cli/internal/progress"
 	"github.com/wxnacy/bdpan-cli/internal/terminal"
 	"github.com/wxnacy/go-bdpan"
 	"github.com/wxnacy/go-tools"
@@ -85,6 +87,24 @@ var rootCmd = &cobra.Command{
 	},
 }
 
+// addFilterFlags 注册文件过滤参数，download、upload、backup、sync exec 共用
+func addFilterFlags(cmd *cobra.Command, opts *filter.Options) {
+	cmd.Flags().StringArrayVar(&opts.Include, "include", nil, "只传输匹配的文件，gitignore 语法，如 '*.jpg'、'docs/**'，可多次指定")
+	cmd.Flags().StringArrayVar(&opts.Exclude, "exclude", nil, "排除匹配的文件和文件夹，如 node_modules、.DS_Store、'*.log'，可多次指定")
+	cmd.Flags().StringArrayVar(&opts.FilterFrom, "filter-from", nil, "从文件读取规则，每行一条，'+ ' 或 '!' 开头为包含，'- ' 开头或无前缀为排除")
+	cmd.Flags().StringVar(&opts.MinSize, "min-size", "", "只传输不小于该大小的文件，如 100K、1M")
+	cmd.Flags().StringVar(&opts.MaxSize, "max-size", "", "只传输不大于该大小的文件，如 500M、2G")
+	cmd.Flags().StringVar(&opts.MinAge, "min-age", "", "只传输修改时间早于该时长之前的文件，如 30m、12h、7d、2w")
+	cmd.Flags().StringVar(&opts.MaxAge, "max-age", "", "只传输修改时间在该时长之内的文件，如 30m、12h、7d、2w")
+	cmd.Flags().StringSliceVar(&opts.Categories, "category", nil, "只传输指定分类的文件：video、audio、image、doc、app、other、torrent，多个用逗号分隔")
+	cmd.Flags().BoolVar(&opts.ExcludeHidden, "exclude-hidden", false, "排除 . 开头的隐藏文件和文件夹")
+}
+
+// addProgressFlag 注册传输命令共用的进度输出参数
+func addProgressFlag(cmd *cobra.Command, mode *string) {
+	cmd.Flags().StringVar(mode, "progress", progress.ModeAuto, "进度输出方式：auto（终端中为 tui，否则为 plain）、tui（进度条）、plain（定时输出文字）、jsonl（每次状态变化向标准错误输出一行 JSON）、none（不输出）")
+}
+
 var ErrQuit = errors.New("quit bdpan")
 
 func handleCmdErr(err error) {
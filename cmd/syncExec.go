@@ -10,6 +10,9 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/wxnacy/bdpan"
+	"github.com/wxnacy/bdpan-cli/internal/dto"
+	"github.com/wxnacy/bdpan-cli/internal/filter"
+	"github.com/wxnacy/bdpan-cli/internal/handler"
 )
 
 var syncExecCommand = &SyncExecCommand{SyncCommand: syncCommand}
@@ -18,6 +21,7 @@ type SyncExecCommand struct {
 	*SyncCommand
 	isOnce bool
 	id     string
+	filter filter.Options
 }
 
 func (s SyncExecCommand) Run() error {
@@ -27,7 +31,7 @@ func (s SyncExecCommand) Run() error {
 			if s.id != "" && s.id != m.ID {
 				continue
 			}
-			err := m.Exec()
+			err := s.exec(m)
 			if err != nil {
 				return err
 			}
@@ -39,6 +43,26 @@ func (s SyncExecCommand) Run() error {
 	}
 }
 
+// exec 执行单个同步模型
+//
+// 备份模式通过 handler.UploadDir 上传，以便应用过滤参数和 .bdpanignore，
+// 未开启 HasHide 时排除隐藏文件，与 bdpan 原有行为一致
+func (s SyncExecCommand) exec(m *bdpan.SyncModel) error {
+	if !m.IsBackup() {
+		return m.Exec()
+	}
+	req := dto.NewUploadReq()
+	req.Filter = s.filter
+	req.Filter.ExcludeHidden = req.Filter.ExcludeHidden || !m.HasHide
+	if err := handler.GetFileHandler().UploadDir(req, m.Local, m.Remote); err != nil {
+		return err
+	}
+	m.LastSyncTime = time.Now()
+	models := bdpan.GetSyncModels()
+	models[m.ID] = m
+	return bdpan.SaveModels(models)
+}
+
 // syncExecCmd represents the syncExec command
 var syncExecCmd = &cobra.Command{
 	Use:   "exec",
@@ -56,5 +80,6 @@ var syncExecCmd = &cobra.Command{
 func init() {
 	syncExecCmd.Flags().BoolVarP(&syncExecCommand.isOnce, "once", "o", false, "是否执行单次")
 	syncExecCmd.Flags().StringVarP(&syncExecCommand.id, "id", "", "", "执行 id")
+	addFilterFlags(syncExecCmd, &syncExecCommand.filter)
 	syncCmd.AddCommand(syncExecCmd)
 }
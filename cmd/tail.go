@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/wxnacy/bdpan-cli/internal/dto"
+	"github.com/wxnacy/bdpan-cli/internal/handler"
+)
+
+var tailReq = dto.NewCatReq()
+
+var tailCmd = &cobra.Command{
+	Use:   "tail",
+	Short: "输出网盘文件末尾的内容",
+	Example: `  bdpan tail /apps/logs/app.log			输出最后 10 行
+  bdpan tail /apps/logs/app.log -n 100		输出最后 100 行
+  bdpan tail /apps/video.mp4 --bytes 1024 | xxd	输出最后 1024 个字节
+	`,
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		tailReq.GlobalReq = *GetGlobalReq()
+		if len(args) > 0 {
+			tailReq.Path = args[0]
+		}
+		handleCmdErr(handler.GetFileHandler().CmdTail(tailReq))
+	},
+}
+
+func init() {
+	tailCmd.Flags().IntVarP(&tailReq.Lines, "lines", "n", 10, "输出的行数")
+	tailCmd.Flags().Int64Var(&tailReq.Bytes, "bytes", 0, "输出的字节数，指定时忽略 --lines")
+	rootCmd.AddCommand(tailCmd)
+}
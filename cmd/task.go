@@ -0,0 +1,25 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// taskCmd represents the task command
+var taskCmd = &cobra.Command{
+	Use:   "task",
+	Short: "管理后台任务",
+	Long: `查看和管理下载等长时间运行的任务
+任务数据保存在本地 SQLite 中，可以在其他终端查看进度或取消任务`,
+	Example: `  bdpan task list				列出所有任务
+  bdpan task list -s running,failed		按状态列出任务
+  bdpan task status 1a2b3c4d			查看任务详情，支持 ID 前缀
+  bdpan task top				全屏查看运行中的任务
+  bdpan task cancel 1a2b3c4d			取消任务
+  bdpan task pause 1a2b3c4d			暂停任务
+  bdpan task resume 1a2b3c4d			恢复失败或已取消的任务
+  bdpan task purge				清理已结束的任务`,
+}
+
+func init() {
+	rootCmd.AddCommand(taskCmd)
+}
@@ -0,0 +1,25 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/wxnacy/bdpan-cli/internal/dto"
+	"github.com/wxnacy/bdpan-cli/internal/handler"
+)
+
+func init() {
+	var req = dto.NewTaskReq()
+	var cmd = &cobra.Command{
+		Use:                   "cancel [id]",
+		Short:                 "取消运行中的任务",
+		Example:               `  bdpan task cancel 1a2b3c4d`,
+		DisableFlagsInUseLine: true,
+		Args:                  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			req.GlobalReq = *GetGlobalReq()
+			req.ID = args[0]
+			return handler.GetTaskHandler().CmdCancel(req)
+		},
+	}
+
+	taskCmd.AddCommand(cmd)
+}
@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/wxnacy/bdpan-cli/internal/dto"
+	"github.com/wxnacy/bdpan-cli/internal/handler"
+)
+
+func init() {
+	var req = dto.NewTaskListReq()
+	var cmd = &cobra.Command{
+		Use:                   "list",
+		Short:                 "列出任务",
+		Example:               `  bdpan task list -s running`,
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			req.GlobalReq = *GetGlobalReq()
+			return handler.GetTaskHandler().CmdList(req)
+		},
+	}
+
+	cmd.Flags().StringVarP(&req.Status, "status", "s", "", "按状态过滤，多个用逗号分隔: queued,running,paused,completed,failed,canceled,stale")
+	taskCmd.AddCommand(cmd)
+}
@@ -0,0 +1,25 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/wxnacy/bdpan-cli/internal/dto"
+	"github.com/wxnacy/bdpan-cli/internal/handler"
+)
+
+func init() {
+	var req = dto.NewTaskReq()
+	var cmd = &cobra.Command{
+		Use:                   "pause [id]",
+		Short:                 "暂停任务，之后可通过 resume 继续",
+		Example:               `  bdpan task pause 1a2b3c4d`,
+		DisableFlagsInUseLine: true,
+		Args:                  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			req.GlobalReq = *GetGlobalReq()
+			req.ID = args[0]
+			return handler.GetTaskHandler().CmdPause(req)
+		},
+	}
+
+	taskCmd.AddCommand(cmd)
+}
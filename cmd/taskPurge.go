@@ -0,0 +1,25 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/wxnacy/bdpan-cli/internal/dto"
+	"github.com/wxnacy/bdpan-cli/internal/handler"
+)
+
+func init() {
+	var req = dto.NewTaskPurgeReq()
+	var cmd = &cobra.Command{
+		Use:                   "purge",
+		Short:                 "清理已结束的任务记录",
+		Example:               `  bdpan task purge -s completed -y`,
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			req.GlobalReq = *GetGlobalReq()
+			return handler.GetTaskHandler().CmdPurge(req)
+		},
+	}
+
+	cmd.Flags().StringVarP(&req.Status, "status", "s", "", "只清理指定状态，多个用逗号分隔: completed,failed,canceled")
+	cmd.Flags().BoolVarP(&req.Yes, "yes", "y", false, "是否回答yes")
+	taskCmd.AddCommand(cmd)
+}
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"errors"
+
+	"github.com/spf13/cobra"
+	"github.com/wxnacy/bdpan-cli/internal/dto"
+	"github.com/wxnacy/bdpan-cli/internal/handler"
+)
+
+func init() {
+	var req = dto.NewTaskResumeReq()
+	var cmd = &cobra.Command{
+		Use:   "resume [id]",
+		Short: "恢复失败、已取消或已暂停的下载任务",
+		Long: `根据任务记录的文件信息重新获取下载链接，并复用已下载的分片继续下载
+无需重新输入下载路径和保存目录`,
+		Example: `  bdpan task resume 1a2b3c4d		恢复指定任务
+  bdpan task resume --all-failed	恢复所有失败的任务`,
+		DisableFlagsInUseLine: true,
+		Args:                  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			req.GlobalReq = *GetGlobalReq()
+			if len(args) > 0 {
+				req.ID = args[0]
+			}
+			if req.ID == "" && !req.AllFailed {
+				return errors.New("请输入任务 ID 或使用 --all-failed")
+			}
+			return handler.GetTaskHandler().CmdResume(req)
+		},
+	}
+
+	cmd.Flags().BoolVar(&req.AllFailed, "all-failed", false, "恢复所有失败的任务")
+	cmd.Flags().BoolVar(&req.IsSync, "sync", false, "是否同步进行")
+	taskCmd.AddCommand(cmd)
+}
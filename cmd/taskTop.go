@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/wxnacy/bdpan-cli/internal/dto"
+	"github.com/wxnacy/bdpan-cli/internal/handler"
+)
+
+func init() {
+	var req = dto.NewTaskTopReq()
+	var cmd = &cobra.Command{
+		Use:   "top",
+		Short: "全屏展示所有运行中的任务",
+		Long: `实时展示各个进程中运行、排队和暂停的任务，包含进度、速度、剩余时间和速度趋势
+按 j/k 选择任务，c 取消，p 暂停，r 恢复（放回队列由 bdpan daemon 执行），q 退出`,
+		Example:               `  bdpan task top -n 2`,
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			req.GlobalReq = *GetGlobalReq()
+			return handler.GetTaskHandler().CmdTop(req)
+		},
+	}
+
+	cmd.Flags().IntVarP(&req.Interval, "interval", "n", req.Interval, "刷新间隔（秒）")
+	taskCmd.AddCommand(cmd)
+}
@@ -19,6 +19,10 @@ var uploadCmd = &cobra.Command{
 	Long: `
 上传文件
 bdpan upload --local 本地文件夹 --path 网盘目录
+bdpan upload --local 本地文件夹 --path 网盘目录 --exclude node_modules --exclude .DS_Store
+bdpan upload --local 本地文件 --path 网盘目录 --progress plain		在 CI 等非终端环境中输出文字进度
+
+上传文件夹时会读取各级目录中的 .bdpanignore，语法与 .gitignore 相同
 	`,
 	Run: func(cmd *cobra.Command, args []string) {
 		// err := uploadCommand.Run()
@@ -30,5 +34,9 @@ bdpan upload --local 本地文件夹 --path 网盘目录
 func init() {
 	uploadCmd.Flags().StringVarP(&uploadReq.Local, "local", "l", "", "本地文件")
 	uploadCmd.Flags().BoolVarP(&uploadReq.IsRewrite, "rewrite", "r", false, "是否覆盖同名文件，上传文件夹时不可指定，一直是 true")
+	uploadCmd.Flags().StringVar(&uploadReq.LimitRate, "limit-rate", "", "上传限速，如 512K、2M，0 表示不限速，覆盖配置 limit.upload")
+	uploadCmd.Flags().IntVar(&uploadReq.Concurrency, "concurrency", 0, "大文件同时上传的分片数，默认读取配置 upload.concurrency")
+	addFilterFlags(uploadCmd, &uploadReq.Filter)
+	addProgressFlag(uploadCmd, &uploadReq.Progress)
 	rootCmd.AddCommand(uploadCmd)
 }
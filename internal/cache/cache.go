@@ -0,0 +1,394 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wxnacy/bdpan-cli/internal/config"
+	"github.com/wxnacy/bdpan-cli/internal/downloader"
+	"github.com/wxnacy/bdpan-cli/internal/filter"
+	"github.com/wxnacy/bdpan-cli/internal/model"
+	"github.com/wxnacy/bdpan-cli/internal/taskstore"
+	"gorm.io/gorm"
+)
+
+// cache 管理 config.GetCacheDir() 下的缓存，索引保存在 SQLite 的 cache_entries 表中。
+//
+// 设计说明：
+// - 以缓存目录第一层的文件或文件夹为一个条目，如预览文件所在的 <md5>/ 文件夹
+// - 索引记录条目类型、大小和最近访问时间，缓存目录为实际数据，Scan 时与索引同步
+// - 总大小超过配置 cache.max_size 时按最近访问时间从旧到新清理
+// - 下载中的 <目标文件>.bdpan-part 在下载目录中，不写入索引，Scan 时从下载任务的目标路径查找，见 partEntries
+// - 属于未结束下载任务的 .bdpan-part 不清理
+
+// 缓存条目类型
+const (
+	KindPreview = "preview" // 预览时下载的小文件，见 bdtools.DownloadFileToLocal
+	KindChunks  = "chunks"  // 旧版本缓存目录模式留下的分片缓存，包含分片文件和 manifest.json，下载不再使用
+	KindPart    = "part"    // 下载中的 .bdpan-part 文件及其分片清单，Path 为 .bdpan-part 文件路径
+	KindOther   = "other"   // 其他文件
+)
+
+// activeWindow .bdpan-part 的分片清单在该时间内有更新时视为正在下载
+const activeWindow = 2 * time.Minute
+
+var (
+	mu      sync.Mutex
+	maxSize int64
+)
+
+// Entry 缓存条目
+type Entry struct {
+	model.CacheEntry
+	Protected bool // 属于未结束的下载任务或正在写入，不会被清理
+}
+
+// remove 删除条目对应的文件，缓存目录中的条目同时删除索引
+func (e Entry) remove() error {
+	if e.Kind == KindPart {
+		part, manifest := downloader.PartFiles(strings.TrimSuffix(e.Path, downloader.PartSuffix))
+		for _, p := range []string{part, manifest} {
+			if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := os.RemoveAll(filepath.Join(config.GetCacheDir(), e.Path)); err != nil {
+		return err
+	}
+	return model.GetDB().Delete(&model.CacheEntry{}, "path = ?", e.Path).Error
+}
+
+func (e Entry) accessTime() time.Time {
+	t, _ := time.Parse(time.RFC3339, e.AccessTime)
+	return t
+}
+
+// PruneOptions 清理条件
+type PruneOptions struct {
+	MaxSize int64  // 清理到总大小不超过 MaxSize，<= 0 时清理所有符合条件的条目
+	Kind    string // 只清理该类型的条目，为空时不限
+	DryRun  bool   // 只返回将被清理的条目，不删除
+
+	CacheDirOnly bool // 只统计和清理缓存目录中的条目，不包括下载目录中的 .bdpan-part
+}
+
+// Init 根据配置 cache 设置缓存目录的最大大小
+func Init(cfg config.Cache) error {
+	size, err := filter.ParseSize(cfg.MaxSize)
+	if err != nil {
+		return fmt.Errorf("cache.max_size: %w", err)
+	}
+	mu.Lock()
+	maxSize = size
+	mu.Unlock()
+	return nil
+}
+
+// MaxSize 缓存目录的最大大小，0 表示不限制
+func MaxSize() int64 {
+	mu.Lock()
+	defer mu.Unlock()
+	return maxSize
+}
+
+// Touch 记录缓存被访问，path 为缓存目录中的文件或文件夹，按其所在的第一层条目记录
+//
+// path 不在缓存目录中时不处理
+func Touch(path, kind string) error {
+	key, ok := entryKey(path)
+	if !ok {
+		return nil
+	}
+	size, err := diskUsage(filepath.Join(config.GetCacheDir(), key))
+	if err != nil {
+		return err
+	}
+	now := time.Now().Format(time.RFC3339)
+	e := model.CacheEntry{Path: key, Kind: kind, Size: size, AccessTime: now, CreateTime: now}
+	return model.GetDB().Transaction(func(tx *gorm.DB) error {
+		var old model.CacheEntry
+		err := tx.First(&old, "path = ?", key).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return tx.Create(&e).Error
+		} else if err != nil {
+			return err
+		}
+		return tx.Model(&old).Updates(map[string]any{"kind": kind, "size": size, "access_time": now}).Error
+	})
+}
+
+// Scan 同步索引与缓存目录，返回所有条目，按最近访问时间从旧到新排列
+//
+// 实现逻辑：
+//
+// 1. 缓存目录中未记录的条目加入索引，类型按内容判断，最近访问时间使用修改时间
+// 2. 已记录的条目更新大小，索引中已不存在的条目删除
+// 3. 加入下载任务目标路径下的 .bdpan-part，标记属于未结束下载任务或正在写入的条目
+func Scan() ([]Entry, error) {
+	root := config.GetCacheDir()
+	dirEntries, err := os.ReadDir(root)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	var rows []model.CacheEntry
+	if err := model.GetDB().Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	indexed := make(map[string]model.CacheEntry, len(rows))
+	for _, r := range rows {
+		indexed[r.Path] = r
+	}
+
+	entries := make([]Entry, 0, len(dirEntries))
+	for _, de := range dirEntries {
+		key := de.Name()
+		path := filepath.Join(root, key)
+		size, err := diskUsage(path)
+		if err != nil {
+			return nil, err
+		}
+		row, ok := indexed[key]
+		delete(indexed, key)
+		if !ok {
+			modTime := time.Now()
+			if info, err := de.Info(); err == nil {
+				modTime = info.ModTime()
+			}
+			row = model.CacheEntry{
+				Path:       key,
+				Kind:       detectKind(path, de.IsDir()),
+				Size:       size,
+				AccessTime: modTime.Format(time.RFC3339),
+				CreateTime: modTime.Format(time.RFC3339),
+			}
+			if err := model.GetDB().Create(&row).Error; err != nil {
+				return nil, err
+			}
+		} else if row.Size != size {
+			row.Size = size
+			if err := model.GetDB().Model(&row).Update("size", size).Error; err != nil {
+				return nil, err
+			}
+		}
+		entries = append(entries, Entry{CacheEntry: row})
+	}
+	for key := range indexed {
+		if err := model.GetDB().Delete(&model.CacheEntry{}, "path = ?", key).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	parts, err := partEntries()
+	if err != nil {
+		return nil, err
+	}
+	entries = append(entries, parts...)
+	slices.SortFunc(entries, func(a, b Entry) int {
+		return a.accessTime().Compare(b.accessTime())
+	})
+	return entries, nil
+}
+
+// Prune 按最近最少使用清理缓存，返回被清理的条目，受保护的条目不清理
+func Prune(opts PruneOptions) ([]Entry, error) {
+	entries, err := Scan()
+	if err != nil {
+		return nil, err
+	}
+	if opts.CacheDirOnly {
+		entries = slices.DeleteFunc(entries, func(e Entry) bool { return e.Kind == KindPart })
+	}
+	var total int64
+	for _, e := range entries {
+		total += e.Size
+	}
+	removed := make([]Entry, 0)
+	for _, e := range entries {
+		if opts.MaxSize > 0 && total <= opts.MaxSize {
+			break
+		}
+		if e.Protected || (opts.Kind != "" && e.Kind != opts.Kind) {
+			continue
+		}
+		if !opts.DryRun {
+			if err := e.remove(); err != nil {
+				return removed, err
+			}
+		}
+		total -= e.Size
+		removed = append(removed, e)
+	}
+	return removed, nil
+}
+
+// Enforce 缓存目录总大小超过 cache.max_size 时按最近最少使用清理，下载目录中的 .bdpan-part 不计入
+func Enforce() error {
+	limit := MaxSize()
+	if limit <= 0 {
+		return nil
+	}
+	_, err := Prune(PruneOptions{MaxSize: limit, CacheDirOnly: true})
+	return err
+}
+
+// CheckKind 校验条目类型，为空时不限
+func CheckKind(kind string) error {
+	switch kind {
+	case "", KindPreview, KindChunks, KindPart, KindOther:
+		return nil
+	}
+	return fmt.Errorf("不支持的缓存类型 %s，可选值: preview、chunks、part、other", kind)
+}
+
+// entryKey 返回 path 所在的缓存目录第一层条目名
+func entryKey(path string) (string, bool) {
+	rel, err := filepath.Rel(config.GetCacheDir(), path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return strings.SplitN(filepath.ToSlash(rel), "/", 2)[0], true
+}
+
+// detectKind 按内容判断条目类型：包含分片清单或分片文件的文件夹为分片缓存，其他文件夹为预览文件
+func detectKind(path string, isDir bool) string {
+	if !isDir {
+		return KindOther
+	}
+	if _, err := os.Stat(filepath.Join(path, "manifest.json")); err == nil {
+		return KindChunks
+	}
+	if chunks, _ := filepath.Glob(filepath.Join(path, "chunk_*")); len(chunks) > 0 {
+		return KindChunks
+	}
+	return KindPreview
+}
+
+// partEntries 下载任务目标路径下的 .bdpan-part，每个文件和它的分片清单为一个条目
+//
+// 实现逻辑：
+//
+// 1. 文件任务查找 TargetPath，未记录时为 OutputDir 下的同名文件，列表任务查找每个条目的 TargetPath
+// 2. 文件夹任务遍历 OutputDir 查找所有子文件的 .bdpan-part
+// 3. 任务未结束（运行中且仍存活、排队中、已暂停）或分片清单在 activeWindow 内有更新时受保护
+// 4. 大小为 .bdpan-part 与分片清单之和，最近访问时间为两者中较新的修改时间
+func partEntries() ([]Entry, error) {
+	tasks, err := taskstore.List(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	active := make(map[string]bool)
+	add := func(part string, unfinished bool) {
+		if _, ok := active[part]; !ok {
+			paths = append(paths, part)
+		}
+		active[part] = active[part] || unfinished
+	}
+	for _, t := range tasks {
+		if t.Type != taskstore.TaskTypeDownload {
+			continue
+		}
+		var data taskstore.DownloadData
+		if err := taskstore.DecodeData(&t, &data); err != nil {
+			continue
+		}
+		unfinished := !taskstore.IsFinished(t) && !taskstore.IsStale(t)
+		if data.IsDir {
+			if data.OutputDir == "" {
+				continue
+			}
+			filepath.WalkDir(data.OutputDir, func(p string, d fs.DirEntry, err error) error {
+				if err == nil && !d.IsDir() && strings.HasSuffix(p, downloader.PartSuffix) {
+					add(p, unfinished)
+				}
+				return nil
+			})
+			continue
+		}
+		targets := []string{data.TargetPath}
+		if len(data.Items) > 0 {
+			targets = targets[:0]
+			for _, item := range data.Items {
+				targets = append(targets, item.TargetPath)
+			}
+		} else if data.TargetPath == "" && data.OutputDir != "" {
+			targets[0] = filepath.Join(data.OutputDir, filepath.Base(data.Path))
+		}
+		for _, target := range targets {
+			if target == "" {
+				continue
+			}
+			if part, _ := downloader.PartFiles(target); fileExists(part) {
+				add(part, unfinished)
+			}
+		}
+	}
+
+	entries := make([]Entry, 0, len(paths))
+	for _, part := range paths {
+		e := Entry{CacheEntry: model.CacheEntry{Path: part, Kind: KindPart}}
+		var modTime time.Time
+		_, manifest := downloader.PartFiles(strings.TrimSuffix(part, downloader.PartSuffix))
+		for _, p := range []string{part, manifest} {
+			info, err := os.Stat(p)
+			if err != nil {
+				continue
+			}
+			e.Size += info.Size()
+			if info.ModTime().After(modTime) {
+				modTime = info.ModTime()
+			}
+		}
+		if modTime.IsZero() {
+			continue
+		}
+		e.AccessTime = modTime.Format(time.RFC3339)
+		e.CreateTime = e.AccessTime
+		e.Protected = active[part] || time.Since(modTime) < activeWindow
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// fileExists 判断 path 是否为已存在的文件
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// diskUsage 文件或文件夹中所有文件的大小之和
+func diskUsage(path string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
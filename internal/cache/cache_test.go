@@ -0,0 +1,327 @@
+package cache
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/wxnacy/bdpan-cli/internal/config"
+	"github.com/wxnacy/bdpan-cli/internal/downloader"
+	"github.com/wxnacy/bdpan-cli/internal/model"
+	"github.com/wxnacy/bdpan-cli/internal/taskstore"
+)
+
+// 数据库连接在进程内只初始化一次，所有测试共用同一个数据目录，每个测试开始时清空
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "bdpan-cache-test")
+	if err != nil {
+		panic(err)
+	}
+	config.Set(&config.Config{DataDir: dir})
+	code := m.Run()
+	model.CloseDB()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// resetCache 清空缓存目录、缓存索引和任务记录，并设置缓存大小上限
+func resetCache(t *testing.T, maxSize string) {
+	t.Helper()
+	if err := os.RemoveAll(config.GetCacheDir()); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(config.GetCacheDir(), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	db := model.GetDB()
+	if err := db.Where("1 = 1").Delete(&model.CacheEntry{}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Where("1 = 1").Delete(&model.Task{}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := Init(config.Cache{MaxSize: maxSize}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeEntry 在缓存目录第一层创建条目，kind 决定内容，修改时间为 age 之前
+func writeEntry(t *testing.T, key, kind string, size int, age time.Duration) {
+	t.Helper()
+	path := filepath.Join(config.GetCacheDir(), key)
+	switch kind {
+	case KindOther:
+		writeFile(t, path, size, age)
+	case KindChunks:
+		writeFile(t, filepath.Join(path, "chunk_0"), size, age)
+		writeFile(t, filepath.Join(path, "manifest.json"), 0, age)
+	default:
+		writeFile(t, filepath.Join(path, "preview"), size, age)
+	}
+	mtime := time.Now().Add(-age)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeFile 写入 size 字节的文件，修改时间为 age 之前
+func writeFile(t *testing.T, path string, size int, age time.Duration) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, make([]byte, size), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Now().Add(-age)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writePart 创建目标文件 target 下载中的 .bdpan-part 和分片清单，返回 .bdpan-part 路径
+func writePart(t *testing.T, target string, size int, age time.Duration) string {
+	t.Helper()
+	part, manifest := downloader.PartFiles(target)
+	writeFile(t, part, size, age)
+	writeFile(t, manifest, 0, age)
+	return part
+}
+
+// addTask 添加一个下载任务记录
+func addTask(t *testing.T, id, status string, data taskstore.DownloadData) {
+	t.Helper()
+	b, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	task := model.NewTask(id, taskstore.TaskTypeDownload, id, 0, os.Getpid(), "", 1, string(b))
+	task.Status = status
+	if err := model.GetDB().Create(task).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func entryPaths(entries []Entry) []string {
+	paths := make([]string, 0, len(entries))
+	for _, e := range entries {
+		paths = append(paths, e.Path)
+	}
+	return paths
+}
+
+func TestPrune(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        PruneOptions
+		wantRemoved []string
+	}{
+		{
+			name:        "清理到上限以内",
+			opts:        PruneOptions{MaxSize: 350},
+			wantRemoved: []string{"old", "mid"},
+		},
+		{
+			name:        "未超过上限",
+			opts:        PruneOptions{MaxSize: 600},
+			wantRemoved: []string{},
+		},
+		{
+			name:        "清理全部",
+			opts:        PruneOptions{},
+			wantRemoved: []string{"old", "mid", "new"},
+		},
+		{
+			name:        "只清理指定类型",
+			opts:        PruneOptions{MaxSize: 100, Kind: KindChunks},
+			wantRemoved: []string{"mid"},
+		},
+		{
+			name:        "只列出不删除",
+			opts:        PruneOptions{MaxSize: 350, DryRun: true},
+			wantRemoved: []string{"old", "mid"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetCache(t, "")
+			writeEntry(t, "new", KindOther, 300, time.Hour)
+			writeEntry(t, "old", KindPreview, 100, 3*time.Hour)
+			writeEntry(t, "mid", KindChunks, 200, 2*time.Hour)
+
+			removed, err := Prune(tt.opts)
+			if err != nil {
+				t.Fatalf("Prune() error = %v", err)
+			}
+			if got := entryPaths(removed); !slices.Equal(got, tt.wantRemoved) {
+				t.Errorf("Prune() removed = %v, want %v", got, tt.wantRemoved)
+			}
+
+			entries, err := Scan()
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []string{"old", "mid", "new"}
+			if !tt.opts.DryRun {
+				want = slices.DeleteFunc(want, func(p string) bool { return slices.Contains(tt.wantRemoved, p) })
+			}
+			if got := entryPaths(entries); !slices.Equal(got, want) {
+				t.Errorf("Scan() after prune = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestScan_Kinds(t *testing.T) {
+	resetCache(t, "")
+	writeEntry(t, "a", KindPreview, 10, 3*time.Hour)
+	writeEntry(t, "b", KindChunks, 20, 2*time.Hour)
+	writeEntry(t, "c", KindOther, 30, time.Hour)
+
+	entries, err := Scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]Entry{
+		"a": {CacheEntry: model.CacheEntry{Kind: KindPreview, Size: 10}},
+		"b": {CacheEntry: model.CacheEntry{Kind: KindChunks, Size: 20}},
+		"c": {CacheEntry: model.CacheEntry{Kind: KindOther, Size: 30}},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("Scan() = %v, want 3 entries", entryPaths(entries))
+	}
+	for _, e := range entries {
+		w := want[e.Path]
+		if e.Kind != w.Kind || e.Size != w.Size || e.Protected {
+			t.Errorf("%s: kind %s size %d protected %v, want kind %s size %d", e.Path, e.Kind, e.Size, e.Protected, w.Kind, w.Size)
+		}
+	}
+}
+
+func TestScan_TouchUpdatesOrder(t *testing.T) {
+	resetCache(t, "")
+	writeEntry(t, "a", KindPreview, 10, 2*time.Hour)
+	writeEntry(t, "b", KindPreview, 10, time.Hour)
+	if _, err := Scan(); err != nil {
+		t.Fatal(err)
+	}
+	if err := Touch(filepath.Join(config.GetCacheDir(), "a", "preview"), KindPreview); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := Scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := entryPaths(entries), []string{"b", "a"}; !slices.Equal(got, want) {
+		t.Errorf("Scan() = %v, want %v", got, want)
+	}
+}
+
+func TestPrune_PartFiles(t *testing.T) {
+	resetCache(t, "")
+	dir := t.TempDir()
+	old := 3 * time.Hour
+
+	// 文件任务：已暂停的保留，失败的可以清理
+	paused := writePart(t, filepath.Join(dir, "paused.bin"), 100, old)
+	addTask(t, "paused", taskstore.StatusPaused, taskstore.DownloadData{Path: "/paused.bin", TargetPath: filepath.Join(dir, "paused.bin")})
+	failed := writePart(t, filepath.Join(dir, "failed.bin"), 100, old)
+	addTask(t, "failed", taskstore.StatusFailed, taskstore.DownloadData{Path: "/failed.bin", TargetPath: filepath.Join(dir, "failed.bin")})
+	// 排队中的文件任务未记录 TargetPath，查找 OutputDir 下的同名文件
+	queued := writePart(t, filepath.Join(dir, "out", "queued.bin"), 100, old)
+	addTask(t, "queued", taskstore.StatusQueued, taskstore.DownloadData{Path: "/queued.bin", OutputDir: filepath.Join(dir, "out")})
+	// 文件夹任务遍历 OutputDir
+	child := writePart(t, filepath.Join(dir, "folder", "sub", "child.bin"), 100, old)
+	addTask(t, "folder", taskstore.StatusPaused, taskstore.DownloadData{Path: "/folder", OutputDir: filepath.Join(dir, "folder"), IsDir: true})
+	canceledChild := writePart(t, filepath.Join(dir, "canceled", "child.bin"), 100, old)
+	addTask(t, "canceled", taskstore.StatusCanceled, taskstore.DownloadData{Path: "/canceled", OutputDir: filepath.Join(dir, "canceled"), IsDir: true})
+	// 列表任务查找每个条目的 TargetPath
+	item := writePart(t, filepath.Join(dir, "list", "item.bin"), 100, old)
+	addTask(t, "list", taskstore.StatusQueued, taskstore.DownloadData{Path: "list.txt", Items: []taskstore.DownloadItem{{Path: "/item.bin", TargetPath: filepath.Join(dir, "list", "item.bin")}}})
+	// 失败任务的 .bdpan-part 刚刚写入，可能仍在下载中
+	recent := writePart(t, filepath.Join(dir, "recent.bin"), 100, 0)
+	addTask(t, "recent", taskstore.StatusFailed, taskstore.DownloadData{Path: "/recent.bin", TargetPath: filepath.Join(dir, "recent.bin")})
+	writeEntry(t, "preview", KindPreview, 100, old)
+
+	entries, err := Scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantProtected := map[string]bool{
+		paused: true, failed: false, queued: true, child: true, canceledChild: false, item: true, recent: true, "preview": false,
+	}
+	if len(entries) != len(wantProtected) {
+		t.Fatalf("Scan() = %v, want %d entries", entryPaths(entries), len(wantProtected))
+	}
+	for _, e := range entries {
+		want, ok := wantProtected[e.Path]
+		if !ok {
+			t.Errorf("unexpected entry %s", e.Path)
+			continue
+		}
+		if e.Protected != want {
+			t.Errorf("%s: protected = %v, want %v", e.Path, e.Protected, want)
+		}
+		if e.Path != "preview" && (e.Kind != KindPart || e.Size != 100) {
+			t.Errorf("%s: kind %s size %d, want kind %s size 100", e.Path, e.Kind, e.Size, KindPart)
+		}
+	}
+
+	removed, err := Prune(PruneOptions{Kind: KindPart})
+	if err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	got := entryPaths(removed)
+	slices.Sort(got)
+	want := []string{canceledChild, failed}
+	slices.Sort(want)
+	if !slices.Equal(got, want) {
+		t.Errorf("Prune() removed = %v, want %v", got, want)
+	}
+	for part, protected := range wantProtected {
+		if part == "preview" {
+			continue
+		}
+		_, manifest := downloader.PartFiles(part[:len(part)-len(downloader.PartSuffix)])
+		for _, p := range []string{part, manifest} {
+			if _, err := os.Stat(p); (err == nil) != protected {
+				t.Errorf("%s exists = %v, want %v", p, err == nil, protected)
+			}
+		}
+	}
+}
+
+func TestEnforce(t *testing.T) {
+	resetCache(t, "250")
+	dir := t.TempDir()
+	part := writePart(t, filepath.Join(dir, "failed.bin"), 1000, 4*time.Hour)
+	addTask(t, "failed", taskstore.StatusFailed, taskstore.DownloadData{Path: "/failed.bin", TargetPath: filepath.Join(dir, "failed.bin")})
+	writeEntry(t, "old", KindPreview, 100, 3*time.Hour)
+	writeEntry(t, "mid", KindPreview, 100, 2*time.Hour)
+	writeEntry(t, "new", KindPreview, 100, time.Hour)
+
+	// 下载目录中的 .bdpan-part 不计入缓存目录大小，也不会被自动清理
+	if err := Enforce(); err != nil {
+		t.Fatalf("Enforce() error = %v", err)
+	}
+	entries, err := Scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := entryPaths(entries), []string{part, "mid", "new"}; !slices.Equal(got, want) {
+		t.Errorf("Scan() after Enforce = %v, want %v", got, want)
+	}
+
+	// 未设置上限时不清理
+	resetCache(t, "")
+	writeEntry(t, "old", KindPreview, 100, time.Hour)
+	if err := Enforce(); err != nil {
+		t.Fatalf("Enforce() error = %v", err)
+	}
+	if entries, err := Scan(); err != nil || len(entries) != 1 {
+		t.Errorf("Scan() = %v, %v, want 1 entry", entryPaths(entries), err)
+	}
+}
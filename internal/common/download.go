@@ -5,6 +5,8 @@ import (
 	"io"
 	"net/http"
 	"os"
+
+	"github.com/wxnacy/bdpan-cli/internal/httpclient"
 )
 
 // 直接下载小文件的函数
@@ -18,6 +20,7 @@ func DownloadFile(url, outputPath string) error {
 
 	// 创建 HTTP 客户端并处理 302 跳转
 	client := &http.Client{
+		Transport: httpclient.Transport(),
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			req.Header.Set("User-Agent", "pan.baidu.com") // 保持 User-Agent 在跳转中不变
 			return nil
@@ -26,9 +26,61 @@ func Set(conf *Config) {
 type Config struct {
 	App     App    `yaml:"app" json:"app"`
 	DataDir string `yaml:"data_dir" json:"data_dir" mapstructure:"data_dir"`
+	Daemon  Daemon `yaml:"daemon" json:"daemon"`
+	Limit   Limit  `yaml:"limit" json:"limit"`
+	Upload  Upload `yaml:"upload" json:"upload"`
+	HTTP    HTTP   `yaml:"http" json:"http"`
+	Cache   Cache  `yaml:"cache" json:"cache"`
 }
 
 type App struct {
 	Name  string `yaml:"name" json:"name"`
 	Scope string `yaml:"scope" json:"scope"`
 }
+
+type Daemon struct {
+	Workers int `yaml:"workers" json:"workers"`
+}
+
+// Upload 上传配置
+type Upload struct {
+	// 大文件同时上传的分片数
+	Concurrency int `yaml:"concurrency" json:"concurrency"`
+}
+
+// Limit 上传、下载限速，速率格式如 512K、2M，为空或 0 时不限速
+type Limit struct {
+	Download string      `yaml:"download" json:"download"`
+	Upload   string      `yaml:"upload" json:"upload"`
+	Schedule []LimitRule `yaml:"schedule" json:"schedule"`
+}
+
+// LimitRule 时间段限速，Start、End 格式为 HH:MM，End 早于 Start 时表示跨天
+type LimitRule struct {
+	Start    string `yaml:"start" json:"start"`
+	End      string `yaml:"end" json:"end"`
+	Download string `yaml:"download" json:"download"`
+	Upload   string `yaml:"upload" json:"upload"`
+}
+
+// HTTP 所有网络请求共用的连接配置，时间格式如 10s、1m，为空时使用默认值
+type HTTP struct {
+	// 代理地址，支持 http://、https://、socks5://，为空时使用 HTTP_PROXY、HTTPS_PROXY 环境变量
+	Proxy string `yaml:"proxy" json:"proxy"`
+	// 建立连接（包括 TLS 握手）的超时时间
+	ConnectTimeout string `yaml:"connect_timeout" json:"connect_timeout" mapstructure:"connect_timeout"`
+	// 空闲连接保留的时间
+	IdleTimeout string `yaml:"idle_timeout" json:"idle_timeout" mapstructure:"idle_timeout"`
+	// 额外信任的根证书文件（PEM 格式），与系统根证书一起使用
+	CAFiles []string `yaml:"ca_files" json:"ca_files" mapstructure:"ca_files"`
+	// 是否复用连接
+	KeepAlive bool `yaml:"keep_alive" json:"keep_alive" mapstructure:"keep_alive"`
+	// 每个域名最多保留的空闲连接数
+	MaxIdleConnsPerHost int `yaml:"max_idle_conns_per_host" json:"max_idle_conns_per_host" mapstructure:"max_idle_conns_per_host"`
+}
+
+// Cache 缓存目录的配置
+type Cache struct {
+	// 缓存目录的最大大小，如 500M、2G，超过时按最近最少使用清理，为空或 0 时不限制
+	MaxSize string `yaml:"max_size" json:"max_size" mapstructure:"max_size"`
+}
@@ -21,6 +21,14 @@ app:
     name: bdpan
     scope: basic,netdisk
 data_dir: "~/.local/share/bdpan"
+daemon:
+    workers: 2
+upload:
+    concurrency: 4
+http:
+    keep_alive: true
+cache:
+    max_size: 1G
 `)
 	initOnce sync.Once
 )
@@ -199,6 +207,22 @@ func GetDBFile() string {
 	return filepath.Join(Get().DataDir, "bdpan.db?_journal_mode=wal&_synchronous=normal&_busy_timeout=5000&_foreign_keys=on&mode=rwc&check_same_thread=false")
 }
 
+// 获取 daemon 同时执行的任务数，默认为 2，配置小于 1 时按 1 处理
+func GetDaemonWorkers() int {
+	if n := Get().Daemon.Workers; n > 0 {
+		return n
+	}
+	return 1
+}
+
+// 获取大文件同时上传的分片数，默认为 4，配置小于 1 时按 1 处理
+func GetUploadConcurrency() int {
+	if n := Get().Upload.Concurrency; n > 0 {
+		return n
+	}
+	return 1
+}
+
 // 获取缓存目录
 func GetCacheDir() string {
 	return filepath.Join(Get().DataDir, "cache")
@@ -95,3 +95,92 @@ func TestInit_MissingFile_OK(t *testing.T) {
         t.Fatalf("expected DataDir to be set from defaults")
     }
 }
+
+func TestInit_DaemonWorkers(t *testing.T) {
+    resetConfigState()
+    if err := Init(""); err != nil {
+        t.Fatalf("Init returned error: %v", err)
+    }
+    if GetDaemonWorkers() != 2 {
+        t.Fatalf("expected default daemon workers 2, got %d", GetDaemonWorkers())
+    }
+
+    resetConfigState()
+    p := filepath.Join(t.TempDir(), "conf.yml")
+    os.WriteFile(p, []byte("daemon:\n  workers: 5\n"), 0o644)
+    if err := Init(p); err != nil {
+        t.Fatalf("Init returned error: %v", err)
+    }
+    if GetDaemonWorkers() != 5 {
+        t.Fatalf("expected daemon workers 5, got %d", GetDaemonWorkers())
+    }
+}
+
+func TestInit_UploadConcurrency(t *testing.T) {
+    resetConfigState()
+    if err := Init(""); err != nil {
+        t.Fatalf("Init returned error: %v", err)
+    }
+    if GetUploadConcurrency() != 4 {
+        t.Fatalf("expected default upload concurrency 4, got %d", GetUploadConcurrency())
+    }
+
+    resetConfigState()
+    p := filepath.Join(t.TempDir(), "conf.yml")
+    os.WriteFile(p, []byte("upload:\n  concurrency: 8\n"), 0o644)
+    if err := Init(p); err != nil {
+        t.Fatalf("Init returned error: %v", err)
+    }
+    if GetUploadConcurrency() != 8 {
+        t.Fatalf("expected upload concurrency 8, got %d", GetUploadConcurrency())
+    }
+}
+
+func TestInit_Limit(t *testing.T) {
+    resetConfigState()
+    p := filepath.Join(t.TempDir(), "conf.yml")
+    content := "limit:\n  download: 2M\n  schedule:\n    - start: \"23:00\"\n      end: \"07:00\"\n      download: 0\n      upload: 512K\n"
+    os.WriteFile(p, []byte(content), 0o644)
+    if err := Init(p); err != nil {
+        t.Fatalf("Init returned error: %v", err)
+    }
+    limit := Get().Limit
+    if limit.Download != "2M" || limit.Upload != "" {
+        t.Fatalf("unexpected limit: %#v", limit)
+    }
+    if len(limit.Schedule) != 1 {
+        t.Fatalf("expected 1 schedule rule, got %d", len(limit.Schedule))
+    }
+    rule := limit.Schedule[0]
+    if rule.Start != "23:00" || rule.End != "07:00" || rule.Download != "0" || rule.Upload != "512K" {
+        t.Fatalf("unexpected schedule rule: %#v", rule)
+    }
+}
+
+func TestInit_HTTP(t *testing.T) {
+    resetConfigState()
+    if err := Init(""); err != nil {
+        t.Fatalf("Init returned error: %v", err)
+    }
+    if !Get().HTTP.KeepAlive {
+        t.Fatalf("expected keep_alive enabled by default")
+    }
+
+    resetConfigState()
+    p := filepath.Join(t.TempDir(), "conf.yml")
+    content := "http:\n  proxy: socks5://127.0.0.1:1080\n  connect_timeout: 10s\n  ca_files:\n    - ~/corp.pem\n  max_idle_conns_per_host: 4\n"
+    os.WriteFile(p, []byte(content), 0o644)
+    if err := Init(p); err != nil {
+        t.Fatalf("Init returned error: %v", err)
+    }
+    h := Get().HTTP
+    if h.Proxy != "socks5://127.0.0.1:1080" || h.ConnectTimeout != "10s" || h.MaxIdleConnsPerHost != 4 {
+        t.Fatalf("unexpected http config: %#v", h)
+    }
+    if len(h.CAFiles) != 1 || h.CAFiles[0] != "~/corp.pem" {
+        t.Fatalf("unexpected ca_files: %#v", h.CAFiles)
+    }
+    if !h.KeepAlive {
+        t.Fatalf("expected keep_alive kept from defaults when not set in file")
+    }
+}
@@ -0,0 +1,100 @@
+package downloader
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/wxnacy/bdpan-cli/internal/logger"
+)
+
+const (
+	// tuneInterval 测量下载速度并调整并发数、分片大小的间隔
+	tuneInterval = 3 * time.Second
+
+	// chunkTargetDuration 单个分片期望的下载时长，分片大小 = 单连接速度 * chunkTargetDuration
+	chunkTargetDuration = 10 * time.Second
+
+	// speedGainRatio 增加并发后总速度至少提升的比例，否则认为已达到上限
+	speedGainRatio = 1.1
+
+	// plateauHoldTicks 达到速度上限或出错后，再次尝试增加并发前等待的调整次数
+	plateauHoldTicks = 5
+)
+
+// tuner 根据下载速度自动调整并发数和分片大小
+//
+// 实现细节：
+// - 每次调整前测量上一个周期的总下载速度
+// - 增加一个并发后，如果总速度提升超过 10% 则保留并继续尝试，否则撤销并等待一段时间后再尝试
+// - 周期内出现重试（网络错误、限流、5xx）时并发数减半
+// - 分片大小按单连接速度计算，使单个分片大约下载 10 秒，在 MinChunkSize 和 MaxChunkSize 之间
+// - 切分新分片时不超过剩余大小除以两倍并发数，避免最后只剩一个大分片单线程下载
+type tuner struct {
+	lastBytes int64
+	lastTime  time.Time
+	baseline  float64 // 增加并发前的总速度
+	probing   bool    // 是否刚增加了并发，等待测量结果
+	hold      int     // 再次增加并发前还需等待的调整次数
+	errors    atomic.Int64
+}
+
+func newTuner(downloaded int64) *tuner {
+	return &tuner{lastBytes: downloaded, lastTime: time.Now()}
+}
+
+// noteError 记录一次失败重试
+func (t *tuner) noteError() {
+	if t != nil {
+		t.errors.Add(1)
+	}
+}
+
+// tune 根据上一个周期的下载速度调整并发数和分片大小，active 为正在下载的分片数
+func (d *ChunkDownloader) tune(active int) {
+	t := d.tuner
+	d.mu.Lock()
+	downloaded := d.downloadedSum
+	d.mu.Unlock()
+	now := time.Now()
+	elapsed := now.Sub(t.lastTime).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+	speed := float64(downloaded-t.lastBytes) / elapsed
+	t.lastBytes = downloaded
+	t.lastTime = now
+
+	if !d.fixedChunkSize && active > 0 && speed > 0 {
+		size := int64(speed / float64(active) * chunkTargetDuration.Seconds())
+		d.ChunkSize = min(max(size, MinChunkSize), MaxChunkSize)
+	}
+	if d.fixedConcurrency {
+		return
+	}
+
+	before := d.Concurrency
+	switch {
+	case t.errors.Swap(0) > 0:
+		d.Concurrency = max(d.Concurrency/2, 1)
+		t.probing = false
+		t.hold = plateauHoldTicks
+	case t.probing:
+		t.probing = false
+		// 剩余分片不足以用满并发时速度下降不代表并发过多，不撤销
+		if active >= d.Concurrency && speed < t.baseline*speedGainRatio {
+			d.Concurrency = max(d.Concurrency-1, 1)
+			t.hold = plateauHoldTicks
+		}
+	case t.hold > 0:
+		t.hold--
+	case active >= d.Concurrency && d.Concurrency < MaxConcurrency:
+		// 只有并发已用满时增加才有意义，剩余分片不足时不再尝试
+		t.baseline = speed
+		t.probing = true
+		d.Concurrency++
+	}
+	if d.Concurrency != before {
+		logger.Infof("下载速度 %.2f MB/s，并发数调整为 %d，分片大小 %.2f MB",
+			speed/1024/1024, d.Concurrency, float64(d.ChunkSize)/1024/1024)
+	}
+}
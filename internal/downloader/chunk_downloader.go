@@ -2,6 +2,7 @@ package downloader
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -11,7 +12,9 @@ import (
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/wxnacy/bdpan-cli/internal/httpclient"
 	"github.com/wxnacy/bdpan-cli/internal/logger"
+	"github.com/wxnacy/bdpan-cli/internal/ratelimit"
 )
 
 const (
@@ -25,13 +28,38 @@ const (
 	// MaxChunkSize 最大分片大小 - 50MB
 	MaxChunkSize = 50 * 1024 * 1024
 
-	// DefaultConcurrency 默认并发数
+	// DefaultConcurrency 默认并发数，自动调整时作为初始并发数
 	DefaultConcurrency = 3
 
+	// MaxConcurrency 最大并发数
+	MaxConcurrency = 16
+
 	// userAgent 统一的 User-Agent 字符串
 	userAgent = "pan.baidu.com"
+
+	// DefaultMaxRetries 每个分片的默认最大重试次数
+	DefaultMaxRetries = 5
+
+	// retryBaseDelay 重试等待的初始时间，每次失败翻倍，最长 retryMaxDelay
+	retryBaseDelay = 1 * time.Second
+	retryMaxDelay  = 30 * time.Second
 )
 
+// StatusError 服务端返回了非预期的 HTTP 状态码
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("HTTP status: %d", e.StatusCode)
+}
+
+// isLinkExpired 判断是否为下载链接过期，百度网盘 Dlink 过期后返回 403
+func isLinkExpired(err error) bool {
+	var se *StatusError
+	return errors.As(err, &se) && (se.StatusCode == http.StatusForbidden || se.StatusCode == http.StatusGone)
+}
+
 // ChunkInfo 分片信息
 type ChunkInfo struct {
 	Index      int   // 分片索引（从0开始）
@@ -51,15 +79,18 @@ type ChunkInfo struct {
 //
 // 实现细节：
 // - 使用 HTTP Range 头实现分片下载
-// - 将分片缓存保存到临时目录
-// - 下载完成后合并所有分片到目标文件
-// - 断点续传通过检查缓存文件的大小实现
+// - 将分片缓存保存到临时目录，分片进度记录在缓存目录的 manifest.json 中
+// - 单个分片失败时按指数退避重试，重试时从分片已下载的位置继续
+// - 下载链接过期（403）时通过 SetURLRefresher 设置的函数获取新链接，剩余分片使用新链接
+// - 下载完成后合并所有分片到目标文件，启用 EnablePartFile 时分片直接写入预分配的 .bdpan-part 文件
+// - 断点续传以分片清单为准，清单与远程文件不一致时丢弃缓存
+// - 未调用 SetConcurrency/SetChunkSize 时根据下载速度自动调整并发数和分片大小，见 adaptive.go
 type ChunkDownloader struct {
 	URL           string           // 下载 URL
 	OutputPath    string           // 输出文件路径
 	CacheDir      string           // 缓存目录
-	ChunkSize     int64            // 分片大小
-	Concurrency   int              // 并发数
+	ChunkSize     int64            // 分片大小，自动调整时为新切分分片的大小
+	Concurrency   int              // 并发数，自动调整时为当前的并发数
 	TotalSize     int64            // 文件总大小
 	Chunks        []*ChunkInfo     // 分片列表
 	ProgressFunc  func(int64, int64) // 进度回调函数 (已下载, 总大小)
@@ -71,6 +102,19 @@ type ChunkDownloader struct {
 	mu            sync.Mutex       // 互斥锁
 	downloadedSum int64            // 已下载总量
 	progressWriter *ProgressWriter // 进度写入器
+	limiter       *ratelimit.Limiter // 限速器，所有分片共享
+	MaxRetries    int              // 每个分片的最大重试次数
+	refreshURL    func() (string, error) // 下载链接过期时获取新链接
+	urlMu         sync.Mutex       // 保护 URL 的并发刷新
+	identity      string           // 下载内容标识，记录在分片清单中
+	manifest      *Manifest        // 分片清单
+	manifestMu    sync.Mutex       // 串行保存分片清单
+	usePartFile   bool             // 是否启用预分配文件模式，见 part_file.go
+	part          *os.File         // 预分配文件模式下的 .bdpan-part 文件
+	nextOffset    int64            // 下一个分片的起始位置，分片按需从文件开头连续切分
+	fixedChunkSize   bool          // 是否通过 SetChunkSize 固定分片大小
+	fixedConcurrency bool          // 是否通过 SetConcurrency 固定并发数
+	tuner         *tuner           // 并发数和分片大小的自动调整
 }
 
 // NewChunkDownloader 创建分片下载器
@@ -85,25 +129,22 @@ func NewChunkDownloader(url, outputPath, cacheDir string) *ChunkDownloader {
 		Concurrency: DefaultConcurrency,
 		client: &http.Client{
 			Timeout: 30 * time.Minute,
-			Transport: &http.Transport{
-				// 关闭 keep-alive，避免空闲连接上服务端的额外输出触发标准库日志
-				DisableKeepAlives: true,
-			},
+			// 使用共享的 Transport，代理、超时、证书和连接复用见配置 http
+			Transport: httpclient.Transport(),
 			CheckRedirect: func(req *http.Request, via []*http.Request) error {
 				// 保持 User-Agent 在跳转中不变
 				req.Header.Set("User-Agent", userAgent)
-				// 保证重定向请求也不复用连接
-				req.Close = true
-				req.Header.Set("Connection", "close")
 				return nil
 			},
 		},
-		ctx:    ctx,
-		cancel: cancel,
+		ctx:        ctx,
+		cancel:     cancel,
+		limiter:    ratelimit.Download(),
+		MaxRetries: DefaultMaxRetries,
 	}
 }
 
-// SetChunkSize 设置分片大小
+// SetChunkSize 固定分片大小，不再自动调整
 func (d *ChunkDownloader) SetChunkSize(size int64) *ChunkDownloader {
 	if size < MinChunkSize {
 		size = MinChunkSize
@@ -112,18 +153,20 @@ func (d *ChunkDownloader) SetChunkSize(size int64) *ChunkDownloader {
 		size = MaxChunkSize
 	}
 	d.ChunkSize = size
+	d.fixedChunkSize = true
 	return d
 }
 
-// SetConcurrency 设置并发数
+// SetConcurrency 固定并发数，不再自动调整
 func (d *ChunkDownloader) SetConcurrency(n int) *ChunkDownloader {
 	if n < 1 {
 		n = 1
 	}
-	if n > 10 {
-		n = 10
+	if n > MaxConcurrency {
+		n = MaxConcurrency
 	}
 	d.Concurrency = n
+	d.fixedConcurrency = true
 	return d
 }
 
@@ -133,6 +176,33 @@ func (d *ChunkDownloader) SetProgressFunc(f func(int64, int64)) *ChunkDownloader
 	return d
 }
 
+// SetLimiter 设置限速器，默认使用全局下载限速器，nil 表示不限速
+func (d *ChunkDownloader) SetLimiter(l *ratelimit.Limiter) *ChunkDownloader {
+	d.limiter = l
+	return d
+}
+
+// SetMaxRetries 设置每个分片的最大重试次数，0 表示不重试
+func (d *ChunkDownloader) SetMaxRetries(n int) *ChunkDownloader {
+	if n < 0 {
+		n = 0
+	}
+	d.MaxRetries = n
+	return d
+}
+
+// SetIdentity 设置下载内容标识，续传时标识不一致的缓存会被丢弃，默认为去掉参数的 URL
+func (d *ChunkDownloader) SetIdentity(identity string) *ChunkDownloader {
+	d.identity = identity
+	return d
+}
+
+// SetURLRefresher 设置下载链接刷新函数，下载链接过期时调用，返回新的下载链接
+func (d *ChunkDownloader) SetURLRefresher(f func() (string, error)) *ChunkDownloader {
+	d.refreshURL = f
+	return d
+}
+
 // EnableTUI 启用 TUI 进度条
 func (d *ChunkDownloader) EnableTUI(filename string) *ChunkDownloader {
 	d.useTUI = true
@@ -144,15 +214,21 @@ func (d *ChunkDownloader) EnableTUI(filename string) *ChunkDownloader {
 //
 // 实现步骤：
 // 1. 获取文件总大小，确定是否支持 Range 下载
-// 2. 计算分片信息
+// 2. 创建缓存目录，预分配文件模式下清理旧版本缓存目录模式留下的分片
 // 3. 如果启用 TUI，创建进度条程序
-// 4. 检查断点续传：扫描缓存目录，恢复已下载的分片进度
-// 5. 并发下载未完成的分片
-// 6. 合并所有分片到目标文件
+// 4. 检查断点续传：根据分片清单恢复已下载的分片进度，清单与远程文件不一致时丢弃缓存
+// 5. 并发下载未完成的分片，剩余部分按需切分新分片，单个分片失败时重试，链接过期时刷新链接
+// 6. 合并所有分片到目标文件，预分配文件模式下将 .bdpan-part 重命名为目标文件
 // 7. 清理缓存文件
 func (d *ChunkDownloader) Start() error {
 	// 1. 获取文件总大小
-	totalSize, supportsRange, err := d.getFileSize()
+	var totalSize int64
+	var supportsRange bool
+	err := d.withRetry("获取文件大小", func(url string) error {
+		var err error
+		totalSize, supportsRange, err = d.getFileSize(url)
+		return err
+	})
 	if err != nil {
 		return fmt.Errorf("获取文件大小失败: %w", err)
 	}
@@ -162,17 +238,30 @@ func (d *ChunkDownloader) Start() error {
 	// 如果不支持 Range 或文件很小，直接下载
 	if !supportsRange || totalSize < d.ChunkSize {
 		logger.Infof("不支持分片下载或文件过小，使用直接下载")
-		return d.downloadDirect()
+		var written int64
+		err := d.withRetry("直接下载", func(url string) error {
+			// 直接下载无法续传，重试时从头下载，扣除上一次的进度
+			if written > 0 {
+				d.updateProgress(-written)
+				written = 0
+			}
+			return d.downloadDirect(url, &written)
+		})
+		if err == nil && d.usePartFile {
+			err = os.Rename(d.partPath(), d.OutputPath)
+		}
+		return err
 	}
 
-	// 2. 计算分片
-	d.calculateChunks()
-	logger.Infof("分片数量: %d, 每片大小: %.2f MB", len(d.Chunks), float64(d.ChunkSize)/1024/1024)
-
-	// 3. 创建缓存目录
-	if err := os.MkdirAll(d.CacheDir, 0755); err != nil {
-		return fmt.Errorf("创建缓存目录失败: %w", err)
+	// 3. 创建缓存目录，预分配文件模式下分片清单保存在目标文件旁，不需要缓存目录
+	if !d.usePartFile {
+		if err := os.MkdirAll(d.CacheDir, 0755); err != nil {
+			return fmt.Errorf("创建缓存目录失败: %w", err)
+		}
+	} else {
+		d.removeLegacyCache()
 	}
+	defer d.closePartFile()
 
 	// 4. 如果启用 TUI，创建进度条程序
 	var p *tea.Program
@@ -194,7 +283,9 @@ func (d *ChunkDownloader) Start() error {
 	}
 
 	// 5. 检查断点续传
-	d.checkResume()
+	if err := d.checkResume(); err != nil {
+		return fmt.Errorf("检查断点续传失败: %w", err)
+	}
 
 	// 6. 并发下载分片
 	err = d.downloadChunks()
@@ -210,17 +301,26 @@ func (d *ChunkDownloader) Start() error {
         return err
     }
 
-	// 7. 合并分片
-	logger.Infof("开始合并分片...")
-	if err := d.mergeChunks(); err != nil {
-		if d.progressWriter != nil {
-			d.progressWriter.Error(err)
+	// 7. 合并分片，预分配文件模式下重命名为目标文件
+	if d.usePartFile {
+		if err := d.finalizePartFile(); err != nil {
+			if d.progressWriter != nil {
+				d.progressWriter.Error(err)
+			}
+			return fmt.Errorf("重命名下载文件失败: %w", err)
+		}
+	} else {
+		logger.Infof("开始合并分片...")
+		if err := d.mergeChunks(); err != nil {
+			if d.progressWriter != nil {
+				d.progressWriter.Error(err)
+			}
+			return fmt.Errorf("合并分片失败: %w", err)
 		}
-		return fmt.Errorf("合并分片失败: %w", err)
-	}
 
-	// 8. 清理缓存
-	d.cleanup()
+		// 8. 清理缓存
+		d.cleanup()
+	}
 	
 	// 9. 通知完成
 	if d.progressWriter != nil {
@@ -249,15 +349,12 @@ func (d *ChunkDownloader) SetContext(ctx context.Context, cancel context.CancelF
 }
 
 // getFileSize 获取文件大小并检查是否支持 Range 下载
-func (d *ChunkDownloader) getFileSize() (int64, bool, error) {
-	req, err := http.NewRequestWithContext(d.ctx, "HEAD", d.URL, nil)
+func (d *ChunkDownloader) getFileSize(url string) (int64, bool, error) {
+	req, err := http.NewRequestWithContext(d.ctx, "HEAD", url, nil)
 	if err != nil {
 		return 0, false, err
 	}
 	req.Header.Set("User-Agent", userAgent)
-	// 使用短连接，避免空闲连接引发标准库日志
-	req.Close = true
-	req.Header.Set("Connection", "close")
 
 	resp, err := d.client.Do(req)
 	if err != nil {
@@ -266,7 +363,7 @@ func (d *ChunkDownloader) getFileSize() (int64, bool, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return 0, false, fmt.Errorf("HTTP status: %d", resp.StatusCode)
+		return 0, false, &StatusError{StatusCode: resp.StatusCode}
 	}
 
 	size := resp.ContentLength
@@ -275,84 +372,256 @@ func (d *ChunkDownloader) getFileSize() (int64, bool, error) {
 	return size, supportsRange, nil
 }
 
-// calculateChunks 计算分片信息
-func (d *ChunkDownloader) calculateChunks() {
-	totalChunks := int((d.TotalSize + d.ChunkSize - 1) / d.ChunkSize)
-	d.Chunks = make([]*ChunkInfo, totalChunks)
-
-	for i := 0; i < totalChunks; i++ {
-		start := int64(i) * d.ChunkSize
-		end := start + d.ChunkSize - 1
-		if end >= d.TotalSize {
-			end = d.TotalSize - 1
+// checkResume 根据分片清单检查断点续传
+//
+// 实现逻辑：
+//
+// 1. 清单不存在、无法解析或标识、总大小与本次下载不一致时，删除已有分片重新下载
+// 2. 预分配文件模式下 .bdpan-part 不存在或大小不一致时同样丢弃
+// 3. 已完成的分片校验长度和 md5，校验失败的分片重新下载
+// 4. 未完成的分片以清单记录的长度为准，分片文件多出的部分截断，不足时重新下载该分片
+// 5. 清单中未切分的剩余部分在下载时按需切分
+// 6. 保存校正后的清单
+func (d *ChunkDownloader) checkResume() error {
+	identity := d.identity
+	if identity == "" {
+		identity = urlIdentity(d.currentURL())
+	}
+	m, err := loadManifest(d.manifestPath())
+	if err != nil {
+		logger.Infof("%v，丢弃缓存", err)
+		m = nil
+	}
+	valid := m != nil && m.matches(identity, d.TotalSize)
+	if valid && d.usePartFile {
+		if err := d.openPartFile(); err != nil {
+			logger.Infof("%v", err)
+			valid = false
 		}
-
-		d.Chunks[i] = &ChunkInfo{
-			Index: i,
-			Start: start,
-			End:   end,
-			Size:  end - start + 1,
+	}
+	if !valid {
+		if m != nil {
+			logger.Infof("缓存与远程文件不一致，丢弃缓存: %s", d.manifestPath())
+		}
+		m = &Manifest{
+			Version:   manifestVersion,
+			Identity:  identity,
+			TotalSize: d.TotalSize,
+		}
+		if paths, err := filepath.Glob(filepath.Join(d.CacheDir, "chunk_*")); err == nil {
+			for _, p := range paths {
+				os.Remove(p)
+			}
+		}
+		if d.usePartFile {
+			if err := d.createPartFile(); err != nil {
+				return err
+			}
 		}
+		d.manifest = m
+		return d.saveManifest()
 	}
-}
 
-// checkResume 检查断点续传
-func (d *ChunkDownloader) checkResume() {
+	d.manifest = m
+	for _, mc := range m.Chunks {
+		d.Chunks = append(d.Chunks, &ChunkInfo{
+			Index: mc.Index,
+			Start: mc.Start,
+			End:   mc.End,
+			Size:  mc.End - mc.Start + 1,
+		})
+		d.nextOffset = mc.End + 1
+	}
 	for _, chunk := range d.Chunks {
+		mc := &m.Chunks[chunk.Index]
 		chunkPath := d.getChunkPath(chunk.Index)
-		if info, err := os.Stat(chunkPath); err == nil {
-			// 文件存在，检查大小
-			if info.Size() == chunk.Size {
+		switch {
+		case mc.MD5 != "":
+			if sum, err := d.chunkMD5(chunk); err == nil && sum == mc.MD5 {
 				chunk.Completed = true
 				chunk.Downloaded = chunk.Size
 				d.updateProgress(chunk.Size)
 				logger.Infof("分片 %d 已完成，跳过下载", chunk.Index)
-			} else if info.Size() > 0 {
-				// 部分下载，记录已下载大小
-				chunk.Downloaded = info.Size()
-				d.updateProgress(info.Size())
-				logger.Infof("分片 %d 已下载 %.2f%%", chunk.Index, float64(info.Size())/float64(chunk.Size)*100)
+				continue
+			}
+			logger.Infof("分片 %d 校验失败，重新下载", chunk.Index)
+		case mc.Downloaded > 0 && mc.Downloaded < chunk.Size:
+			// 预分配文件中清单记录之后的数据会被重新写入覆盖，缓存文件则需要截断多出的部分
+			if d.usePartFile || os.Truncate(chunkPath, mc.Downloaded) == nil {
+				chunk.Downloaded = mc.Downloaded
+				d.updateProgress(mc.Downloaded)
+				logger.Infof("分片 %d 已下载 %.2f%%", chunk.Index, float64(mc.Downloaded)/float64(chunk.Size)*100)
+				continue
 			}
 		}
+		mc.Downloaded = 0
+		mc.MD5 = ""
+		os.Remove(chunkPath)
+	}
+	return d.saveManifest()
+}
+
+// completeChunk 记录分片完成和分片 md5
+func (d *ChunkDownloader) completeChunk(chunk *ChunkInfo) error {
+	sum, err := d.chunkMD5(chunk)
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	chunk.Completed = true
+	d.manifest.Chunks[chunk.Index].MD5 = sum
+	d.mu.Unlock()
+	return d.saveManifest()
+}
+
+// chunkMD5 计算分片已下载数据的 md5，数据不足分片大小时返回错误
+func (d *ChunkDownloader) chunkMD5(chunk *ChunkInfo) (string, error) {
+	if d.usePartFile {
+		return sectionMD5(d.part, chunk.Start, chunk.Size)
 	}
+	return fileMD5(d.getChunkPath(chunk.Index), chunk.Size)
+}
+
+// nextChunk 从未切分的部分切出一个新分片，全部切分完时返回 nil
+//
+// 接近结尾时缩小分片，使剩余部分仍能分给所有并发下载；剩余部分不足半个分片时并入当前分片
+func (d *ChunkDownloader) nextChunk() *ChunkInfo {
+	if d.nextOffset >= d.TotalSize {
+		return nil
+	}
+	start := d.nextOffset
+	size := d.ChunkSize
+	if !d.fixedChunkSize {
+		size = min(size, max((d.TotalSize-start)/int64(2*d.Concurrency), MinChunkSize))
+	}
+	end := start + size - 1
+	if end >= d.TotalSize || d.TotalSize-1-end < size/2 {
+		end = d.TotalSize - 1
+	}
+	d.mu.Lock()
+	chunk := &ChunkInfo{
+		Index: len(d.Chunks),
+		Start: start,
+		End:   end,
+		Size:  end - start + 1,
+	}
+	d.Chunks = append(d.Chunks, chunk)
+	d.manifest.Chunks = append(d.manifest.Chunks, ManifestChunk{Index: chunk.Index, Start: start, End: end})
+	d.mu.Unlock()
+	d.nextOffset = end + 1
+	return chunk
+}
+
+// saveManifest 将分片进度写入清单
+func (d *ChunkDownloader) saveManifest() error {
+	d.manifestMu.Lock()
+	defer d.manifestMu.Unlock()
+	d.mu.Lock()
+	for _, chunk := range d.Chunks {
+		d.manifest.Chunks[chunk.Index].Downloaded = chunk.Downloaded
+	}
+	m := *d.manifest
+	m.Chunks = append([]ManifestChunk(nil), d.manifest.Chunks...)
+	d.mu.Unlock()
+	return m.save(d.manifestPath())
+}
+
+// manifestPath 分片清单路径，预分配文件模式下为 <目标文件>.bdpan-part.json
+func (d *ChunkDownloader) manifestPath() string {
+	if d.usePartFile {
+		_, manifest := PartFiles(d.OutputPath)
+		return manifest
+	}
+	return filepath.Join(d.CacheDir, manifestFile)
 }
 
 // downloadChunks 并发下载所有未完成的分片
+//
+// 实现逻辑：
+//
+// 1. 先下载续传恢复的未完成分片，之后按当前分片大小从剩余部分切分新分片
+// 2. 正在下载的分片数少于当前并发数时启动新的分片，并发数减少时等正在下载的分片结束后自然生效
+// 3. 未固定并发数或分片大小时，每隔一段时间根据下载速度调整
+// 4. 任意分片重试后仍失败时不再启动新的分片，等正在下载的分片结束后返回第一个错误
 func (d *ChunkDownloader) downloadChunks() error {
-	var wg sync.WaitGroup
-	sem := make(chan struct{}, d.Concurrency) // 限制并发数
-	errChan := make(chan error, len(d.Chunks))
-
+	var pending []*ChunkInfo
 	for _, chunk := range d.Chunks {
-		if chunk.Completed {
-			continue
+		if !chunk.Completed {
+			pending = append(pending, chunk)
 		}
+	}
 
-		wg.Add(1)
-		go func(c *ChunkInfo) {
-			defer wg.Done()
-			sem <- struct{}{}        // 获取信号量
-			defer func() { <-sem }() // 释放信号量
+	d.mu.Lock()
+	d.tuner = newTuner(d.downloadedSum)
+	d.mu.Unlock()
+	tuneTicker := time.NewTicker(tuneInterval)
+	defer tuneTicker.Stop()
+
+	// 定期保存分片进度，进程意外退出时最多重新下载几秒的数据
+	saveQuit := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(2 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-saveQuit:
+				return
+			case <-ticker.C:
+				if err := d.saveManifest(); err != nil {
+					logger.Errorf("保存分片清单失败: %v", err)
+				}
+			}
+		}
+	}()
 
-			if err := d.downloadChunk(c); err != nil {
-				errChan <- fmt.Errorf("分片 %d 下载失败: %w", c.Index, err)
+	results := make(chan error)
+	active := 0
+	var firstErr error
+	for {
+		for firstErr == nil && d.ctx.Err() == nil && active < d.Concurrency {
+			var chunk *ChunkInfo
+			if len(pending) > 0 {
+				chunk, pending = pending[0], pending[1:]
+			} else if chunk = d.nextChunk(); chunk == nil {
+				break
+			}
+			active++
+			go func(c *ChunkInfo) {
+				err := d.withRetry(fmt.Sprintf("分片 %d", c.Index), func(url string) error {
+					return d.downloadChunk(c, url)
+				})
+				if err != nil {
+					err = fmt.Errorf("分片 %d 下载失败: %w", c.Index, err)
+				}
+				results <- err
+			}(chunk)
+		}
+		if active == 0 {
+			break
+		}
+		select {
+		case err := <-results:
+			active--
+			if err != nil && firstErr == nil {
+				firstErr = err
 			}
-		}(chunk)
+		case <-tuneTicker.C:
+			d.tune(active)
+		}
 	}
 
-	wg.Wait()
-	close(errChan)
-
-	// 检查是否有错误
-	if len(errChan) > 0 {
-		return <-errChan
+	close(saveQuit)
+	if err := d.saveManifest(); err != nil {
+		logger.Errorf("保存分片清单失败: %v", err)
 	}
-
-	return nil
+	if firstErr == nil && d.ctx.Err() != nil {
+		return d.ctx.Err()
+	}
+	return firstErr
 }
 
-// downloadChunk 下载单个分片
-func (d *ChunkDownloader) downloadChunk(chunk *ChunkInfo) error {
+// downloadChunk 使用 url 下载单个分片，从分片已下载的位置继续
+func (d *ChunkDownloader) downloadChunk(chunk *ChunkInfo, url string) error {
 	chunkPath := d.getChunkPath(chunk.Index)
 	
 	// 计算实际的下载范围（考虑已下载部分）
@@ -361,20 +630,16 @@ func (d *ChunkDownloader) downloadChunk(chunk *ChunkInfo) error {
 
 	// 如果已经下载完成，跳过
 	if start > end {
-		chunk.Completed = true
-		return nil
+		return d.completeChunk(chunk)
 	}
 
 	// 创建请求
-	req, err := http.NewRequestWithContext(d.ctx, "GET", d.URL, nil)
+	req, err := http.NewRequestWithContext(d.ctx, "GET", url, nil)
 	if err != nil {
 		return err
 	}
 	req.Header.Set("User-Agent", userAgent)
 	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
-	// 使用短连接
-	req.Close = true
-	req.Header.Set("Connection", "close")
 
 	// 发起请求
 	resp, err := d.client.Do(req)
@@ -384,17 +649,28 @@ func (d *ChunkDownloader) downloadChunk(chunk *ChunkInfo) error {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusPartialContent && resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("HTTP status: %d", resp.StatusCode)
+		return &StatusError{StatusCode: resp.StatusCode}
+	}
+	// 200 返回的是整个文件，只有请求范围恰好是整个文件时才能直接写入
+	if resp.StatusCode == http.StatusOK && (start != 0 || end != d.TotalSize-1) {
+		return fmt.Errorf("服务端未按 Range 返回数据: bytes=%d-%d", start, end)
 	}
 
-	// 打开文件（追加模式）
-	file, err := os.OpenFile(chunkPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-	if err != nil {
-		return err
+	// 预分配文件模式写入分片对应的区间，否则追加写入分片文件
+	var file io.Writer
+	if d.usePartFile {
+		file = io.NewOffsetWriter(d.part, start)
+	} else {
+		f, err := os.OpenFile(chunkPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		file = f
 	}
-	defer file.Close()
 
-	// 写入数据并更新进度
+	// 写入数据并更新进度，多出请求范围的数据不写入，避免覆盖后面的分片
+	body := io.LimitReader(resp.Body, end-start+1)
 	buf := make([]byte, 32*1024) // 32KB 缓冲区
 	for {
 		select {
@@ -403,13 +679,18 @@ func (d *ChunkDownloader) downloadChunk(chunk *ChunkInfo) error {
 		default:
 		}
 
-		n, err := resp.Body.Read(buf)
+		n, err := body.Read(buf)
 		if n > 0 {
 			if _, writeErr := file.Write(buf[:n]); writeErr != nil {
 				return writeErr
 			}
+			d.mu.Lock()
 			chunk.Downloaded += int64(n)
+			d.mu.Unlock()
 			d.updateProgress(int64(n))
+			if waitErr := d.limiter.WaitN(d.ctx, n); waitErr != nil {
+				return waitErr
+			}
 		}
 		if err == io.EOF {
 			break
@@ -419,20 +700,20 @@ func (d *ChunkDownloader) downloadChunk(chunk *ChunkInfo) error {
 		}
 	}
 
-	chunk.Completed = true
-	return nil
+	// 连接提前结束时返回的数据不足，重试时从已下载的位置继续
+	if chunk.Downloaded < chunk.Size {
+		return fmt.Errorf("分片数据不完整，已下载 %d/%d: %w", chunk.Downloaded, chunk.Size, io.ErrUnexpectedEOF)
+	}
+	return d.completeChunk(chunk)
 }
 
-// downloadDirect 直接下载（不分片）
-func (d *ChunkDownloader) downloadDirect() error {
-	req, err := http.NewRequestWithContext(d.ctx, "GET", d.URL, nil)
+// downloadDirect 使用 url 直接下载（不分片），written 记录本次写入的字节数
+func (d *ChunkDownloader) downloadDirect(url string, written *int64) error {
+	req, err := http.NewRequestWithContext(d.ctx, "GET", url, nil)
 	if err != nil {
 		return err
 	}
 	req.Header.Set("User-Agent", userAgent)
-	// 使用短连接
-	req.Close = true
-	req.Header.Set("Connection", "close")
 
 	resp, err := d.client.Do(req)
 	if err != nil {
@@ -441,7 +722,7 @@ func (d *ChunkDownloader) downloadDirect() error {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("HTTP status: %d", resp.StatusCode)
+		return &StatusError{StatusCode: resp.StatusCode}
 	}
 
 	// 确保输出目录存在
@@ -449,7 +730,12 @@ func (d *ChunkDownloader) downloadDirect() error {
 		return err
 	}
 
-	file, err := os.Create(d.OutputPath)
+	// 预分配文件模式先写入 .bdpan-part，下载完成后重命名
+	outputPath := d.OutputPath
+	if d.usePartFile {
+		outputPath = d.partPath()
+	}
+	file, err := os.Create(outputPath)
 	if err != nil {
 		return err
 	}
@@ -469,7 +755,11 @@ func (d *ChunkDownloader) downloadDirect() error {
 			if _, writeErr := file.Write(buf[:n]); writeErr != nil {
 				return writeErr
 			}
+			*written += int64(n)
 			d.updateProgress(int64(n))
+			if waitErr := d.limiter.WaitN(d.ctx, n); waitErr != nil {
+				return waitErr
+			}
 		}
 		if err == io.EOF {
 			break
@@ -482,6 +772,94 @@ func (d *ChunkDownloader) downloadDirect() error {
 	return nil
 }
 
+// withRetry 执行 fn，失败时按指数退避重试，最多重试 d.MaxRetries 次
+//
+// 实现逻辑：
+//
+// 1. 每次执行前读取当前的下载链接，其他分片刷新后的链接对重试立即生效
+// 2. 上下文取消、本地文件错误、Stream 写入输出失败和 403 以外的 4xx 直接返回，不重试
+// 3. 下载链接过期时调用 refreshURL 刷新后立即重试，多个分片同时过期只刷新一次
+// 4. 其他错误等待 1s、2s、4s... 后重试，最长等待 30s
+func (d *ChunkDownloader) withRetry(name string, fn func(url string) error) error {
+	for attempt := 0; ; attempt++ {
+		url := d.currentURL()
+		err := fn(url)
+		if err == nil {
+			return nil
+		}
+		if d.ctx.Err() != nil {
+			return d.ctx.Err()
+		}
+		if attempt >= d.MaxRetries || !d.retryable(err) {
+			return err
+		}
+		if isLinkExpired(err) {
+			logger.Infof("%s 下载链接已过期，刷新后重试: %v", name, err)
+			if rerr := d.refreshLink(url); rerr != nil {
+				return fmt.Errorf("刷新下载链接失败: %w", rerr)
+			}
+			continue
+		}
+		d.tuner.noteError()
+		delay := min(retryBaseDelay<<attempt, retryMaxDelay)
+		logger.Infof("%s 失败，%v 后第 %d 次重试: %v", name, delay, attempt+1, err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-d.ctx.Done():
+			timer.Stop()
+			return d.ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// retryable 判断错误是否可以重试
+func (d *ChunkDownloader) retryable(err error) bool {
+	var pathErr *os.PathError
+	var writeErr *writeError
+	if errors.As(err, &pathErr) || errors.As(err, &writeErr) {
+		return false
+	}
+	var se *StatusError
+	if errors.As(err, &se) {
+		switch {
+		case isLinkExpired(err):
+			return d.refreshURL != nil
+		case se.StatusCode == http.StatusRequestTimeout, se.StatusCode == http.StatusTooManyRequests:
+			return true
+		default:
+			return se.StatusCode >= 500
+		}
+	}
+	return true
+}
+
+// currentURL 返回当前的下载链接
+func (d *ChunkDownloader) currentURL() string {
+	d.urlMu.Lock()
+	defer d.urlMu.Unlock()
+	return d.URL
+}
+
+// refreshLink 刷新下载链接，staleURL 已被其他分片刷新时直接返回
+func (d *ChunkDownloader) refreshLink(staleURL string) error {
+	d.urlMu.Lock()
+	defer d.urlMu.Unlock()
+	if d.URL != staleURL {
+		return nil
+	}
+	url, err := d.refreshURL()
+	if err != nil {
+		return err
+	}
+	if url == "" {
+		return errors.New("未获取到新的下载链接")
+	}
+	d.URL = url
+	logger.Infof("下载链接已刷新")
+	return nil
+}
+
 // mergeChunks 合并所有分片到目标文件
 func (d *ChunkDownloader) mergeChunks() error {
 	// 确保输出目录存在
@@ -547,10 +925,11 @@ func (d *ChunkDownloader) updateProgress(delta int64) {
 	downloaded := d.downloadedSum
 	d.mu.Unlock()
 
-	// 优先使用 TUI 进度条
+	// TUI 进度条与进度回调同时生效，回调用于任务心跳和非终端进度输出
 	if d.progressWriter != nil {
 		d.progressWriter.UpdateProgress(downloaded, d.TotalSize)
-	} else if d.ProgressFunc != nil {
+	}
+	if d.ProgressFunc != nil {
 		d.ProgressFunc(downloaded, d.TotalSize)
 	}
 }
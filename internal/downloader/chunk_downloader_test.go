@@ -0,0 +1,553 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestDownloader 创建输出和缓存目录都在临时目录中的下载器，不限速
+func newTestDownloader(t *testing.T, url string) *ChunkDownloader {
+	t.Helper()
+	dir := t.TempDir()
+	d := NewChunkDownloader(url, filepath.Join(dir, "out"), filepath.Join(dir, "cache"))
+	d.SetLimiter(nil)
+	return d
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		refresh bool
+		want    bool
+	}{
+		{"网络错误", errors.New("connection reset"), false, true},
+		{"本地文件错误", &os.PathError{Op: "open", Path: "x", Err: os.ErrPermission}, false, false},
+		{"写入输出失败", &writeError{err: errors.New("broken pipe")}, false, false},
+		{"包装的本地文件错误", fmt.Errorf("分片 0: %w", &os.PathError{Op: "write", Path: "x", Err: os.ErrClosed}), false, false},
+		{"403 无刷新函数", &StatusError{StatusCode: http.StatusForbidden}, false, false},
+		{"403 有刷新函数", &StatusError{StatusCode: http.StatusForbidden}, true, true},
+		{"410 有刷新函数", &StatusError{StatusCode: http.StatusGone}, true, true},
+		{"404", &StatusError{StatusCode: http.StatusNotFound}, true, false},
+		{"408", &StatusError{StatusCode: http.StatusRequestTimeout}, false, true},
+		{"429", &StatusError{StatusCode: http.StatusTooManyRequests}, false, true},
+		{"500", &StatusError{StatusCode: http.StatusInternalServerError}, false, true},
+		{"503", &StatusError{StatusCode: http.StatusServiceUnavailable}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newTestDownloader(t, "http://example.com/file")
+			if tt.refresh {
+				d.SetURLRefresher(func() (string, error) { return "http://example.com/new", nil })
+			}
+			if got := d.retryable(tt.err); got != tt.want {
+				t.Errorf("retryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	const (
+		oldURL = "http://example.com/file?sign=old"
+		newURL = "http://example.com/file?sign=new"
+	)
+	refreshErr := errors.New("获取链接失败")
+	tests := []struct {
+		name        string
+		errs        []error // 每次执行 fn 返回的错误，用完后返回 nil
+		maxRetries  int
+		refresher   func() (string, error)
+		wantURLs    []string // 每次执行 fn 使用的链接
+		wantErr     error
+		wantRefresh int
+	}{
+		{
+			name:       "成功不重试",
+			maxRetries: 3,
+			wantURLs:   []string{oldURL},
+		},
+		{
+			name:        "链接过期刷新后重试",
+			errs:        []error{&StatusError{StatusCode: http.StatusForbidden}},
+			maxRetries:  3,
+			refresher:   func() (string, error) { return newURL, nil },
+			wantURLs:    []string{oldURL, newURL},
+			wantRefresh: 1,
+		},
+		{
+			name:       "链接过期无刷新函数",
+			errs:       []error{&StatusError{StatusCode: http.StatusForbidden}},
+			maxRetries: 3,
+			wantURLs:   []string{oldURL},
+			wantErr:    &StatusError{StatusCode: http.StatusForbidden},
+		},
+		{
+			name:        "刷新链接失败",
+			errs:        []error{&StatusError{StatusCode: http.StatusGone}},
+			maxRetries:  3,
+			refresher:   func() (string, error) { return "", refreshErr },
+			wantURLs:    []string{oldURL},
+			wantErr:     refreshErr,
+			wantRefresh: 1,
+		},
+		{
+			name:        "刷新返回空链接",
+			errs:        []error{&StatusError{StatusCode: http.StatusForbidden}},
+			maxRetries:  3,
+			refresher:   func() (string, error) { return "", nil },
+			wantURLs:    []string{oldURL},
+			wantErr:     errors.New("刷新下载链接失败: 未获取到新的下载链接"),
+			wantRefresh: 1,
+		},
+		{
+			name:       "5xx 退避后重试",
+			errs:       []error{&StatusError{StatusCode: http.StatusBadGateway}},
+			maxRetries: 3,
+			wantURLs:   []string{oldURL, oldURL},
+		},
+		{
+			name:       "4xx 不重试",
+			errs:       []error{&StatusError{StatusCode: http.StatusNotFound}},
+			maxRetries: 3,
+			wantURLs:   []string{oldURL},
+			wantErr:    &StatusError{StatusCode: http.StatusNotFound},
+		},
+		{
+			name:       "本地文件错误不重试",
+			errs:       []error{&os.PathError{Op: "open", Path: "x", Err: os.ErrPermission}},
+			maxRetries: 3,
+			wantURLs:   []string{oldURL},
+			wantErr:    os.ErrPermission,
+		},
+		{
+			name:       "重试次数用完",
+			errs:       []error{&StatusError{StatusCode: http.StatusServiceUnavailable}},
+			maxRetries: 0,
+			wantURLs:   []string{oldURL},
+			wantErr:    &StatusError{StatusCode: http.StatusServiceUnavailable},
+		},
+		{
+			name: "链接过期重试同样计入次数",
+			errs: []error{
+				&StatusError{StatusCode: http.StatusForbidden},
+				&StatusError{StatusCode: http.StatusForbidden},
+			},
+			maxRetries:  1,
+			refresher:   func() (string, error) { return newURL, nil },
+			wantURLs:    []string{oldURL, newURL},
+			wantErr:     &StatusError{StatusCode: http.StatusForbidden},
+			wantRefresh: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newTestDownloader(t, oldURL).SetMaxRetries(tt.maxRetries)
+			d.tuner = newTuner(0)
+			refreshed := 0
+			if tt.refresher != nil {
+				d.SetURLRefresher(func() (string, error) {
+					refreshed++
+					return tt.refresher()
+				})
+			}
+			var urls []string
+			err := d.withRetry("测试", func(url string) error {
+				urls = append(urls, url)
+				if len(urls) <= len(tt.errs) {
+					return tt.errs[len(urls)-1]
+				}
+				return nil
+			})
+			if !sameError(err, tt.wantErr) {
+				t.Errorf("withRetry() error = %v, want %v", err, tt.wantErr)
+			}
+			if !slices.Equal(urls, tt.wantURLs) {
+				t.Errorf("urls = %v, want %v", urls, tt.wantURLs)
+			}
+			if refreshed != tt.wantRefresh {
+				t.Errorf("refreshed = %d, want %d", refreshed, tt.wantRefresh)
+			}
+		})
+	}
+}
+
+// sameError 判断 err 是否为 want：StatusError 比较状态码，其他错误先用 errors.Is 再比较错误信息
+func sameError(err, want error) bool {
+	if err == nil || want == nil {
+		return err == want
+	}
+	var se, wantSE *StatusError
+	if errors.As(want, &wantSE) {
+		return errors.As(err, &se) && se.StatusCode == wantSE.StatusCode
+	}
+	return errors.Is(err, want) || err.Error() == want.Error()
+}
+
+func TestWithRetry_Canceled(t *testing.T) {
+	d := newTestDownloader(t, "http://example.com/file")
+	d.tuner = newTuner(0)
+	calls := 0
+	time.AfterFunc(100*time.Millisecond, d.Cancel)
+	start := time.Now()
+	err := d.withRetry("测试", func(string) error {
+		calls++
+		return &StatusError{StatusCode: http.StatusServiceUnavailable}
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("withRetry() error = %v, want context.Canceled", err)
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+	if elapsed := time.Since(start); elapsed >= retryBaseDelay {
+		t.Errorf("取消后仍等待了 %v", elapsed)
+	}
+}
+
+func TestRefreshLink_Stale(t *testing.T) {
+	d := newTestDownloader(t, "http://example.com/file?sign=old")
+	refreshed := 0
+	d.SetURLRefresher(func() (string, error) {
+		refreshed++
+		return fmt.Sprintf("http://example.com/file?sign=%d", refreshed), nil
+	})
+	// 两个分片使用同一个过期链接，第二个分片刷新时链接已经更新，不再重复刷新
+	for range 2 {
+		if err := d.refreshLink("http://example.com/file?sign=old"); err != nil {
+			t.Fatalf("refreshLink() error = %v", err)
+		}
+	}
+	if refreshed != 1 {
+		t.Errorf("refreshed = %d, want 1", refreshed)
+	}
+	if got, want := d.currentURL(), "http://example.com/file?sign=1"; got != want {
+		t.Errorf("currentURL() = %q, want %q", got, want)
+	}
+}
+
+func TestManifest_Matches(t *testing.T) {
+	const identity = "http://example.com/file"
+	chunks := func(cs ...ManifestChunk) []ManifestChunk { return cs }
+	tests := []struct {
+		name      string
+		m         Manifest
+		totalSize int64
+		want      bool
+	}{
+		{
+			name:      "未切分分片",
+			m:         Manifest{Version: manifestVersion, Identity: identity, TotalSize: 100},
+			totalSize: 100,
+			want:      true,
+		},
+		{
+			name: "连续分片",
+			m: Manifest{Version: manifestVersion, Identity: identity, TotalSize: 100, Chunks: chunks(
+				ManifestChunk{Index: 0, Start: 0, End: 39},
+				ManifestChunk{Index: 1, Start: 40, End: 99},
+			)},
+			totalSize: 100,
+			want:      true,
+		},
+		{
+			name: "只切分了一部分",
+			m: Manifest{Version: manifestVersion, Identity: identity, TotalSize: 100, Chunks: chunks(
+				ManifestChunk{Index: 0, Start: 0, End: 39},
+			)},
+			totalSize: 100,
+			want:      true,
+		},
+		{
+			name:      "版本不一致",
+			m:         Manifest{Version: manifestVersion - 1, Identity: identity, TotalSize: 100},
+			totalSize: 100,
+		},
+		{
+			name:      "标识不一致",
+			m:         Manifest{Version: manifestVersion, Identity: "http://example.com/other", TotalSize: 100},
+			totalSize: 100,
+		},
+		{
+			name:      "文件大小变化",
+			m:         Manifest{Version: manifestVersion, Identity: identity, TotalSize: 100},
+			totalSize: 120,
+		},
+		{
+			name: "第一个分片不从 0 开始",
+			m: Manifest{Version: manifestVersion, Identity: identity, TotalSize: 100, Chunks: chunks(
+				ManifestChunk{Index: 0, Start: 10, End: 39},
+			)},
+			totalSize: 100,
+		},
+		{
+			name: "分片之间有空隙",
+			m: Manifest{Version: manifestVersion, Identity: identity, TotalSize: 100, Chunks: chunks(
+				ManifestChunk{Index: 0, Start: 0, End: 39},
+				ManifestChunk{Index: 1, Start: 50, End: 99},
+			)},
+			totalSize: 100,
+		},
+		{
+			name: "分片重叠",
+			m: Manifest{Version: manifestVersion, Identity: identity, TotalSize: 100, Chunks: chunks(
+				ManifestChunk{Index: 0, Start: 0, End: 39},
+				ManifestChunk{Index: 1, Start: 30, End: 99},
+			)},
+			totalSize: 100,
+		},
+		{
+			name: "索引不连续",
+			m: Manifest{Version: manifestVersion, Identity: identity, TotalSize: 100, Chunks: chunks(
+				ManifestChunk{Index: 0, Start: 0, End: 39},
+				ManifestChunk{Index: 2, Start: 40, End: 99},
+			)},
+			totalSize: 100,
+		},
+		{
+			name: "结束位置小于起始位置",
+			m: Manifest{Version: manifestVersion, Identity: identity, TotalSize: 100, Chunks: chunks(
+				ManifestChunk{Index: 0, Start: 0, End: -1},
+			)},
+			totalSize: 100,
+		},
+		{
+			name: "超出文件大小",
+			m: Manifest{Version: manifestVersion, Identity: identity, TotalSize: 100, Chunks: chunks(
+				ManifestChunk{Index: 0, Start: 0, End: 100},
+			)},
+			totalSize: 100,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.m.matches(identity, tt.totalSize); got != tt.want {
+				t.Errorf("matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// rangeServer 支持 Range 请求的测试服务，fault 返回 true 时表示已经写入了模拟的异常响应
+type rangeServer struct {
+	*httptest.Server
+	data   []byte
+	fault  func(w http.ResponseWriter, r *http.Request, n int) bool
+	mu     sync.Mutex
+	gets   int      // 收到的 GET 请求数
+	ranges []string // 正常响应的 GET 请求的 Range
+}
+
+func newRangeServer(t *testing.T, data []byte, fault func(w http.ResponseWriter, r *http.Request, n int) bool) *rangeServer {
+	s := &rangeServer{data: data, fault: fault}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *rangeServer) serve(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		s.mu.Lock()
+		s.gets++
+		n := s.gets
+		s.mu.Unlock()
+		if s.fault != nil && s.fault(w, r, n) {
+			return
+		}
+		s.mu.Lock()
+		s.ranges = append(s.ranges, r.Header.Get("Range"))
+		s.mu.Unlock()
+	}
+	http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(s.data))
+}
+
+// parseRange 解析 bytes=start-end 格式的 Range
+func parseRange(r *http.Request) (start, end int64) {
+	fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end)
+	return start, end
+}
+
+func TestChunkDownloader_Start(t *testing.T) {
+	data := make([]byte, 3*MinChunkSize+12345)
+	for i := range data {
+		data[i] = byte(i*7 + i>>11)
+	}
+
+	var resumeRange atomic.Value // 数据不足的分片重试时应该请求的 Range
+	tests := []struct {
+		name        string
+		fault       func(w http.ResponseWriter, r *http.Request, n int) bool
+		maxRetries  int
+		wantErr     bool
+		wantRefresh int
+		wantRange   func() string // 必须出现的 Range 请求
+	}{
+		{
+			name:       "正常下载",
+			maxRetries: 3,
+		},
+		{
+			name: "下载链接过期",
+			fault: func(w http.ResponseWriter, r *http.Request, n int) bool {
+				if r.URL.Query().Get("sign") == "old" {
+					w.WriteHeader(http.StatusForbidden)
+					return true
+				}
+				return false
+			},
+			maxRetries:  3,
+			wantRefresh: 1,
+		},
+		{
+			name: "5xx 后重试",
+			fault: func(w http.ResponseWriter, r *http.Request, n int) bool {
+				if n == 1 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return true
+				}
+				return false
+			},
+			maxRetries: 3,
+		},
+		{
+			name: "Range 返回数据不足",
+			fault: func(w http.ResponseWriter, r *http.Request, n int) bool {
+				if n != 1 {
+					return false
+				}
+				start, end := parseRange(r)
+				half := (end - start + 1) / 2
+				resumeRange.Store(fmt.Sprintf("bytes=%d-%d", start+half, end))
+				w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, start+half-1, len(data)))
+				w.Header().Set("Content-Length", strconv.FormatInt(half, 10))
+				w.WriteHeader(http.StatusPartialContent)
+				w.Write(data[start : start+half])
+				return true
+			},
+			maxRetries: 3,
+			wantRange:  func() string { return resumeRange.Load().(string) },
+		},
+		{
+			name: "忽略 Range 返回整个文件",
+			fault: func(w http.ResponseWriter, r *http.Request, n int) bool {
+				w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+				w.WriteHeader(http.StatusOK)
+				w.Write(data)
+				return true
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		for _, partFile := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/part=%v", tt.name, partFile), func(t *testing.T) {
+				srv := newRangeServer(t, data, tt.fault)
+				d := newTestDownloader(t, srv.URL+"/file?sign=old").
+					SetChunkSize(MinChunkSize).
+					SetConcurrency(2).
+					SetMaxRetries(tt.maxRetries)
+				if partFile {
+					d.EnablePartFile()
+				}
+				var refreshed atomic.Int32
+				d.SetURLRefresher(func() (string, error) {
+					refreshed.Add(1)
+					return srv.URL + "/file?sign=new", nil
+				})
+
+				err := d.Start()
+				if tt.wantErr {
+					if err == nil {
+						t.Fatalf("Start() error = nil, want error")
+					}
+					if _, statErr := os.Stat(d.OutputPath); statErr == nil {
+						t.Errorf("下载失败时不应生成目标文件")
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("Start() error = %v", err)
+				}
+				got, err := os.ReadFile(d.OutputPath)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, data) {
+					t.Errorf("下载内容不一致，长度 %d，want %d", len(got), len(data))
+				}
+				if n := int(refreshed.Load()); n != tt.wantRefresh {
+					t.Errorf("refreshed = %d, want %d", n, tt.wantRefresh)
+				}
+				if tt.wantRange != nil {
+					want := tt.wantRange()
+					srv.mu.Lock()
+					ranges := slices.Clone(srv.ranges)
+					srv.mu.Unlock()
+					if !slices.Contains(ranges, want) {
+						t.Errorf("未从已下载的位置续传，Range 请求 %v，want %s", ranges, want)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestChunkDownloader_StartRemovesLegacyCache(t *testing.T) {
+	data := make([]byte, 2*MinChunkSize)
+	tests := []struct {
+		name      string
+		files     []string // 缓存目录中已有的文件
+		wantFiles []string // 下载后缓存目录中剩余的文件，nil 表示目录已删除
+	}{
+		{"只有分片缓存", []string{"chunk_0", "chunk_1", manifestFile}, nil},
+		{"保留预览文件", []string{"chunk_0", manifestFile, "preview.txt"}, []string{"preview.txt"}},
+		{"没有分片缓存", []string{"preview.txt"}, []string{"preview.txt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newRangeServer(t, data, nil)
+			d := newTestDownloader(t, srv.URL+"/file").SetChunkSize(MinChunkSize).EnablePartFile()
+			if err := os.MkdirAll(d.CacheDir, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range tt.files {
+				if err := os.WriteFile(filepath.Join(d.CacheDir, name), []byte("x"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := d.Start(); err != nil {
+				t.Fatalf("Start() error = %v", err)
+			}
+			entries, err := os.ReadDir(d.CacheDir)
+			if tt.wantFiles == nil {
+				if !os.IsNotExist(err) {
+					t.Errorf("缓存目录未删除，ReadDir() error = %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, e := range entries {
+				got = append(got, e.Name())
+			}
+			if !slices.Equal(got, tt.wantFiles) {
+				t.Errorf("缓存目录剩余 %v, want %v", got, tt.wantFiles)
+			}
+		})
+	}
+}
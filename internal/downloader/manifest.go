@@ -0,0 +1,128 @@
+package downloader
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// manifestFile 分片清单文件名，保存在缓存目录中
+	manifestFile = "manifest.json"
+
+	// manifestVersion 清单格式版本，格式变化时旧的缓存直接丢弃
+	manifestVersion = 2
+)
+
+// Manifest 分片下载清单，记录缓存目录中每个分片的可信进度
+//
+// 实现细节：
+// - 清单通过写临时文件再重命名的方式保存，进程崩溃时不会留下半个清单
+// - Downloaded 只记录已经写入分片文件的长度，续传时分片文件多出的部分会被截断
+// - 分片完成时记录分片文件的 md5，续传时校验失败的分片重新下载
+// - 分片大小在下载过程中会动态调整，每个分片记录自己的区间，分片从文件开头连续切分
+type Manifest struct {
+	Version   int             `json:"version"`
+	Identity  string          `json:"identity"`   // 下载内容标识，默认为去掉参数的 URL
+	TotalSize int64           `json:"total_size"` // 文件总大小
+	Chunks    []ManifestChunk `json:"chunks"`     // 已切分的分片
+}
+
+// ManifestChunk 单个分片的进度
+type ManifestChunk struct {
+	Index      int    `json:"index"`
+	Start      int64  `json:"start"`
+	End        int64  `json:"end"`
+	Downloaded int64  `json:"downloaded"`    // 已写入分片文件的长度
+	MD5        string `json:"md5,omitempty"` // 分片完成后的 md5
+}
+
+// matches 判断清单是否与本次下载一致，并且分片从文件开头连续切分
+func (m *Manifest) matches(identity string, totalSize int64) bool {
+	if m.Version != manifestVersion || m.Identity != identity || m.TotalSize != totalSize {
+		return false
+	}
+	var next int64
+	for i, c := range m.Chunks {
+		if c.Index != i || c.Start != next || c.End < c.Start || c.End >= totalSize {
+			return false
+		}
+		next = c.End + 1
+	}
+	return true
+}
+
+// loadManifest 读取清单，不存在时返回 nil
+func loadManifest(path string) (*Manifest, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("解析分片清单失败: %w", err)
+	}
+	return &m, nil
+}
+
+// save 原子地保存清单
+func (m *Manifest) save(path string) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), manifestFile+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// urlIdentity 去掉 URL 的参数作为下载内容标识，Dlink 刷新后参数会变化但路径不变
+func urlIdentity(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String()
+}
+
+// fileMD5 计算文件前 size 个字节的 md5
+func fileMD5(path string, size int64) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := md5.New()
+	n, err := io.Copy(h, io.LimitReader(f, size))
+	if err != nil {
+		return "", err
+	}
+	if n != size {
+		return "", fmt.Errorf("文件长度 %d 小于 %d", n, size)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
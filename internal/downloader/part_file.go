@@ -0,0 +1,139 @@
+package downloader
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/wxnacy/bdpan-cli/internal/logger"
+)
+
+const (
+	// PartSuffix 下载中文件的后缀，下载完成后重命名为目标文件
+	PartSuffix = ".bdpan-part"
+)
+
+// 预分配文件模式：分片直接写入 <目标文件>.bdpan-part 的对应位置，不再经过缓存目录合并。
+//
+// - 开始下载时创建与远程文件等大的 .bdpan-part 文件，各分片通过 WriteAt 写入自己的区间
+// - 分片清单保存在 <目标文件>.bdpan-part.json 中，续传时只信任清单记录的进度
+// - 全部分片完成后重命名为目标文件，目标文件名下不会出现写了一半的文件
+// - 相比缓存目录模式少一次合并的读写，也不需要两倍的磁盘空间
+// - CacheDir 只用于清理旧版本缓存目录模式留下的分片文件和清单，这些分片无法转换为 .bdpan-part，重新下载
+
+// EnablePartFile 启用预分配文件模式
+func (d *ChunkDownloader) EnablePartFile() *ChunkDownloader {
+	d.usePartFile = true
+	return d
+}
+
+// PartFiles 目标文件 outputPath 下载中的 .bdpan-part 文件和分片清单的路径
+func PartFiles(outputPath string) (part, manifest string) {
+	part = outputPath + PartSuffix
+	return part, part + ".json"
+}
+
+// partPath 下载中文件的路径
+func (d *ChunkDownloader) partPath() string {
+	part, _ := PartFiles(d.OutputPath)
+	return part
+}
+
+// openPartFile 打开已有的 .bdpan-part 文件，文件大小与远程文件不一致时返回错误
+func (d *ChunkDownloader) openPartFile() error {
+	f, err := os.OpenFile(d.partPath(), os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if info.Size() != d.TotalSize {
+		f.Close()
+		return fmt.Errorf("%s 大小 %d 与远程文件大小 %d 不一致", d.partPath(), info.Size(), d.TotalSize)
+	}
+	d.part = f
+	return nil
+}
+
+// createPartFile 创建 .bdpan-part 文件并预分配为远程文件大小
+func (d *ChunkDownloader) createPartFile() error {
+	d.closePartFile()
+	if err := os.MkdirAll(filepath.Dir(d.OutputPath), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(d.partPath(), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if err := f.Truncate(d.TotalSize); err != nil {
+		f.Close()
+		return err
+	}
+	d.part = f
+	return nil
+}
+
+// removeLegacyCache 删除 CacheDir 中缓存目录模式的分片文件和清单，目录为空时一并删除
+//
+// 缓存目录以文件 md5 命名，可能同时保存了预览文件，因此只删除分片相关的文件
+func (d *ChunkDownloader) removeLegacyCache() {
+	if d.CacheDir == "" {
+		return
+	}
+	paths, _ := filepath.Glob(filepath.Join(d.CacheDir, "chunk_*"))
+	paths = append(paths, filepath.Join(d.CacheDir, manifestFile))
+	removed := false
+	for _, p := range paths {
+		if err := os.Remove(p); err == nil {
+			removed = true
+		} else if !os.IsNotExist(err) {
+			logger.Errorf("删除旧的分片缓存失败: %v", err)
+		}
+	}
+	if removed {
+		os.Remove(d.CacheDir)
+		logger.Infof("已清理旧的分片缓存: %s", d.CacheDir)
+	}
+}
+
+// closePartFile 关闭 .bdpan-part 文件
+func (d *ChunkDownloader) closePartFile() {
+	if d.part != nil {
+		d.part.Close()
+		d.part = nil
+	}
+}
+
+// finalizePartFile 落盘后将 .bdpan-part 重命名为目标文件，并删除分片清单
+func (d *ChunkDownloader) finalizePartFile() error {
+	if err := d.part.Sync(); err != nil {
+		return err
+	}
+	d.closePartFile()
+	if err := os.Rename(d.partPath(), d.OutputPath); err != nil {
+		return err
+	}
+	if err := os.Remove(d.manifestPath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// sectionMD5 计算文件 [off, off+size) 区间的 md5
+func sectionMD5(f *os.File, off, size int64) (string, error) {
+	h := md5.New()
+	n, err := io.Copy(h, io.NewSectionReader(f, off, size))
+	if err != nil {
+		return "", err
+	}
+	if n != size {
+		return "", fmt.Errorf("文件长度 %d 小于 %d", off+n, off+size)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
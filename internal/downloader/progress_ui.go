@@ -155,12 +155,12 @@ func (m ProgressModel) View() string {
 	elapsed := time.Since(m.startTime).Seconds()
 	if elapsed > 0 {
 		speed := float64(m.downloaded) / elapsed
-		speedStr := formatSpeed(int64(speed))
+		speedStr := FormatSpeed(int64(speed))
 		
 		var etaStr string
 		if speed > 0 {
 			remaining := float64(m.totalSize-m.downloaded) / speed
-			etaStr = formatDuration(time.Duration(remaining) * time.Second)
+			etaStr = FormatDuration(time.Duration(remaining) * time.Second)
 		} else {
 			etaStr = "--:--"
 		}
@@ -184,8 +184,8 @@ func (m ProgressModel) View() string {
 	return b.String()
 }
 
-// formatSpeed 格式化速度
-func formatSpeed(bytesPerSecond int64) string {
+// FormatSpeed 格式化速度，如 1.5 MB/s
+func FormatSpeed(bytesPerSecond int64) string {
 	if bytesPerSecond < 1024 {
 		return fmt.Sprintf("%d B/s", bytesPerSecond)
 	} else if bytesPerSecond < 1024*1024 {
@@ -196,8 +196,8 @@ func formatSpeed(bytesPerSecond int64) string {
 	return fmt.Sprintf("%.1f GB/s", float64(bytesPerSecond)/1024/1024/1024)
 }
 
-// formatDuration 格式化时间
-func formatDuration(d time.Duration) string {
+// FormatDuration 格式化时间，超过一小时显示为 hh:mm:ss
+func FormatDuration(d time.Duration) string {
 	d = d.Round(time.Second)
 	h := d / time.Hour
 	d -= h * time.Hour
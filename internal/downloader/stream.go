@@ -0,0 +1,103 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// writeError 写入输出失败，如管道被关闭，不重试
+type writeError struct {
+	err error
+}
+
+func (e *writeError) Error() string { return e.err.Error() }
+
+func (e *writeError) Unwrap() error { return e.err }
+
+// Stream 将远程文件 [offset, offset+length) 区间的内容依次写入 w，length < 0 表示读取到文件末尾
+//
+// 实现逻辑：
+//
+// 1. 通过 Range 请求读取，失败时从已写入的位置继续，重试和下载链接刷新与 Start 相同
+// 2. 服务端忽略 Range 返回 200 时丢弃已写入位置之前的内容
+// 3. 写入 w 失败时直接返回，不重试
+// 4. 读取速度受 SetLimiter 设置的限速器限制，不写入任何本地文件
+func (d *ChunkDownloader) Stream(w io.Writer, offset, length int64) error {
+	if length == 0 {
+		return nil
+	}
+	pos := offset
+	end := int64(-1)
+	if length > 0 {
+		end = offset + length - 1
+	}
+	err := d.withRetry("读取", func(url string) error {
+		if end >= 0 && pos > end {
+			return nil
+		}
+		req, err := http.NewRequestWithContext(d.ctx, "GET", url, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("User-Agent", userAgent)
+		if end >= 0 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", pos, end))
+		} else {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", pos))
+		}
+
+		resp, err := d.client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		var body io.Reader = resp.Body
+		switch resp.StatusCode {
+		case http.StatusPartialContent:
+		case http.StatusOK:
+			if _, err := io.CopyN(io.Discard, resp.Body, pos); err != nil {
+				return err
+			}
+			if end >= 0 {
+				body = io.LimitReader(resp.Body, end-pos+1)
+			}
+		default:
+			return &StatusError{StatusCode: resp.StatusCode}
+		}
+
+		buf := make([]byte, 32*1024)
+		for {
+			select {
+			case <-d.ctx.Done():
+				return d.ctx.Err()
+			default:
+			}
+
+			n, err := body.Read(buf)
+			if n > 0 {
+				if _, writeErr := w.Write(buf[:n]); writeErr != nil {
+					return &writeError{err: writeErr}
+				}
+				pos += int64(n)
+				d.updateProgress(int64(n))
+				if waitErr := d.limiter.WaitN(d.ctx, n); waitErr != nil {
+					return waitErr
+				}
+			}
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+	})
+	var we *writeError
+	if errors.As(err, &we) {
+		return we.err
+	}
+	return err
+}
@@ -0,0 +1,24 @@
+package dto
+
+func NewCacheListReq() *CacheListReq {
+	return &CacheListReq{}
+}
+
+type CacheListReq struct {
+	GlobalReq
+	Kind string
+}
+
+func NewCachePruneReq() *CachePruneReq {
+	return &CachePruneReq{}
+}
+
+type CachePruneReq struct {
+	GlobalReq
+	MaxSize string
+	Kind    string
+	All     bool
+	DryRun  bool
+	// 只清理缓存目录，不包括下载目录中的 .bdpan-part，bdpan clean 使用
+	CacheDirOnly bool
+}
@@ -1,6 +1,9 @@
 package dto
 
-import "github.com/mitchellh/go-homedir"
+import (
+	"github.com/mitchellh/go-homedir"
+	"github.com/wxnacy/bdpan-cli/internal/filter"
+)
 
 func NewDownloadReq() *DownloadReq {
 	dlDir, _ := homedir.Expand("~/Downloads")
@@ -15,6 +18,19 @@ type DownloadReq struct {
 	OutputPath  string
 	IsSync      bool
 	IsRecursion bool
+	IsQueue     bool
+	LimitRate   string
+	Filter      filter.Options
+	// 本地已存在同名文件时的处理策略，为空时文件夹下载跳过，单文件下载重命名
+	OnConflict string
+	// 下载完成后本地文件修改时间的设置方式：none、local、server
+	PreserveTimes string
+	// 下载列表文件，每行一个网盘路径，为 - 时从标准输入读取
+	InputFile string
+	// 进度输出方式：auto、tui、plain、jsonl、none，见 internal/progress
+	Progress string
+	// 无终端模式（daemon 执行），不启用 TUI 进度条，也不监听 Ctrl+C
+	Headless bool
 }
 
 func NewListReq() *ListReq {
@@ -52,8 +68,12 @@ func NewUploadReq() *UploadReq {
 
 type UploadReq struct {
 	GlobalReq
-	Local     string
-	IsRewrite bool
+	Local       string
+	IsRewrite   bool
+	LimitRate   string
+	Concurrency int // 大文件同时上传的分片数，为 0 时读取配置 upload.concurrency
+	Filter      filter.Options
+	Progress    string // 进度输出方式：auto、tui、plain、jsonl、none
 }
 
 func NewBackupReq() *BackupReq {
@@ -62,5 +82,22 @@ func NewBackupReq() *BackupReq {
 
 type BackupReq struct {
 	GlobalReq
-	Local string
+	Local       string
+	LimitRate   string
+	Concurrency int // 大文件同时上传的分片数，为 0 时读取配置 upload.concurrency
+	Filter      filter.Options
+	Progress    string // 进度输出方式：auto、tui、plain、jsonl、none
+}
+
+func NewCatReq() *CatReq {
+	return &CatReq{Length: -1, Lines: 10}
+}
+
+// CatReq 读取远程文件内容到标准输出，cat、head、tail 共用
+type CatReq struct {
+	GlobalReq
+	Offset int64 // 开始读取的位置
+	Length int64 // 读取的字节数，小于 0 表示读取到文件末尾
+	Lines  int   // head、tail 输出的行数
+	Bytes  int64 // head、tail 输出的字节数，大于 0 时忽略 Lines
 }
@@ -0,0 +1,59 @@
+package dto
+
+func NewTaskListReq() *TaskListReq {
+	return &TaskListReq{}
+}
+
+type TaskListReq struct {
+	GlobalReq
+	Status string
+}
+
+func NewTaskReq() *TaskReq {
+	return &TaskReq{}
+}
+
+type TaskReq struct {
+	GlobalReq
+	ID string
+}
+
+func NewTaskPurgeReq() *TaskPurgeReq {
+	return &TaskPurgeReq{}
+}
+
+type TaskPurgeReq struct {
+	GlobalReq
+	Status string
+	Yes    bool
+}
+
+func NewTaskResumeReq() *TaskResumeReq {
+	return &TaskResumeReq{}
+}
+
+type TaskResumeReq struct {
+	GlobalReq
+	ID        string
+	AllFailed bool
+	IsSync    bool
+}
+
+func NewTaskTopReq() *TaskTopReq {
+	return &TaskTopReq{Interval: 1}
+}
+
+type TaskTopReq struct {
+	GlobalReq
+	Interval int
+}
+
+func NewDaemonReq() *DaemonReq {
+	return &DaemonReq{}
+}
+
+type DaemonReq struct {
+	GlobalReq
+	Workers   int
+	LimitRate string
+}
@@ -0,0 +1,324 @@
+package filter
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// IgnoreFile 本地目录中的忽略文件，语法与 .gitignore 相同，只对所在目录及其子目录生效
+const IgnoreFile = ".bdpanignore"
+
+// 百度网盘的文件分类
+const (
+	CategoryVideo   = 1
+	CategoryAudio   = 2
+	CategoryImage   = 3
+	CategoryDoc     = 4
+	CategoryApp     = 5
+	CategoryOther   = 6
+	CategoryTorrent = 7
+)
+
+var categoryNames = map[string]int{
+	"video":   CategoryVideo,
+	"audio":   CategoryAudio,
+	"image":   CategoryImage,
+	"picture": CategoryImage,
+	"doc":     CategoryDoc,
+	"app":     CategoryApp,
+	"other":   CategoryOther,
+	"torrent": CategoryTorrent,
+}
+
+var categoryExts = map[string]int{
+	".mp4": CategoryVideo, ".mkv": CategoryVideo, ".avi": CategoryVideo, ".mov": CategoryVideo,
+	".wmv": CategoryVideo, ".flv": CategoryVideo, ".rmvb": CategoryVideo, ".ts": CategoryVideo, ".webm": CategoryVideo,
+	".mp3": CategoryAudio, ".flac": CategoryAudio, ".wav": CategoryAudio, ".aac": CategoryAudio,
+	".ogg": CategoryAudio, ".m4a": CategoryAudio, ".ape": CategoryAudio,
+	".jpg": CategoryImage, ".jpeg": CategoryImage, ".png": CategoryImage, ".gif": CategoryImage,
+	".bmp": CategoryImage, ".webp": CategoryImage, ".heic": CategoryImage, ".svg": CategoryImage,
+	".txt": CategoryDoc, ".md": CategoryDoc, ".pdf": CategoryDoc, ".doc": CategoryDoc, ".docx": CategoryDoc,
+	".xls": CategoryDoc, ".xlsx": CategoryDoc, ".ppt": CategoryDoc, ".pptx": CategoryDoc, ".epub": CategoryDoc,
+	".exe": CategoryApp, ".apk": CategoryApp, ".dmg": CategoryApp, ".msi": CategoryApp, ".ipa": CategoryApp,
+	".torrent": CategoryTorrent,
+}
+
+// CategoryOf 根据扩展名推断本地文件在百度网盘中的分类
+func CategoryOf(name string) int {
+	if c, ok := categoryExts[strings.ToLower(filepath.Ext(name))]; ok {
+		return c
+	}
+	return CategoryOther
+}
+
+// Options 过滤参数，字段与命令行参数一一对应，加入任务队列时随任务数据保存
+type Options struct {
+	Include    []string `json:"include,omitempty"`     // 只传输匹配的文件
+	Exclude    []string `json:"exclude,omitempty"`     // 不传输匹配的文件和文件夹
+	FilterFrom []string `json:"filter_from,omitempty"` // 规则文件
+	MinSize    string   `json:"min_size,omitempty"`    // 最小文件大小，如 100K
+	MaxSize    string   `json:"max_size,omitempty"`    // 最大文件大小，如 1G
+	MinAge     string   `json:"min_age,omitempty"`     // 只传输修改时间早于该时长之前的文件，如 7d
+	MaxAge     string   `json:"max_age,omitempty"`     // 只传输修改时间在该时长之内的文件，如 24h
+	Categories []string `json:"categories,omitempty"`  // 文件分类，名称或百度网盘分类编号
+	// 排除隐藏文件和隐藏文件夹
+	ExcludeHidden bool `json:"exclude_hidden,omitempty"`
+}
+
+// IsEmpty 是否没有指定任何过滤条件
+func (o Options) IsEmpty() bool {
+	return len(o.Include) == 0 && len(o.Exclude) == 0 && len(o.FilterFrom) == 0 &&
+		o.MinSize == "" && o.MaxSize == "" && o.MinAge == "" && o.MaxAge == "" &&
+		len(o.Categories) == 0 && !o.ExcludeHidden
+}
+
+// Entry 参与过滤的文件，Path 为相对于传输根目录、以 / 分隔的路径
+type Entry struct {
+	Path     string
+	IsDir    bool
+	Size     int64
+	ModTime  time.Time
+	Category int
+}
+
+// Filter 按规则判断文件是否参与传输，nil 表示不过滤
+//
+// 判断顺序：
+//
+// 1. 文件所在的任意一级文件夹被排除时，文件被排除
+// 2. 规则按 --exclude、--include、--filter-from、.bdpanignore（由深到浅）的顺序匹配，第一条匹配的规则生效
+// 3. 没有匹配任何规则时，指定了 --include 的文件被排除，否则参与传输
+// 4. 最后检查大小、修改时间和分类，只对文件生效
+type Filter struct {
+	rules      []rule
+	hasInclude bool
+	ignores    map[string][]rule // 文件夹相对路径 => 该文件夹中 .bdpanignore 的规则
+	minSize    int64
+	maxSize    int64
+	minAge     time.Duration
+	maxAge     time.Duration
+	categories map[int]bool
+	hidden     bool
+	now        time.Time
+}
+
+// New 根据参数创建 Filter，没有任何过滤条件时返回 nil
+func New(opts Options) (*Filter, error) {
+	f := &Filter{now: time.Now(), hidden: opts.ExcludeHidden}
+	for _, p := range opts.Exclude {
+		if r, ok := newRule(p, false); ok {
+			f.rules = append(f.rules, r)
+		}
+	}
+	for _, p := range opts.Include {
+		if r, ok := newRule(p, true); ok {
+			f.rules = append(f.rules, r)
+			f.hasInclude = true
+		}
+	}
+	for _, name := range opts.FilterFrom {
+		lines, err := readLines(name)
+		if err != nil {
+			return nil, fmt.Errorf("读取规则文件失败: %w", err)
+		}
+		f.rules = append(f.rules, parseRules(lines)...)
+	}
+
+	var err error
+	if f.minSize, err = ParseSize(opts.MinSize); err != nil {
+		return nil, fmt.Errorf("--min-size: %w", err)
+	}
+	if f.maxSize, err = ParseSize(opts.MaxSize); err != nil {
+		return nil, fmt.Errorf("--max-size: %w", err)
+	}
+	if f.minAge, err = parseAge(opts.MinAge); err != nil {
+		return nil, fmt.Errorf("--min-age: %w", err)
+	}
+	if f.maxAge, err = parseAge(opts.MaxAge); err != nil {
+		return nil, fmt.Errorf("--max-age: %w", err)
+	}
+	for _, c := range opts.Categories {
+		for _, name := range strings.Split(c, ",") {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+			n, ok := categoryNames[name]
+			if !ok {
+				if n, err = strconv.Atoi(name); err != nil || n < CategoryVideo || n > CategoryTorrent {
+					return nil, fmt.Errorf("--category: 无效的分类 %s", name)
+				}
+			}
+			if f.categories == nil {
+				f.categories = make(map[int]bool)
+			}
+			f.categories[n] = true
+		}
+	}
+
+	if len(f.rules) == 0 && !f.hidden && f.minSize == 0 && f.maxSize == 0 &&
+		f.minAge == 0 && f.maxAge == 0 && len(f.categories) == 0 {
+		return nil, nil
+	}
+	return f, nil
+}
+
+// Match 判断文件或文件夹是否参与传输
+func (f *Filter) Match(e Entry) bool {
+	if f == nil {
+		return true
+	}
+	rel := strings.Trim(path.Clean("/"+filepath.ToSlash(e.Path)), "/")
+	if rel == "" {
+		return true
+	}
+	// 1. 任意一级文件夹被排除时，其中的文件和文件夹都被排除
+	segs := strings.Split(rel, "/")
+	for i := 1; i < len(segs); i++ {
+		if f.decide(strings.Join(segs[:i], "/"), true) < 0 {
+			return false
+		}
+	}
+	d := f.decide(rel, e.IsDir)
+	if e.IsDir {
+		return d >= 0
+	}
+	// 2. 没有匹配任何规则的文件，指定了 --include 时排除
+	if d < 0 || (d == 0 && f.hasInclude) {
+		return false
+	}
+	return f.matchAttrs(e)
+}
+
+// decide 按规则判断路径，1 为包含，-1 为排除，0 为没有匹配的规则
+func (f *Filter) decide(rel string, isDir bool) int {
+	if f.hidden && strings.HasPrefix(path.Base(rel), ".") {
+		return -1
+	}
+	for _, r := range f.rules {
+		if r.match(rel, isDir) {
+			return ruleDecision(r)
+		}
+	}
+	if len(f.ignores) == 0 {
+		return 0
+	}
+	// .bdpanignore 由深到浅匹配，规则相对于忽略文件所在的文件夹
+	dir := path.Dir(rel)
+	for {
+		base := dir
+		if base == "." {
+			base = ""
+		}
+		if rules, ok := f.ignores[base]; ok {
+			sub := rel
+			if base != "" {
+				sub = strings.TrimPrefix(rel, base+"/")
+			}
+			for _, r := range rules {
+				if r.match(sub, isDir) {
+					return ruleDecision(r)
+				}
+			}
+		}
+		if base == "" {
+			return 0
+		}
+		dir = path.Dir(dir)
+	}
+}
+
+func ruleDecision(r rule) int {
+	if r.include {
+		return 1
+	}
+	return -1
+}
+
+// matchAttrs 检查文件大小、修改时间和分类
+func (f *Filter) matchAttrs(e Entry) bool {
+	if f.minSize > 0 && e.Size < f.minSize {
+		return false
+	}
+	if f.maxSize > 0 && e.Size > f.maxSize {
+		return false
+	}
+	if !e.ModTime.IsZero() {
+		age := f.now.Sub(e.ModTime)
+		if f.minAge > 0 && age < f.minAge {
+			return false
+		}
+		if f.maxAge > 0 && age > f.maxAge {
+			return false
+		}
+	}
+	if len(f.categories) > 0 {
+		c := e.Category
+		if c == 0 {
+			c = CategoryOf(e.Path)
+		}
+		if !f.categories[c] {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseSize 解析文件大小，支持 100、100K、1.5M、2G 等格式，单位按 1024 换算
+func ParseSize(s string) (int64, error) {
+	v := strings.ToUpper(strings.TrimSpace(s))
+	if v == "" {
+		return 0, nil
+	}
+	v = strings.TrimSuffix(v, "B")
+	unit := int64(1)
+	switch {
+	case strings.HasSuffix(v, "K"):
+		unit = 1 << 10
+	case strings.HasSuffix(v, "M"):
+		unit = 1 << 20
+	case strings.HasSuffix(v, "G"):
+		unit = 1 << 30
+	case strings.HasSuffix(v, "T"):
+		unit = 1 << 40
+	}
+	if unit > 1 {
+		v = v[:len(v)-1]
+	}
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("无效的大小: %s", s)
+	}
+	return int64(n * float64(unit)), nil
+}
+
+// parseAge 解析时长，在 time.ParseDuration 的基础上支持 d（天）和 w（周）
+func parseAge(s string) (time.Duration, error) {
+	v := strings.TrimSpace(s)
+	if v == "" {
+		return 0, nil
+	}
+	unit := time.Duration(0)
+	switch {
+	case strings.HasSuffix(v, "d"):
+		unit = 24 * time.Hour
+	case strings.HasSuffix(v, "w"):
+		unit = 7 * 24 * time.Hour
+	}
+	if unit > 0 {
+		n, err := strconv.ParseFloat(v[:len(v)-1], 64)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("无效的时长: %s", s)
+		}
+		return time.Duration(n * float64(unit)), nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("无效的时长: %s", s)
+	}
+	return d, nil
+}
@@ -0,0 +1,366 @@
+package filter
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRule_Match(t *testing.T) {
+	cases := []struct {
+		pattern string
+		rel     string
+		isDir   bool
+		want    bool
+	}{
+		// 不含 / 的规则匹配任意层级的文件名
+		{"*.log", "a.log", false, true},
+		{"*.log", "x/y/a.log", false, true},
+		{"*.log", "a.txt", false, false},
+		{"node_modules", "web/node_modules", true, true},
+		// 含 / 的规则从根目录开始匹配
+		{"/build", "build", true, true},
+		{"/build", "src/build", true, false},
+		{"docs/*.md", "docs/a.md", false, true},
+		{"docs/*.md", "docs/sub/a.md", false, false},
+		{"docs/*.md", "x/docs/a.md", false, false},
+		// ** 匹配零个或多个目录
+		{"**/cache/**", "cache/a", false, true},
+		{"**/cache/**", "x/y/cache/z/a", false, true},
+		{"**/cache/**", "x/cached/a", false, false},
+		{"a/**/b", "a/b", false, true},
+		{"a/**/b", "a/x/y/b", false, true},
+		{"a/**/b", "a/x/c", false, false},
+		// 以 / 结尾的规则只匹配文件夹
+		{"tmp/", "tmp", true, true},
+		{"tmp/", "tmp", false, false},
+		{"tmp/", "x/tmp", true, true},
+	}
+	for _, c := range cases {
+		r, ok := newRule(c.pattern, false)
+		if !ok {
+			t.Fatalf("newRule(%q) returned false", c.pattern)
+		}
+		if got := r.match(c.rel, c.isDir); got != c.want {
+			t.Errorf("rule %q match(%q, dir=%v) = %v, want %v", c.pattern, c.rel, c.isDir, got, c.want)
+		}
+	}
+}
+
+func TestNewRule_Empty(t *testing.T) {
+	for _, p := range []string{"", "  ", "/", "//"} {
+		if _, ok := newRule(p, false); ok {
+			t.Errorf("newRule(%q) should be ignored", p)
+		}
+	}
+}
+
+func TestFilter_Match(t *testing.T) {
+	cases := []struct {
+		name string
+		opts Options
+		e    Entry
+		want bool
+	}{
+		{"exclude", Options{Exclude: []string{"*.log"}}, Entry{Path: "a.log"}, false},
+		{"exclude other", Options{Exclude: []string{"*.log"}}, Entry{Path: "a.txt"}, true},
+		{"include only", Options{Include: []string{"*.jpg"}}, Entry{Path: "a.jpg"}, true},
+		{"include unmatched", Options{Include: []string{"*.jpg"}}, Entry{Path: "a.png"}, false},
+		{"include keeps dirs", Options{Include: []string{"*.jpg"}}, Entry{Path: "photos", IsDir: true}, true},
+		// --exclude 先于 --include 匹配
+		{"exclude before include", Options{Include: []string{"*.jpg"}, Exclude: []string{"tmp.jpg"}}, Entry{Path: "tmp.jpg"}, false},
+		{"include after exclude", Options{Include: []string{"*.jpg"}, Exclude: []string{"tmp.jpg"}}, Entry{Path: "a.jpg"}, true},
+		// 文件夹被排除时其中的文件都被排除
+		{"parent excluded", Options{Exclude: []string{"node_modules/"}}, Entry{Path: "web/node_modules/a.js"}, false},
+		{"parent excluded include", Options{Include: []string{"*.js"}, Exclude: []string{"node_modules/"}}, Entry{Path: "node_modules/a.js"}, false},
+		{"hidden file", Options{ExcludeHidden: true}, Entry{Path: "a/.env"}, false},
+		{"hidden dir", Options{ExcludeHidden: true}, Entry{Path: ".git/config"}, false},
+		{"not hidden", Options{ExcludeHidden: true}, Entry{Path: "a/b.txt"}, true},
+	}
+	for _, c := range cases {
+		f, err := New(c.opts)
+		if err != nil {
+			t.Fatalf("%s: New returned error: %v", c.name, err)
+		}
+		if got := f.Match(c.e); got != c.want {
+			t.Errorf("%s: Match(%q) = %v, want %v", c.name, c.e.Path, got, c.want)
+		}
+	}
+}
+
+func TestFilter_MatchAttrs(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		name string
+		opts Options
+		e    Entry
+		want bool
+	}{
+		{"min size", Options{MinSize: "1K"}, Entry{Path: "a", Size: 1023}, false},
+		{"min size ok", Options{MinSize: "1K"}, Entry{Path: "a", Size: 1024}, true},
+		{"max size", Options{MaxSize: "1M"}, Entry{Path: "a", Size: 1<<20 + 1}, false},
+		{"min age", Options{MinAge: "7d"}, Entry{Path: "a", ModTime: now.Add(-time.Hour)}, false},
+		{"min age ok", Options{MinAge: "7d"}, Entry{Path: "a", ModTime: now.Add(-8 * 24 * time.Hour)}, true},
+		{"max age", Options{MaxAge: "24h"}, Entry{Path: "a", ModTime: now.Add(-48 * time.Hour)}, false},
+		{"max age ok", Options{MaxAge: "24h"}, Entry{Path: "a", ModTime: now.Add(-time.Hour)}, true},
+		{"category by ext", Options{Categories: []string{"video,image"}}, Entry{Path: "a.JPG"}, true},
+		{"category other", Options{Categories: []string{"video"}}, Entry{Path: "a.txt"}, false},
+		{"category by number", Options{Categories: []string{"4"}}, Entry{Path: "a", Category: CategoryDoc}, true},
+		// 大小等条件只对文件生效
+		{"dir ignores size", Options{MinSize: "1G"}, Entry{Path: "d", IsDir: true}, true},
+	}
+	for _, c := range cases {
+		f, err := New(c.opts)
+		if err != nil {
+			t.Fatalf("%s: New returned error: %v", c.name, err)
+		}
+		if got := f.Match(c.e); got != c.want {
+			t.Errorf("%s: Match(%+v) = %v, want %v", c.name, c.e, got, c.want)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	f, err := New(Options{})
+	if err != nil || f != nil {
+		t.Fatalf("New with empty options = %v, %v, want nil, nil", f, err)
+	}
+	invalid := []Options{
+		{MinSize: "abc"},
+		{MaxSize: "-1"},
+		{MinAge: "3x"},
+		{MaxAge: "-2d"},
+		{Categories: []string{"movie"}},
+		{Categories: []string{"8"}},
+		{FilterFrom: []string{filepath.Join(t.TempDir(), "missing")}},
+	}
+	for _, opts := range invalid {
+		if _, err := New(opts); err == nil {
+			t.Errorf("New(%+v) expected error", opts)
+		}
+	}
+}
+
+func TestNew_FilterFrom(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "rules")
+	content := "# comment\n\n+ *.md\n- *\n"
+	if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+		t.Fatalf("write rules: %v", err)
+	}
+	f, err := New(Options{FilterFrom: []string{p}})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	// 规则文件中第一条匹配的规则生效
+	if !f.Match(Entry{Path: "a.md"}) {
+		t.Errorf("a.md should be included")
+	}
+	if f.Match(Entry{Path: "a.txt"}) {
+		t.Errorf("a.txt should be excluded")
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	rules := parseRules([]string{"# c", "", "+ a", "- b", "!c", "d"})
+	var got []string
+	for _, r := range rules {
+		got = append(got, r.pattern+map[bool]string{true: "+", false: "-"}[r.include])
+	}
+	want := []string{"a+", "b-", "c+", "d-"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("parseRules = %v, want %v", got, want)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	cases := []struct {
+		in   string
+		want int64
+		err  bool
+	}{
+		{"", 0, false},
+		{"100", 100, false},
+		{"100K", 100 << 10, false},
+		{"100kb", 100 << 10, false},
+		{"1.5M", 3 << 19, false},
+		{"2G", 2 << 30, false},
+		{"1T", 1 << 40, false},
+		{" 3m ", 3 << 20, false},
+		{"abc", 0, true},
+		{"-1K", 0, true},
+		{"K", 0, true},
+	}
+	for _, c := range cases {
+		got, err := ParseSize(c.in)
+		if (err != nil) != c.err || got != c.want {
+			t.Errorf("ParseSize(%q) = %d, %v, want %d, err=%v", c.in, got, err, c.want, c.err)
+		}
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	cases := []struct {
+		in   string
+		want time.Duration
+		err  bool
+	}{
+		{"", 0, false},
+		{"90m", 90 * time.Minute, false},
+		{"24h", 24 * time.Hour, false},
+		{"7d", 7 * 24 * time.Hour, false},
+		{"1.5d", 36 * time.Hour, false},
+		{"2w", 14 * 24 * time.Hour, false},
+		{"d", 0, true},
+		{"-1h", 0, true},
+		{"-1d", 0, true},
+		{"3x", 0, true},
+	}
+	for _, c := range cases {
+		got, err := parseAge(c.in)
+		if (err != nil) != c.err || got != c.want {
+			t.Errorf("parseAge(%q) = %v, %v, want %v, err=%v", c.in, got, err, c.want, c.err)
+		}
+	}
+}
+
+// writeTree 在 root 下创建文件，files 为相对路径 => 文件内容
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+}
+
+// walkPaths 返回 Walk 传给回调的相对路径
+func walkPaths(t *testing.T, f *Filter, root string) []string {
+	t.Helper()
+	var got []string
+	err := f.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, p)
+		if rel != "." {
+			got = append(got, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk returned error: %v", err)
+	}
+	slices.Sort(got)
+	return got
+}
+
+func TestFilter_Walk(t *testing.T) {
+	cases := []struct {
+		name  string
+		opts  Options
+		files map[string]string
+		want  []string
+	}{
+		{
+			// gitignore 中后面的规则优先
+			name: "ignore file last rule wins",
+			files: map[string]string{
+				IgnoreFile: "*.log\n!keep.log\n",
+				"a.log":    "", "keep.log": "", "b.txt": "",
+			},
+			want: []string{"b.txt", "keep.log"},
+		},
+		{
+			name: "ignore file reversed order",
+			files: map[string]string{
+				IgnoreFile: "!keep.log\n*.log\n",
+				"a.log":    "", "keep.log": "", "b.txt": "",
+			},
+			want: []string{"b.txt"},
+		},
+		{
+			// 忽略文件只对所在文件夹及其子目录生效，规则相对于所在文件夹
+			name: "nested ignore file scope",
+			files: map[string]string{
+				"sub/" + IgnoreFile: "/only.txt\n*.tmp\n",
+				"only.txt":          "", "a.tmp": "",
+				"sub/only.txt": "", "sub/b.tmp": "", "sub/x/only.txt": "", "sub/x/c.tmp": "",
+			},
+			want: []string{"a.tmp", "only.txt", "sub", "sub/x", "sub/x/only.txt"},
+		},
+		{
+			// 命令行规则先于忽略文件匹配
+			name: "options before ignore file",
+			opts: Options{Include: []string{"*.log"}},
+			files: map[string]string{
+				IgnoreFile: "*.log\n",
+				"a.log":    "", "b.txt": "",
+			},
+			want: []string{"a.log"},
+		},
+		{
+			// 被排除的文件夹整个跳过
+			name: "prune excluded dir",
+			opts: Options{Exclude: []string{"node_modules/", "/build"}},
+			files: map[string]string{
+				"node_modules/x/a.js": "", "web/node_modules/b.js": "",
+				"build/out.bin": "", "src/build/keep.go": "", "main.go": "",
+			},
+			want: []string{"main.go", "src", "src/build", "src/build/keep.go", "web"},
+		},
+		{
+			name: "prune dir from ignore file",
+			files: map[string]string{
+				IgnoreFile:    "cache/\n",
+				"cache/a.bin": "", "x/cache/b.bin": "", "x/c.txt": "",
+			},
+			want: []string{"x", "x/c.txt"},
+		},
+		{
+			name: "size applies to files only",
+			opts: Options{MinSize: "2"},
+			files: map[string]string{
+				"d/big.txt": "123", "d/small.txt": "1",
+			},
+			want: []string{"d", "d/big.txt"},
+		},
+	}
+	for _, c := range cases {
+		root := t.TempDir()
+		writeTree(t, root, c.files)
+		f, err := New(c.opts)
+		if err != nil {
+			t.Fatalf("%s: New returned error: %v", c.name, err)
+		}
+		if got := walkPaths(t, f, root); !slices.Equal(got, c.want) {
+			t.Errorf("%s: Walk = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestFilter_WalkSkipsPrunedDirs(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root 不受目录权限限制")
+	}
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{"skip/a/c.txt": "", "keep.txt": ""})
+	// 进入不可读的文件夹时 Walk 会返回错误，被排除的文件夹不应进入
+	locked := filepath.Join(root, "skip", "a")
+	if err := os.Chmod(locked, 0o000); err != nil {
+		t.Fatalf("chmod: %v", err)
+	}
+	defer os.Chmod(locked, 0o755)
+	f, err := New(Options{Exclude: []string{"skip/"}})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	if got := walkPaths(t, f, root); !slices.Equal(got, []string{"keep.txt"}) {
+		t.Fatalf("Walk = %v, want [keep.txt]", got)
+	}
+}
@@ -0,0 +1,129 @@
+package filter
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"strings"
+)
+
+// rule 单条过滤规则，语法与 gitignore 相同
+//
+// - 不含 / 的规则匹配任意层级的文件名，如 *.log、node_modules
+// - 含 / 的规则从根目录开始匹配完整路径，开头的 / 可省略，如 /build、docs/*.md
+// - ** 匹配任意层级的目录，如 **/cache/**
+// - 以 / 结尾的规则只匹配文件夹，如 tmp/
+type rule struct {
+	pattern  string
+	include  bool
+	dirOnly  bool
+	anchored bool
+	segs     []string
+}
+
+func newRule(pattern string, include bool) (rule, bool) {
+	p := strings.TrimSpace(pattern)
+	if p == "" {
+		return rule{}, false
+	}
+	r := rule{pattern: p, include: include}
+	if strings.HasSuffix(p, "/") {
+		r.dirOnly = true
+		p = strings.TrimRight(p, "/")
+	}
+	if strings.Contains(p, "/") {
+		r.anchored = true
+		p = strings.TrimLeft(p, "/")
+	}
+	if p == "" {
+		return rule{}, false
+	}
+	r.segs = strings.Split(p, "/")
+	return r, true
+}
+
+// match 判断相对路径 rel 是否匹配规则
+func (r rule) match(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if !r.anchored {
+		return globMatch(r.segs[0], path.Base(rel))
+	}
+	return matchSegs(r.segs, strings.Split(rel, "/"))
+}
+
+// matchSegs 逐级匹配路径，** 匹配零个或多个目录
+func matchSegs(pattern, segs []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			pattern = pattern[1:]
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := range len(segs) + 1 {
+				if matchSegs(pattern, segs[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segs) == 0 || !globMatch(pattern[0], segs[0]) {
+			return false
+		}
+		pattern, segs = pattern[1:], segs[1:]
+	}
+	return len(segs) == 0
+}
+
+func globMatch(pattern, name string) bool {
+	if pattern == "**" {
+		return true
+	}
+	ok, err := path.Match(pattern, name)
+	return err == nil && ok
+}
+
+// parseRules 解析规则文件的内容
+//
+// 每行一条规则，# 开头为注释。"+ " 开头为包含，"- " 开头为排除（rclone 风格），
+// ! 开头为包含，其余为排除（gitignore 风格）
+func parseRules(lines []string) []rule {
+	rules := make([]rule, 0, len(lines))
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		include := false
+		switch {
+		case strings.HasPrefix(line, "+ "):
+			include = true
+			line = line[2:]
+		case strings.HasPrefix(line, "- "):
+			line = line[2:]
+		case strings.HasPrefix(line, "!"):
+			include = true
+			line = line[1:]
+		}
+		if r, ok := newRule(line, include); ok {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+// readLines 读取文件的所有行
+func readLines(name string) ([]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
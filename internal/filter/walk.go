@@ -0,0 +1,76 @@
+package filter
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// Walk 遍历本地目录，跳过被排除的文件和文件夹
+//
+// 实现逻辑：
+//
+// 1. 进入文件夹时读取其中的 .bdpanignore，规则只对该文件夹及其子目录生效
+// 2. 被排除的文件夹整个跳过，不再进入
+// 3. 被排除的文件和 .bdpanignore 本身不会传给 fn
+//
+// f 为 nil 时只应用 .bdpanignore
+func (f *Filter) Walk(root string, fn filepath.WalkFunc) error {
+	w := &Filter{}
+	if f != nil {
+		*w = *f
+	}
+	w.ignores = make(map[string][]rule)
+	return filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return fn(p, info, err)
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "." {
+			rel = ""
+		}
+		if rel != "" {
+			e := Entry{
+				Path:    rel,
+				IsDir:   info.IsDir(),
+				Size:    info.Size(),
+				ModTime: info.ModTime(),
+			}
+			if !info.IsDir() && info.Name() == IgnoreFile {
+				return nil
+			}
+			if !w.Match(e) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+		if info.IsDir() {
+			if err := w.loadIgnore(p, rel); err != nil {
+				return err
+			}
+		}
+		return fn(p, info, nil)
+	})
+}
+
+// loadIgnore 读取文件夹中的 .bdpanignore
+func (f *Filter) loadIgnore(dir, rel string) error {
+	lines, err := readLines(filepath.Join(dir, IgnoreFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	rules := parseRules(lines)
+	// gitignore 中后面的规则优先，倒序后按第一条匹配的规则生效
+	slices.Reverse(rules)
+	f.ignores[rel] = rules
+	return nil
+}
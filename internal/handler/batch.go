@@ -0,0 +1,272 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/wxnacy/bdpan-cli/internal/dto"
+	"github.com/wxnacy/bdpan-cli/internal/filter"
+	"github.com/wxnacy/bdpan-cli/internal/logger"
+	"github.com/wxnacy/bdpan-cli/internal/taskstore"
+	"github.com/wxnacy/go-bdpan"
+	"github.com/wxnacy/go-tools"
+)
+
+// stdinName 列表文件为 - 时从标准输入读取
+const stdinName = "-"
+
+// downloadFromInput 按列表文件下载多个文件，对应 bdpan download -i
+//
+// 实现逻辑：
+//
+// 1. 读取列表文件或标准输入，每行一个网盘路径，可以用 Tab 分隔指定本地保存路径
+// 2. 指定 --queue 时整个列表作为一个任务加入队列，由 bdpan daemon 执行
+// 3. 否则通过 h.DownloadList 下载
+func (h *FileHandler) downloadFromInput(req *dto.DownloadReq) error {
+	items, err := readDownloadList(req.InputFile, req.OutputDir)
+	if err != nil {
+		return err
+	}
+	source := req.InputFile
+	if source == stdinName {
+		source = "stdin"
+	}
+	if req.IsQueue {
+		data := taskstore.DownloadData{Path: source, OutputDir: req.OutputDir, OnConflict: req.OnConflict, PreserveTimes: req.PreserveTimes, Items: items}
+		if !req.Filter.IsEmpty() {
+			data.Filter = &req.Filter
+		}
+		taskID, existed, err := taskstore.Enqueue(context.Background(), taskstore.TaskTypeDownload, downloadListIdentity(items), 0, data)
+		if err != nil {
+			return fmt.Errorf("加入队列失败: %w", err)
+		}
+		if existed {
+			fmt.Printf("任务已在队列中或正在运行: %s\n使用: bdpan task status %s 查看进度\n", taskID, taskID)
+			return nil
+		}
+		fmt.Printf("\n已加入队列，任务ID: %s\n", taskID)
+		fmt.Println("使用: bdpan daemon 启动后台执行，bdpan task list 查看队列")
+		return nil
+	}
+	return h.runDownloadList(req, source, items)
+}
+
+// runDownloadList 下载列表中的文件并输出下载结果
+//
+// 用户取消时返回 nil；其他错误写入日志，返回友好提示
+func (h *FileHandler) runDownloadList(req *dto.DownloadReq, source string, items []taskstore.DownloadItem) error {
+	fmt.Println("\n开始批量下载...")
+	if err := h.DownloadList(req, source, items); err != nil {
+		if errors.Is(err, context.Canceled) {
+			fmt.Println("\n✗ 下载已取消")
+			return nil
+		}
+		logger.Errorf("批量下载失败: %v", err)
+		return fmt.Errorf("网络原因下载失败，请重试，具体报错请通过 bdpan log 命令查看")
+	}
+	return nil
+}
+
+// DownloadList 在一个父任务下下载列表中的所有文件
+//
+// 参数：
+// - req: 具体字段描述见 cmd/download.go init() 中每个 cobra.Command 初始化 usage 字段
+// - source: 列表来源，记录在任务数据中
+// - items: 网盘路径和本地保存路径
+//
+// 实现逻辑：
+//
+// 1. 按所在文件夹分组查找网盘文件，同一文件夹只获取一次文件列表
+// 2. 未找到的路径和文件夹跳过并输出提示，不影响其他文件
+// 3. 按 req.Filter 过滤，路径为完整的网盘路径
+// 4. 任务检测（幂等）：identity 由所有条目的网盘路径和本地保存路径生成，同一列表重复执行时只下载未完成的文件
+// 5. 通过 h.downloadBatch 并发下载，共用一个聚合进度条，本地已存在的文件按 req.OnConflict 处理，默认跳过
+func (h *FileHandler) DownloadList(req *dto.DownloadReq, source string, items []taskstore.DownloadItem) error {
+	policy, err := checkConflictPolicy(req.OnConflict, ConflictSkip)
+	if err != nil {
+		return err
+	}
+	fil, err := filter.New(req.Filter)
+	if err != nil {
+		return err
+	}
+
+	// 1. 查找网盘文件
+	fmt.Printf("正在查找 %d 个文件...\n", len(items))
+	dirFiles := make(map[string]map[string]*bdpan.FileInfo)
+	batch := make([]downloadItem, 0, len(items))
+	var (
+		missing, dirs, filtered int
+		totalBytes              int64
+	)
+	for _, item := range items {
+		dir, name := path.Split(item.Path)
+		dir = path.Clean(dir)
+		files, ok := dirFiles[dir]
+		if !ok {
+			list, err := h.GetDirAllFiles(dir)
+			if err != nil && err.Error() != bdpan.ErrFilenameNotFound.Error() {
+				return fmt.Errorf("获取文件列表失败: %w", err)
+			}
+			files = make(map[string]*bdpan.FileInfo, len(list))
+			for _, f := range list {
+				files[f.GetFilename()] = f
+			}
+			dirFiles[dir] = files
+		}
+
+		// 2. 跳过未找到的路径和文件夹
+		f, ok := files[name]
+		switch {
+		case !ok:
+			missing++
+			fmt.Printf("✗ 未找到: %s\n", item.Path)
+			continue
+		case f.IsDir():
+			dirs++
+			fmt.Printf("跳过文件夹: %s，使用 bdpan download %s -r 下载\n", item.Path, item.Path)
+			continue
+		}
+
+		// 3. 过滤
+		if !fil.Match(filter.Entry{
+			Path:     item.Path,
+			Size:     int64(f.Size),
+			ModTime:  time.Unix(f.ServerMTime, 0),
+			Category: f.Category,
+		}) {
+			filtered++
+			continue
+		}
+		batch = append(batch, downloadItem{File: f, RelPath: item.Path, TargetPath: item.TargetPath})
+		totalBytes += int64(f.Size)
+	}
+
+	fmt.Printf("找到 %d 个文件，共 %s\n", len(batch), tools.FormatSize(totalBytes))
+	if missing > 0 {
+		fmt.Printf("%d 个路径未找到\n", missing)
+	}
+	if dirs > 0 {
+		fmt.Printf("跳过 %d 个文件夹\n", dirs)
+	}
+	if filtered > 0 {
+		fmt.Printf("按过滤条件排除 %d 个文件\n", filtered)
+	}
+	if len(batch) == 0 {
+		return nil
+	}
+
+	// 4. 任务检测
+	tdata := taskstore.DownloadData{Path: source, OutputDir: req.OutputDir, OnConflict: req.OnConflict, PreserveTimes: req.PreserveTimes, Items: items}
+	if !req.Filter.IsEmpty() {
+		tdata.Filter = &req.Filter
+	}
+
+	// 5. 并发下载
+	_, err = h.downloadBatch(req, downloadBatchSpec{
+		Identity: downloadListIdentity(items),
+		Data:     tdata,
+		Title:    fmt.Sprintf("%d 个文件", len(batch)),
+		Policy:   policy,
+		Items:    batch,
+	})
+	return err
+}
+
+// downloadListIdentity 列表下载的 identity，由所有条目的网盘路径和本地保存路径生成
+func downloadListIdentity(items []taskstore.DownloadItem) string {
+	parts := make([]string, 0, 2*len(items)+2)
+	parts = append(parts, "download", "list")
+	for _, item := range items {
+		parts = append(parts, item.Path, item.TargetPath)
+	}
+	return taskstore.BuildIdentitySHA1(parts...)
+}
+
+// readDownloadList 读取下载列表，name 为 - 时从标准输入读取
+//
+// 格式：
+//
+// - 每行一个网盘路径，空行和 # 开头的行忽略
+// - 网盘路径后可以用 Tab 分隔指定本地保存路径，相对路径相对于 outputDir
+// - 本地保存路径以 / 结尾或是已存在的文件夹时，保存为其中的同名文件
+// - 没有指定本地保存路径时保存到 outputDir
+// - 同一网盘路径重复出现时只保留第一次，不同网盘路径的保存路径不能相同
+func readDownloadList(name, outputDir string) ([]taskstore.DownloadItem, error) {
+	var r io.Reader = os.Stdin
+	if name != stdinName {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, fmt.Errorf("读取下载列表失败: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	items := make([]taskstore.DownloadItem, 0)
+	seenPaths := make(map[string]bool)
+	seenTargets := make(map[string]int)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		remote, local, _ := strings.Cut(line, "\t")
+		remote = strings.TrimSpace(remote)
+		if !strings.HasPrefix(remote, "/") {
+			return nil, fmt.Errorf("下载列表第 %d 行: 网盘路径需以 / 开头: %s", lineNo, remote)
+		}
+		remote = path.Clean(remote)
+		if seenPaths[remote] {
+			continue
+		}
+		target, err := listTargetPath(remote, strings.TrimSpace(local), outputDir)
+		if err != nil {
+			return nil, fmt.Errorf("下载列表第 %d 行: %w", lineNo, err)
+		}
+		if prev, ok := seenTargets[target]; ok {
+			return nil, fmt.Errorf("下载列表第 %d 行与第 %d 行的保存路径相同: %s", lineNo, prev, target)
+		}
+		seenPaths[remote] = true
+		seenTargets[target] = lineNo
+		items = append(items, taskstore.DownloadItem{Path: remote, TargetPath: target})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取下载列表失败: %w", err)
+	}
+	if len(items) == 0 {
+		return nil, errors.New("下载列表为空")
+	}
+	return items, nil
+}
+
+// listTargetPath 计算列表条目的本地保存路径
+func listTargetPath(remote, local, outputDir string) (string, error) {
+	name := path.Base(remote)
+	if local == "" {
+		return filepath.Join(outputDir, name), nil
+	}
+	isDir := strings.HasSuffix(local, "/")
+	local, err := homedir.Expand(local)
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(local) {
+		local = filepath.Join(outputDir, local)
+	}
+	if isDir || tools.DirExists(local) {
+		return filepath.Join(local, name), nil
+	}
+	return filepath.Clean(local), nil
+}
@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"fmt"
+
+	"github.com/wxnacy/bdpan-cli/internal/cache"
+	"github.com/wxnacy/bdpan-cli/internal/config"
+	"github.com/wxnacy/bdpan-cli/internal/dto"
+	"github.com/wxnacy/bdpan-cli/internal/filter"
+	"github.com/wxnacy/go-tools"
+)
+
+var cacheHandler *CacheHandler
+
+func GetCacheHandler() *CacheHandler {
+	if cacheHandler == nil {
+		cacheHandler = &CacheHandler{}
+	}
+	return cacheHandler
+}
+
+// CacheHandler 处理 bdpan cache 系列命令，缓存索引见 internal/cache
+type CacheHandler struct {
+}
+
+// 查看缓存统计
+//
+// 实现逻辑：
+//
+// 1. 通过 cache.Scan 同步索引与缓存目录，并查找下载任务留下的 .bdpan-part
+// 2. 输出缓存目录、大小上限、总大小，以及按类型分组的数量和大小
+func (h *CacheHandler) CmdStats(req *dto.GlobalReq) error {
+	entries, err := cache.Scan()
+	if err != nil {
+		return err
+	}
+	var (
+		total, protectedSize int64
+		protectedCount       int
+	)
+	counts := make(map[string]int)
+	sizes := make(map[string]int64)
+	for _, e := range entries {
+		total += e.Size
+		counts[e.Kind]++
+		sizes[e.Kind] += e.Size
+		if e.Protected {
+			protectedCount++
+			protectedSize += e.Size
+		}
+	}
+
+	fmt.Printf("缓存目录: %s\n", config.GetCacheDir())
+	if limit := cache.MaxSize(); limit > 0 {
+		fmt.Printf("大小上限: %s\n", tools.FormatSize(limit))
+	} else {
+		fmt.Println("大小上限: 不限制")
+	}
+	fmt.Printf("总大小: %s（%d 项）\n", tools.FormatSize(total), len(entries))
+	rows := [][]string{{"类型", "数量", "大小"}}
+	for _, kind := range []string{cache.KindPreview, cache.KindChunks, cache.KindPart, cache.KindOther} {
+		if counts[kind] == 0 {
+			continue
+		}
+		rows = append(rows, []string{kind, fmt.Sprint(counts[kind]), tools.FormatSize(sizes[kind])})
+	}
+	if len(rows) > 1 {
+		fmt.Println()
+		printTable(rows)
+	}
+	if protectedCount > 0 {
+		fmt.Printf("\n未结束的下载任务正在使用 %d 项，共 %s，清理时保留\n", protectedCount, tools.FormatSize(protectedSize))
+	}
+	return nil
+}
+
+// 列出缓存条目
+//
+// 实现逻辑：
+//
+// 1. 通过 cache.Scan 同步索引与缓存目录，req.Kind 不为空时只列出该类型
+// 2. 按最近访问时间从旧到新输出，越靠前越先被清理
+func (h *CacheHandler) CmdList(req *dto.CacheListReq) error {
+	if err := cache.CheckKind(req.Kind); err != nil {
+		return err
+	}
+	entries, err := cache.Scan()
+	if err != nil {
+		return err
+	}
+	rows := [][]string{{"类型", "大小", "最近访问", "状态", "路径"}}
+	for _, e := range entries {
+		if req.Kind != "" && e.Kind != req.Kind {
+			continue
+		}
+		status := ""
+		if e.Protected {
+			status = "使用中"
+		}
+		rows = append(rows, []string{e.Kind, tools.FormatSize(e.Size), formatTaskTime(e.AccessTime), status, e.Path})
+	}
+	if len(rows) == 1 {
+		fmt.Println("没有缓存")
+		return nil
+	}
+	printTable(rows)
+	return nil
+}
+
+// 清理缓存
+//
+// 实现逻辑：
+//
+// 1. 指定 --all 时清理所有条目，否则清理到总大小不超过 req.MaxSize，为空时使用配置 cache.max_size
+// 2. req.Kind 不为空时只清理该类型的条目，req.CacheDirOnly 时不清理下载目录中的 .bdpan-part
+// 3. 通过 cache.Prune 按最近访问时间从旧到新清理，未结束的下载任务使用的 .bdpan-part 保留
+// 4. 指定 --dry-run 时只输出将被清理的条目
+func (h *CacheHandler) CmdPrune(req *dto.CachePruneReq) error {
+	if err := cache.CheckKind(req.Kind); err != nil {
+		return err
+	}
+	opts := cache.PruneOptions{Kind: req.Kind, DryRun: req.DryRun, CacheDirOnly: req.CacheDirOnly}
+	if !req.All {
+		if req.MaxSize != "" {
+			size, err := filter.ParseSize(req.MaxSize)
+			if err != nil {
+				return err
+			}
+			opts.MaxSize = size
+		} else {
+			opts.MaxSize = cache.MaxSize()
+		}
+		if opts.MaxSize <= 0 {
+			return fmt.Errorf("没有设置缓存大小上限，使用 --max-size 指定或 --all 清理全部")
+		}
+	}
+
+	removed, err := cache.Prune(opts)
+	var freed int64
+	for _, e := range removed {
+		freed += e.Size
+		if req.DryRun || req.IsVerbose {
+			fmt.Printf("%s\t%s\t%s\n", e.Kind, tools.FormatSize(e.Size), e.Path)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if req.DryRun {
+		fmt.Printf("将清理 %d 项，共 %s\n", len(removed), tools.FormatSize(freed))
+		return nil
+	}
+	fmt.Printf("已清理 %d 项，释放 %s\n", len(removed), tools.FormatSize(freed))
+	return nil
+}
@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/wxnacy/bdpan-cli/internal/downloader"
+	"github.com/wxnacy/bdpan-cli/internal/dto"
+	"github.com/wxnacy/bdpan-cli/pkg/bdtools"
+	"github.com/wxnacy/go-bdpan"
+)
+
+// tailWindow tail 按行读取时第一次读取的文件末尾大小，行数不够时扩大 4 倍继续读取
+const tailWindow = 64 * 1024
+
+// errLinesDone head 已输出足够的行数
+var errLinesDone = errors.New("lines done")
+
+// CmdCat 将远程文件内容输出到标准输出
+//
+// 实现逻辑：
+//
+// 1. 通过 bdtools.GetFileByPath 查找文件，文件夹直接返回错误
+// 2. 通过 FSID 获取带 access_token 的 Dlink
+// 3. 使用 downloader.ChunkDownloader.Stream 按 Range 读取 [Offset, Offset+Length)，失败重试和 Dlink 刷新与下载相同
+func (h *FileHandler) CmdCat(req *dto.CatReq) error {
+	if req.Offset < 0 {
+		return fmt.Errorf("--offset 不能小于 0")
+	}
+	file, d, err := h.openStream(req.Path)
+	if err != nil {
+		return err
+	}
+	if req.Offset >= int64(file.Size) {
+		return nil
+	}
+	return d.Stream(os.Stdout, req.Offset, req.Length)
+}
+
+// CmdHead 输出远程文件的前 req.Lines 行，指定 req.Bytes 时输出前 req.Bytes 个字节
+//
+// 按行输出时边读取边计数，输出足够的行数后停止读取
+func (h *FileHandler) CmdHead(req *dto.CatReq) error {
+	_, d, err := h.openStream(req.Path)
+	if err != nil {
+		return err
+	}
+	if req.Bytes > 0 {
+		return d.Stream(os.Stdout, 0, req.Bytes)
+	}
+	if req.Lines <= 0 {
+		return nil
+	}
+	err = d.Stream(&lineWriter{w: os.Stdout, lines: req.Lines}, 0, -1)
+	if errors.Is(err, errLinesDone) {
+		return nil
+	}
+	return err
+}
+
+// CmdTail 输出远程文件的最后 req.Lines 行，指定 req.Bytes 时输出最后 req.Bytes 个字节
+//
+// 按行输出时先读取文件末尾 64KB，行数不够时扩大读取范围，直到行数足够或读到文件开头
+func (h *FileHandler) CmdTail(req *dto.CatReq) error {
+	file, d, err := h.openStream(req.Path)
+	if err != nil {
+		return err
+	}
+	size := int64(file.Size)
+	if req.Bytes > 0 {
+		return d.Stream(os.Stdout, max(size-req.Bytes, 0), -1)
+	}
+	if req.Lines <= 0 || size == 0 {
+		return nil
+	}
+	for window := int64(tailWindow); ; window *= 4 {
+		start := max(size-window, 0)
+		var buf bytes.Buffer
+		if err := d.Stream(&buf, start, size-start); err != nil {
+			return err
+		}
+		content := buf.Bytes()
+		if i := lastLinesIndex(content, req.Lines); i >= 0 || start == 0 {
+			_, err := os.Stdout.Write(content[max(i, 0):])
+			return err
+		}
+	}
+}
+
+// openStream 查找远程文件并创建用于流式读取的下载器
+func (h *FileHandler) openStream(path string) (*bdpan.FileInfo, *downloader.ChunkDownloader, error) {
+	file, err := h.GetFileByPath(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("查找文件失败: %w", err)
+	}
+	if file.IsDir() {
+		return nil, nil, fmt.Errorf("%s 是文件夹", path)
+	}
+	info, err := bdtools.GetFileInfo(h.accessToken, file.FSID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("获取下载链接失败: %w", err)
+	}
+	d := downloader.NewChunkDownloader(info.Dlink, "", "")
+	d.SetURLRefresher(h.dlinkRefresher(file.FSID))
+	return file, d, nil
+}
+
+// lastLinesIndex 返回最后 n 行在 content 中的起始位置，content 中不足 n 行时返回 -1
+//
+// 以换行结尾的内容最后的换行不算作新的一行
+func lastLinesIndex(content []byte, n int) int {
+	end := len(content)
+	if end > 0 && content[end-1] == '\n' {
+		end--
+	}
+	for ; n > 0; n-- {
+		i := bytes.LastIndexByte(content[:end], '\n')
+		if i < 0 {
+			return -1
+		}
+		end = i
+	}
+	return end + 1
+}
+
+// lineWriter 只写入前 lines 行，写满后返回 errLinesDone
+type lineWriter struct {
+	w     io.Writer
+	lines int
+}
+
+func (lw *lineWriter) Write(p []byte) (int, error) {
+	for i, b := range p {
+		if b != '\n' {
+			continue
+		}
+		lw.lines--
+		if lw.lines == 0 {
+			if _, err := lw.w.Write(p[:i+1]); err != nil {
+				return 0, err
+			}
+			return i + 1, errLinesDone
+		}
+	}
+	return lw.w.Write(p)
+}
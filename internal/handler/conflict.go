@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/wxnacy/go-bdpan"
+)
+
+// 本地已存在同名文件时的处理策略
+const (
+	ConflictSkip        = "skip"         // 跳过，保留本地文件
+	ConflictOverwrite   = "overwrite"    // 重新下载并覆盖
+	ConflictRename      = "rename"       // 下载为 name(1).ext
+	ConflictNewer       = "newer"        // 远程文件修改时间晚于本地文件时覆盖
+	ConflictSizeDiffers = "size-differs" // 远程文件大小与本地文件不同时覆盖
+)
+
+// checkConflictPolicy 校验冲突策略，为空时使用 def
+func checkConflictPolicy(policy, def string) (string, error) {
+	if policy == "" {
+		return def, nil
+	}
+	switch policy {
+	case ConflictSkip, ConflictOverwrite, ConflictRename, ConflictNewer, ConflictSizeDiffers:
+		return policy, nil
+	}
+	return "", fmt.Errorf("--on-conflict 不支持 %s，可选值: skip、overwrite、rename、newer、size-differs", policy)
+}
+
+// resolveConflict 按冲突策略确定远程文件的本地保存路径
+//
+// newer 策略比较的远程修改时间与 --preserve-times 设置的时间一致，见 remoteModTime
+//
+// 返回：
+// - string: 实际保存路径，rename 策略下可能与 targetPath 不同
+// - bool: 是否跳过下载
+// - error: 读取本地文件失败
+func (h *FileHandler) resolveConflict(policy, timesMode string, file *bdpan.FileInfo, targetPath string) (string, bool, error) {
+	info, err := os.Stat(targetPath)
+	if os.IsNotExist(err) {
+		return targetPath, false, nil
+	} else if err != nil {
+		return "", false, err
+	}
+	if info.IsDir() {
+		return "", false, fmt.Errorf("本地已存在同名文件夹: %s", targetPath)
+	}
+	switch policy {
+	case ConflictOverwrite:
+		return targetPath, false, nil
+	case ConflictRename:
+		return h.resolveOutputPath(targetPath), false, nil
+	case ConflictNewer:
+		return targetPath, !remoteModTime(file, timesMode).After(info.ModTime()), nil
+	case ConflictSizeDiffers:
+		return targetPath, info.Size() == int64(file.Size), nil
+	default:
+		return targetPath, true, nil
+	}
+}
@@ -0,0 +1,235 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/wxnacy/bdpan-cli/internal/common"
+	"github.com/wxnacy/bdpan-cli/internal/config"
+	"github.com/wxnacy/bdpan-cli/internal/dto"
+	"github.com/wxnacy/bdpan-cli/internal/logger"
+	"github.com/wxnacy/bdpan-cli/internal/model"
+	"github.com/wxnacy/bdpan-cli/internal/progress"
+	"github.com/wxnacy/bdpan-cli/internal/ratelimit"
+	"github.com/wxnacy/bdpan-cli/internal/taskstore"
+)
+
+// daemonPollInterval 队列为空时的轮询间隔
+const daemonPollInterval = 3 * time.Second
+
+var daemonHandler *DaemonHandler
+
+func GetDaemonHandler() *DaemonHandler {
+	if daemonHandler == nil {
+		daemonHandler = &DaemonHandler{
+			running:     make(map[string]struct{}),
+			interrupted: make(map[string]struct{}),
+		}
+	}
+	return daemonHandler
+}
+
+// DaemonHandler 后台执行任务队列，任务由 bdpan download --queue 等命令加入
+type DaemonHandler struct {
+	mu          sync.Mutex
+	running     map[string]struct{} // 正在执行的任务
+	interrupted map[string]struct{} // 因 daemon 退出而取消的任务，退出前放回队列
+	stopping    bool                // 已开始退出，不再领取任务
+}
+
+// 启动 daemon
+//
+// 实现逻辑：
+//
+// 1. 并发数优先使用 req.Workers，未指定时读取配置 daemon.workers
+// 2. 每个 worker 循环通过 taskstore.ClaimQueued 领取任务，队列为空时每 3 秒轮询一次
+// 3. 任务以无终端模式执行，心跳与 CancelRequested 的处理与前台下载一致
+// 4. 收到 Ctrl+C 或 SIGTERM 后不再领取任务，请求取消正在执行的任务，并在退出前放回队列
+// 5. 退出过程中再次收到信号直接退出，未放回队列的任务会在失去心跳后显示为陈旧
+// 6. 所有 worker 共享下载限速，req.LimitRate 覆盖配置 limit 中的下载限速
+func (h *DaemonHandler) CmdDaemon(req *dto.DaemonReq) error {
+	if err := setLimitRate(ratelimit.Download(), req.LimitRate); err != nil {
+		return err
+	}
+	workers := req.Workers
+	if workers <= 0 {
+		workers = config.GetDaemonWorkers()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	h.printf("daemon 已启动，PID: %d，并发数: %d", os.Getpid(), workers)
+	var wg sync.WaitGroup
+	for i := 1; i <= workers; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			h.runWorker(ctx, id)
+		}(i)
+	}
+
+	<-ctx.Done()
+	// 恢复默认信号处理，再次 Ctrl+C 可直接退出
+	stop()
+	h.printf("正在停止，等待执行中的任务退出（约 5 秒），再次按 Ctrl+C 强制退出")
+	h.interruptRunning()
+	wg.Wait()
+	h.printf("daemon 已退出")
+	return nil
+}
+
+// runWorker 循环领取并执行任务，直到 ctx 结束
+func (h *DaemonHandler) runWorker(ctx context.Context, id int) {
+	for ctx.Err() == nil {
+		t, err := h.claim()
+		if err != nil {
+			logger.Errorf("daemon 领取任务失败: %v", err)
+		}
+		if t == nil {
+			select {
+			case <-ctx.Done():
+			case <-time.After(daemonPollInterval):
+			}
+			continue
+		}
+
+		h.printf("worker %d 开始任务: %s %s", id, t.ID, taskSourcePath(t))
+		err = h.runTask(t)
+		if h.untrack(t.ID) && errors.Is(err, context.Canceled) {
+			if rerr := h.requeue(t.ID); rerr != nil {
+				logger.Errorf("任务 %s 放回队列失败: %v", t.ID, rerr)
+			}
+			h.printf("worker %d 任务已放回队列: %s", id, t.ID)
+			continue
+		}
+		switch {
+		case err == nil:
+			h.printf("worker %d 任务完成: %s", id, t.ID)
+		case errors.Is(err, context.Canceled):
+			status := taskstore.StatusCanceled
+			if cur, gerr := taskstore.Get(context.Background(), t.ID); gerr == nil {
+				status = cur.Status
+			}
+			h.printf("worker %d 任务%s: %s", id, status, t.ID)
+		default:
+			logger.Errorf("daemon 执行任务 %s 失败: %v", t.ID, err)
+			h.printf("worker %d 任务失败: %s %v", id, t.ID, err)
+		}
+	}
+}
+
+// runTask 以无终端模式执行任务
+//
+// DownloadDir/DownloadFile 在领取任务前就可能返回（如获取文件列表失败、空文件夹），
+// 此时任务仍是运行中，需要根据结果补写最终状态
+func (h *DaemonHandler) runTask(t *model.Task) error {
+	err := h.execTask(t)
+	cur, gerr := taskstore.Get(context.Background(), t.ID)
+	if gerr != nil || cur.Status != taskstore.StatusRunning {
+		return err
+	}
+	switch {
+	case err == nil:
+		_ = taskstore.Complete(context.Background(), t.ID)
+	case errors.Is(err, context.Canceled):
+		_ = taskstore.SetCanceled(context.Background(), t.ID)
+	default:
+		_ = taskstore.Fail(context.Background(), t.ID, err.Error())
+	}
+	return err
+}
+
+func (h *DaemonHandler) execTask(t *model.Task) error {
+	if t.Type != taskstore.TaskTypeDownload {
+		return fmt.Errorf("daemon 暂不支持%s任务", t.Type)
+	}
+	var data taskstore.DownloadData
+	if err := taskstore.DecodeData(t, &data); err != nil {
+		return fmt.Errorf("读取任务数据失败: %w", err)
+	}
+	req := newDownloadReqFromData(data)
+	req.Headless = true
+	req.Progress = progress.ModeNone
+
+	fh := GetFileHandler()
+	if len(data.Items) > 0 {
+		return fh.DownloadList(req, data.Path, data.Items)
+	}
+	file, err := fh.refreshFileInfo(data)
+	if err != nil {
+		return fmt.Errorf("查找文件失败: %w", err)
+	}
+	if file.IsDir() {
+		_, err = fh.DownloadDir(file, req)
+	} else {
+		_, err = fh.DownloadFile(file, req)
+	}
+	return err
+}
+
+// interruptRunning 请求取消正在执行的任务，执行端在下一次心跳时退出
+// 用户已经请求取消或暂停的任务不放回队列，之后 claim 不再领取任务
+func (h *DaemonHandler) interruptRunning() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.stopping = true
+	for id := range h.running {
+		t, err := taskstore.Get(context.Background(), id)
+		if err != nil || t.CancelRequested != 0 {
+			continue
+		}
+		if err := taskstore.Cancel(context.Background(), id); err != nil {
+			logger.Errorf("取消任务 %s 失败: %v", id, err)
+			continue
+		}
+		h.interrupted[id] = struct{}{}
+	}
+}
+
+// claim 领取任务并在同一把锁内记录为执行中，interruptRunning 不会漏掉刚领取的任务
+// daemon 已开始退出时返回 nil
+func (h *DaemonHandler) claim() (*model.Task, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.stopping {
+		return nil, nil
+	}
+	t, err := taskstore.ClaimQueued(context.Background())
+	if err != nil || t == nil {
+		return nil, err
+	}
+	h.running[t.ID] = struct{}{}
+	return t, nil
+}
+
+// untrack 移除执行记录，返回任务是否因 daemon 退出而中断
+func (h *DaemonHandler) untrack(id string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.running, id)
+	_, ok := h.interrupted[id]
+	delete(h.interrupted, id)
+	return ok
+}
+
+// requeue 将 daemon 退出时中断的任务放回队列，以中断后读取到的状态作为更新条件
+func (h *DaemonHandler) requeue(id string) error {
+	t, err := taskstore.Get(context.Background(), id)
+	if err != nil {
+		return err
+	}
+	return taskstore.Requeue(context.Background(), t)
+}
+
+func (h *DaemonHandler) printf(format string, a ...any) {
+	msg := fmt.Sprintf(format, a...)
+	logger.Infof("%s", msg)
+	fmt.Printf("[%s] %s\n", time.Now().Format(common.FMT_DATETIME), msg)
+}
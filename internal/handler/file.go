@@ -8,8 +8,10 @@ import (
 	"os/signal"
 	"path"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -19,8 +21,11 @@ import (
 	"github.com/wxnacy/bdpan-cli/internal/config"
 	"github.com/wxnacy/bdpan-cli/internal/downloader"
 	"github.com/wxnacy/bdpan-cli/internal/dto"
+	"github.com/wxnacy/bdpan-cli/internal/filter"
 	"github.com/wxnacy/bdpan-cli/internal/logger"
 	"github.com/wxnacy/bdpan-cli/internal/model"
+	"github.com/wxnacy/bdpan-cli/internal/progress"
+	"github.com/wxnacy/bdpan-cli/internal/ratelimit"
 	"github.com/wxnacy/bdpan-cli/internal/taskstore"
 	"github.com/wxnacy/bdpan-cli/pkg/bdtools"
 	"github.com/wxnacy/go-bdpan"
@@ -107,6 +112,27 @@ func (h *FileHandler) GetDirAllFiles(dir string) ([]*bdpan.FileInfo, error) {
 	return totalList, nil
 }
 
+// GetDirAllFilesRecursive 按层遍历获取目录下所有层级的文件和文件夹
+func (h *FileHandler) GetDirAllFilesRecursive(dir string) ([]*bdpan.FileInfo, error) {
+	totalList := []*bdpan.FileInfo{}
+	queue := []string{dir}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		files, err := h.GetDirAllFiles(cur)
+		if err != nil {
+			return nil, fmt.Errorf("获取 %s 文件列表失败: %w", cur, err)
+		}
+		for _, f := range files {
+			if f.IsDir() {
+				queue = append(queue, f.Path)
+			}
+		}
+		totalList = append(totalList, files...)
+	}
+	return totalList, nil
+}
+
 func (h *FileHandler) DeleteFiles(paths ...string) (*bdpan.ManageFileRes, error) {
 	return bdpan.DeleteFiles(h.accessToken, paths...)
 }
@@ -162,7 +188,34 @@ func (h *FileHandler) BatchRenameFiles(files []*model.File) (*bdpan.ManageFileRe
 // 4. 如果是文件，调用 h.DownloadFile
 // 5. 输出下载结果
 // 6. 意外失败，使用 logger.Errorf 写入日志，返回友好错误信息，提示 bdpan log 查看原因
+// 7. 指定 --queue 时只加入任务队列，由 bdpan daemon 执行
+// 8. 指定 --limit-rate 时固定下载限速，覆盖配置 limit 中的下载限速和时间段限速，不能与 --queue 同时使用
+// 9. 过滤参数只对文件夹下载生效，开始前校验参数
+// 10. 指定 --on-conflict 时按策略处理本地已存在的文件，开始前校验参数
+// 11. --progress 指定进度输出方式，默认终端中使用 TUI 进度条，否则定时输出文字进度
 func (h *FileHandler) CmdDownload(req *dto.DownloadReq) error {
+	if _, err := filter.New(req.Filter); err != nil {
+		return err
+	}
+	if _, err := checkConflictPolicy(req.OnConflict, ConflictSkip); err != nil {
+		return err
+	}
+	if _, err := checkPreserveTimes(req.PreserveTimes); err != nil {
+		return err
+	}
+	if err := progress.Check(req.Progress); err != nil {
+		return err
+	}
+	// daemon 中所有任务共享下载限速，单个任务无法单独限速
+	if req.IsQueue && req.LimitRate != "" {
+		return errors.New("--limit-rate 不能与 --queue 同时使用，队列任务的限速使用 bdpan daemon --limit-rate 或配置 limit.download")
+	}
+	if err := setLimitRate(ratelimit.Download(), req.LimitRate); err != nil {
+		return err
+	}
+	if req.InputFile != "" {
+		return h.downloadFromInput(req)
+	}
 	fmt.Printf("正在查找文件: %s\n", req.Path)
 
 	// 1. 查找文件
@@ -175,7 +228,52 @@ func (h *FileHandler) CmdDownload(req *dto.DownloadReq) error {
 	fmt.Printf("文件ID: %d\n", f.FSID)
 	fmt.Printf("文件大小: %s\n", tools.FormatSize(int64(f.Size)))
 
+	if req.IsQueue {
+		return h.enqueueDownload(f, req)
+	}
+
 	// 2. 根据文件类型处理
+	return h.runDownload(f, req)
+}
+
+// enqueueDownload 将下载加入任务队列
+//
+// identity 与 DownloadDir/DownloadFile 保持一致，daemon 执行时 ClaimOrCreate 会命中同一任务
+func (h *FileHandler) enqueueDownload(f *bdpan.FileInfo, req *dto.DownloadReq) error {
+	var (
+		identity string
+		data     taskstore.DownloadData
+	)
+	if f.IsDir() {
+		outputDir := filepath.Join(req.OutputDir, filepath.Base(f.Path))
+		identity = taskstore.BuildIdentitySHA1("download", "dir", f.Path, outputDir)
+		data = taskstore.DownloadData{Path: f.Path, OutputDir: outputDir, IsDir: true, IsRecursion: req.IsRecursion}
+		if !req.Filter.IsEmpty() {
+			data.Filter = &req.Filter
+		}
+	} else {
+		identity = taskstore.BuildIdentitySHA1("download", "file", f.Path, req.OutputDir)
+		data = taskstore.DownloadData{FSID: f.FSID, Path: f.Path, MD5: f.MD5, TargetPath: req.OutputPath, OutputDir: req.OutputDir}
+	}
+	data.OnConflict = req.OnConflict
+	data.PreserveTimes = req.PreserveTimes
+	taskID, existed, err := taskstore.Enqueue(context.Background(), taskstore.TaskTypeDownload, identity, int64(f.Size), data)
+	if err != nil {
+		return fmt.Errorf("加入队列失败: %w", err)
+	}
+	if existed {
+		fmt.Printf("任务已在队列中或正在运行: %s\n使用: bdpan task status %s 查看进度\n", taskID, taskID)
+		return nil
+	}
+	fmt.Printf("\n已加入队列，任务ID: %s\n", taskID)
+	fmt.Println("使用: bdpan daemon 启动后台执行，bdpan task list 查看队列")
+	return nil
+}
+
+// runDownload 根据文件类型下载文件夹或文件，并输出下载结果
+//
+// 用户取消时返回 nil；其他错误写入日志，返回友好提示
+func (h *FileHandler) runDownload(f *bdpan.FileInfo, req *dto.DownloadReq) error {
 	if f.IsDir() {
 		// 下载文件夹
 		fmt.Println("\n开始下载文件夹...")
@@ -227,14 +325,19 @@ func (h *FileHandler) CmdDownload(req *dto.DownloadReq) error {
 //
 // 实现逻辑：
 //
-// 1. 获取文件夹下的所有文件列表（递归）
-// 2. 使用 `bdtools.BatchGetFileInfos` 批量获取文件详情（包含下载链接）
-// 3. 并发下载文件，默认并发数 3
-// 4. 显示下载进度，统计已下载/总数量
-// 5. 任务检测（幂等）：使用 `taskstore.BuildIdentitySHA1("download","dir", 源目录Path, 输出目录)` 生成稳定 identity
-// 6. 任务领取：`taskstore.ClaimOrCreate` 若已有“运行中且仍存活”的任务则返回 attached=true 并直接退出；否则创建/接管并返回 task_id
-// 7. 心跳与取消：循环每 5s `taskstore.Heartbeat` 更新进度，若返回 cancelRequested==true 则取消父上下文协作退出
+// 1. 获取文件夹下的文件列表，req.IsRecursion 为 true 时通过 h.GetDirAllFilesRecursive 获取所有层级，否则只获取第一层
+// 2. 递归下载时在本地重建完整的目录结构，空文件夹同样创建，开始下载前输出文件数、文件夹数和总大小
+// 3. 按 req.Filter 过滤文件和文件夹，路径相对于下载的文件夹，被排除的文件夹不创建，其中的文件也不下载
+// 4. 任务检测（幂等）：使用 `taskstore.BuildIdentitySHA1("download","dir", 源目录Path, 输出目录)` 生成稳定 identity
+// 5. 通过 h.downloadBatch 在一个父任务下并发下载所有文件，显示聚合进度
+// 6. 本地已存在的文件按 req.OnConflict 处理，默认跳过；overwrite、newer、size-differs 策略下已完成的子项同样重新检查
+// 7. 下载成功后按 req.PreserveTimes 设置文件夹的修改时间，文件的修改时间在各自下载完成时设置
 func (h *FileHandler) DownloadDir(file *bdpan.FileInfo, req *dto.DownloadReq) (string, error) {
+	policy, err := checkConflictPolicy(req.OnConflict, ConflictSkip)
+	if err != nil {
+		return "", err
+	}
+
 	// 确定下载目标目录
 	_, dirName := filepath.Split(file.Path)
 	outputDir := filepath.Join(req.OutputDir, dirName)
@@ -242,59 +345,226 @@ func (h *FileHandler) DownloadDir(file *bdpan.FileInfo, req *dto.DownloadReq) (s
 	fmt.Printf("开始下载文件夹: %s -> %s\n", file.Path, outputDir)
 
 	// 1. 获取文件夹下的所有文件
-	files, err := h.GetDirAllFiles(file.Path)
+	var files []*bdpan.FileInfo
+	if req.IsRecursion {
+		fmt.Println("正在递归获取文件列表...")
+		files, err = h.GetDirAllFilesRecursive(file.Path)
+	} else {
+		files, err = h.GetDirAllFiles(file.Path)
+	}
 	if err != nil {
 		return "", fmt.Errorf("获取文件列表失败: %w", err)
 	}
 
-	// 过滤出文件（排除文件夹）
+	fil, err := filter.New(req.Filter)
+	if err != nil {
+		return "", err
+	}
+
+	// 过滤出文件（排除文件夹），计算总字节数，用于聚合进度条
 	fileList := make([]*bdpan.FileInfo, 0)
+	dirList := make([]*bdpan.FileInfo, 0)
+	var (
+		totalBytes    int64
+		filteredCount int
+	)
 	for _, f := range files {
-		if !f.IsDir() {
+		if !fil.Match(filter.Entry{
+			Path:     strings.TrimPrefix(strings.TrimPrefix(f.Path, file.Path), "/"),
+			IsDir:    f.IsDir(),
+			Size:     int64(f.Size),
+			ModTime:  time.Unix(f.ServerMTime, 0),
+			Category: f.Category,
+		}) {
+			if !f.IsDir() {
+				filteredCount++
+			}
+			continue
+		}
+		if f.IsDir() {
+			dirList = append(dirList, f)
+		} else {
 			fileList = append(fileList, f)
+			totalBytes += int64(f.Size)
 		}
 	}
 
-	fmt.Printf("找到 %d 个文件\n", len(fileList))
-	if len(fileList) == 0 {
+	if req.IsRecursion {
+		fmt.Printf("找到 %d 个文件，%d 个文件夹，共 %s\n", len(fileList), len(dirList), tools.FormatSize(totalBytes))
+		// 重建目录结构，保证空文件夹也被创建
+		if err := os.MkdirAll(outputDir, 0o755); err != nil {
+			return "", err
+		}
+		for _, d := range dirList {
+			relPath := strings.TrimPrefix(strings.TrimPrefix(d.Path, file.Path), "/")
+			if err := os.MkdirAll(filepath.Join(outputDir, relPath), 0o755); err != nil {
+				return "", err
+			}
+		}
+	} else {
+		fmt.Printf("找到 %d 个文件，共 %s\n", len(fileList), tools.FormatSize(totalBytes))
+		if len(dirList) > 0 {
+			fmt.Printf("跳过 %d 个子文件夹，使用 -r 递归下载\n", len(dirList))
+		}
+	}
+	if filteredCount > 0 {
+		fmt.Printf("按过滤条件排除 %d 个文件\n", filteredCount)
+	}
+	if len(fileList) == 0 && !req.IsRecursion {
 		return outputDir, nil
 	}
 
-	// 2. 批量获取文件详情（获取 Dlink）
-	fsids := make([]uint64, 0, len(fileList))
+	// ===== Task detection & claim =====
+	// 任务检测：使用 taskstore.BuildIdentitySHA1 生成稳定 identity，用于任务的唯一标识
+	identity := taskstore.BuildIdentitySHA1("download", "dir", file.Path, outputDir)
+	// 任务数据：记录下载任务的相关信息
+	tdata := taskstore.DownloadData{Path: file.Path, OutputDir: outputDir, IsDir: true, IsRecursion: req.IsRecursion}
+	if !req.Filter.IsEmpty() {
+		tdata.Filter = &req.Filter
+	}
+	tdata.OnConflict = req.OnConflict
+	tdata.PreserveTimes = req.PreserveTimes
+
+	// 计算目标文件路径（保持相对路径结构）
+	items := make([]downloadItem, 0, len(fileList))
 	for _, f := range fileList {
-		fsids = append(fsids, f.FSID)
+		relPath := strings.TrimPrefix(strings.TrimPrefix(f.Path, file.Path), "/")
+		items = append(items, downloadItem{File: f, RelPath: relPath, TargetPath: filepath.Join(outputDir, relPath)})
+	}
+	if len(items) > 0 {
+		attached, err := h.downloadBatch(req, downloadBatchSpec{
+			Identity: identity,
+			Data:     tdata,
+			Title:    filepath.Base(file.Path),
+			SaveDir:  outputDir,
+			Policy:   policy,
+			Items:    items,
+		})
+		if attached {
+			return "", nil
+		}
+		if err != nil {
+			return outputDir, err
+		}
 	}
 
-	fmt.Println("正在获取文件下载链接...")
-	detailFiles, err := bdtools.BatchGetFileInfos(h.accessToken, fsids)
-	if err != nil {
-		return "", fmt.Errorf("获取文件详情失败: %w", err)
+	// 文件全部写入后再设置文件夹的修改时间，避免被新建文件更新；非递归下载时只有最外层文件夹
+	dirs := []*bdpan.FileInfo{file}
+	if req.IsRecursion {
+		dirs = append(dirs, dirList...)
+	}
+	for _, d := range dirs {
+		relPath := strings.TrimPrefix(strings.TrimPrefix(d.Path, file.Path), "/")
+		if err := applyModTime(filepath.Join(outputDir, relPath), d, req.PreserveTimes); err != nil {
+			logger.Errorf("%s: %v", d.Path, err)
+		}
 	}
+	return outputDir, nil
+}
+
+// downloadItem 批量下载中的单个文件
+type downloadItem struct {
+	File       *bdpan.FileInfo // 远程文件，不需要包含 Dlink
+	RelPath    string          // 输出信息中展示的路径
+	TargetPath string          // 本地保存路径
+}
+
+// downloadBatchSpec 批量下载参数
+type downloadBatchSpec struct {
+	Identity string                 // 父任务的 identity
+	Data     taskstore.DownloadData // 父任务数据
+	Title    string                 // 聚合进度条的标题
+	SaveDir  string                 // 结束时输出的保存目录，为空时不输出
+	Policy   string                 // 本地已存在同名文件时的处理策略
+	Items    []downloadItem         // 需要下载的文件，远程路径不能重复
+}
 
-	// 计算总字节数，用于聚合进度条
+// downloadBatch 在一个父任务下并发下载多个文件，文件夹下载和列表下载共用
+//
+// 返回：
+// - bool: 已有相同的任务正在运行，本次没有下载
+// - error: 错误信息
+//
+// 实现逻辑：
+//
+// 1. 任务领取：`taskstore.ClaimOrCreate` 若已有“运行中且仍存活”的任务则返回 attached=true 并直接退出；否则创建/接管并返回 task_id
+// 2. 子项跟踪：每个文件通过 `taskstore.EnsureChildren` 记录为 model.TaskChild，重复执行同一 identity 时只重试失败或等待中的子项
+// 3. 使用 `bdtools.BatchGetFileInfos` 批量获取文件详情（包含下载链接）
+// 4. 并发下载文件，默认并发数 3，所有文件共用一个聚合进度条
+// 5. 心跳与取消：循环每 5s `taskstore.Heartbeat` 更新进度，若返回 cancelRequested==true 则取消父上下文协作退出
+// 6. 子项状态：下载中的子项在心跳时刷新已下载字节，结束时写入状态与错误，取消的子项重置为等待中
+// 7. 无终端模式（req.Headless，daemon 执行）不启用 TUI 进度条，也不捕获 Ctrl+C
+// 8. 按 req.Progress 输出进度，plain 和 jsonl 方式按文件输出开始、进度、完成、跳过和失败
+func (h *FileHandler) downloadBatch(req *dto.DownloadReq, spec downloadBatchSpec) (bool, error) {
 	var totalBytes int64
-	for _, f := range detailFiles {
-		totalBytes += int64(f.Size)
+	for _, item := range spec.Items {
+		totalBytes += int64(item.File.Size)
 	}
-
-	// ===== Task detection & claim =====
-	// 任务检测：使用 taskstore.BuildIdentitySHA1 生成稳定 identity，用于任务的唯一标识
-	identity := taskstore.BuildIdentitySHA1("download", "dir", file.Path, outputDir)
-	// 任务数据：记录下载任务的相关信息
-	tdata := taskstore.DownloadData{Path: file.Path, OutputDir: outputDir, IsDir: true}
-	// 任务领取：若已有“运行中且仍存活”的任务则返回 attached=true 并直接退出；否则创建/接管并返回 task_id
-	taskID, attached, err := taskstore.ClaimOrCreate(context.Background(), taskstore.TaskTypeDownload, identity, "", totalBytes, tdata)
+	taskID, attached, err := taskstore.ClaimOrCreate(context.Background(), taskstore.TaskTypeDownload, spec.Identity, "", totalBytes, spec.Data)
 	if err != nil {
-		return "", err
+		return false, err
 	}
 	// 已有任务正在运行，直接退出
 	if attached {
 		fmt.Printf("已有下载任务正在运行: %s\n使用: bdpan task status %s 查看进度\n", taskID, taskID)
-		return "", nil
+		return true, nil
 	}
 	// 新创建/接管的任务，打印 task_id 便于用户后续通过命令查看
 	fmt.Printf("任务ID: %s\n", taskID)
+	mode := progress.Resolve(req.Progress)
+	reporter := progress.NewReporter(mode)
+	reporter.SetTaskID(taskID)
+	// 没有进度条和 Reporter 时逐个文件输出一行，none 方式下不输出
+	printLines := reporter == nil && mode != progress.ModeNone
+
+	// ===== Children =====
+	itemMap := make(map[string]downloadItem, len(spec.Items))
+	children := make([]model.TaskChild, 0, len(spec.Items))
+	for _, item := range spec.Items {
+		itemMap[item.File.Path] = item
+		children = append(children, model.TaskChild{Name: item.File.GetFilename(), Path: item.File.Path, Size: int64(item.File.Size)})
+	}
+	children, err = taskstore.EnsureChildren(context.Background(), taskID, children)
+	if err != nil {
+		_ = taskstore.Fail(context.Background(), taskID, err.Error())
+		return false, fmt.Errorf("记录子任务失败: %w", err)
+	}
+
+	// 已完成且本地文件仍存在的子项直接跳过，其余子项需要下载
+	// 需要刷新本地文件的策略不信任已完成状态，交给下载前的冲突检查
+	trustCompleted := spec.Policy == ConflictSkip || spec.Policy == ConflictRename
+	var (
+		skippedCount int
+		skippedBytes int64
+	)
+	childMap := make(map[string]model.TaskChild, len(children))
+	fsids := make([]uint64, 0, len(children))
+	for _, c := range children {
+		item := itemMap[c.Path]
+		if trustCompleted && c.Status == taskstore.StatusCompleted {
+			if _, err := os.Stat(item.TargetPath); err == nil {
+				skippedCount++
+				skippedBytes += c.Size
+				continue
+			}
+		}
+		childMap[c.Path] = c
+		fsids = append(fsids, item.File.FSID)
+	}
+	if skippedCount > 0 {
+		fmt.Printf("已完成 %d 个文件，继续下载剩余 %d 个文件\n", skippedCount, len(fsids))
+	}
+
+	// 2. 批量获取文件详情（获取 Dlink）
+	var detailFiles []*bdpan.FileInfo
+	if len(fsids) > 0 {
+		fmt.Println("正在获取文件下载链接...")
+		detailFiles, err = bdtools.BatchGetFileInfos(h.accessToken, fsids)
+		if err != nil {
+			_ = taskstore.Fail(context.Background(), taskID, err.Error())
+			return false, fmt.Errorf("获取文件详情失败: %w", err)
+		}
+	}
 
 	// 3. 并发下载文件
 	var (
@@ -302,14 +572,16 @@ func (h *FileHandler) DownloadDir(file *bdpan.FileInfo, req *dto.DownloadReq) (s
 		concurrency      = 3
 		sem              = make(chan struct{}, concurrency)
 		errChan          = make(chan error, len(detailFiles))
-		successCount     int
+		successCount     = skippedCount
 		failedCount      int
 		mu               sync.Mutex
 		globalMu         sync.Mutex
-		globalDownloaded int64
+		globalDownloaded = skippedBytes
 		progWriter       *downloader.ProgressWriter
 		activeMu         sync.Mutex
 		activeSet        = make(map[string]struct{})
+		childMu          sync.Mutex
+		childDownloaded  = make(map[int64]int64)
 	)
 
 	buildActiveStatus := func() string {
@@ -332,23 +604,26 @@ func (h *FileHandler) DownloadDir(file *bdpan.FileInfo, req *dto.DownloadReq) (s
 	parentCtx, parentCancel := context.WithCancel(context.Background())
 	defer parentCancel()
 
-	// 捕获 Ctrl+C，统一取消
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
-	canceledOnce := make(chan struct{}, 1)
-	go func() {
-		<-sigCh
-		select {
-		case canceledOnce <- struct{}{}:
-			parentCancel()
-		default:
-		}
-	}()
+	// 捕获 Ctrl+C，统一取消；无终端模式下由 daemon 通过 CancelRequested 取消
+	if !req.Headless {
+		sigCh := make(chan os.Signal, 1)
+		signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+		defer signal.Stop(sigCh)
+		canceledOnce := make(chan struct{}, 1)
+		go func() {
+			<-sigCh
+			select {
+			case canceledOnce <- struct{}{}:
+				parentCancel()
+			default:
+			}
+		}()
+	}
 
 	// 启动聚合 TUI 进度条（按 q 或 Ctrl+C 取消所有）
-	if totalBytes > 0 {
-		// 仅传入文件夹名，避免标题出现重复的“下载:”前缀
-		model := downloader.NewProgressModel(filepath.Base(file.Path), totalBytes, parentCancel)
+	if totalBytes > 0 && len(detailFiles) > 0 && mode == progress.ModeTUI && !req.Headless {
+		// 仅传入名称，避免标题出现重复的“下载:”前缀
+		model := downloader.NewProgressModel(spec.Title, totalBytes, parentCancel)
 		p := tea.NewProgram(model)
 		progWriter = downloader.NewProgressWriter(p, totalBytes)
 		go func() {
@@ -356,6 +631,7 @@ func (h *FileHandler) DownloadDir(file *bdpan.FileInfo, req *dto.DownloadReq) (s
 				logger.Errorf("目录进度条运行错误: %v", err)
 			}
 		}()
+		progWriter.UpdateProgress(globalDownloaded, totalBytes)
 	}
 
 	fmt.Printf("开始并发下载（并发数: %d）\n", concurrency)
@@ -365,7 +641,7 @@ func (h *FileHandler) DownloadDir(file *bdpan.FileInfo, req *dto.DownloadReq) (s
 	hbQuit := make(chan struct{})
 	go func() {
 		defer hbTicker.Stop()
-		var last int64
+		last := skippedBytes
 		for {
 			select {
 			case <-hbQuit:
@@ -396,6 +672,16 @@ func (h *FileHandler) DownloadDir(file *bdpan.FileInfo, req *dto.DownloadReq) (s
 				if cancelReq {
 					parentCancel()
 				}
+				// 刷新下载中子项的已下载字节
+				childMu.Lock()
+				snapshot := make(map[int64]int64, len(childDownloaded))
+				for id, n := range childDownloaded {
+					snapshot[id] = n
+				}
+				childMu.Unlock()
+				for id, n := range snapshot {
+					_ = taskstore.UpdateChildProgress(context.Background(), id, n)
+				}
 			}
 		}
 	}()
@@ -407,18 +693,29 @@ func (h *FileHandler) DownloadDir(file *bdpan.FileInfo, req *dto.DownloadReq) (s
 			sem <- struct{}{}
 			defer func() { <-sem }()
 
-			// 计算目标文件路径（保持相对路径结构）
-			relPath := strings.TrimPrefix(fileInfo.Path, file.Path)
-			relPath = strings.TrimPrefix(relPath, "/")
-			targetPath := filepath.Join(outputDir, relPath)
+			item := itemMap[fileInfo.Path]
+			relPath := item.RelPath
+			child := childMap[fileInfo.Path]
 
-			// 检查文件是否已存在
-			if _, err := os.Stat(targetPath); err == nil {
+			// 按冲突策略检查本地已存在的文件，文件详情中没有 local_mtime，使用列表中的文件
+			targetPath, skip, err := h.resolveConflict(spec.Policy, req.PreserveTimes, item.File, item.TargetPath)
+			if err != nil {
+				reporter.Fail(fileInfo.Path, err)
+				_ = taskstore.UpdateChild(context.Background(), child.ID, taskstore.StatusFailed, 0, err.Error())
+				errChan <- fmt.Errorf("下载 %s 失败: %w", fileInfo.Path, err)
+				mu.Lock()
+				failedCount++
+				mu.Unlock()
+				return
+			}
+			if skip {
+				reporter.Skip(fileInfo.Path)
 				if progWriter != nil {
 					progWriter.UpdateStatus("✓ 文件已存在，跳过: " + relPath)
-				} else {
+				} else if printLines {
 					fmt.Printf("✓ 文件已存在，跳过: %s\n", relPath)
 				}
+				_ = taskstore.UpdateChild(context.Background(), child.ID, taskstore.StatusCompleted, child.Size, "")
 				mu.Lock()
 				successCount++
 				mu.Unlock()
@@ -431,12 +728,28 @@ func (h *FileHandler) DownloadDir(file *bdpan.FileInfo, req *dto.DownloadReq) (s
 				activeSet[relPath] = struct{}{}
 				activeMu.Unlock()
 				progWriter.UpdateStatus(buildActiveStatus())
-			} else {
+			} else if printLines {
 				fmt.Printf("↓ 下载: %s\n", relPath)
 			}
-			if err := h.downloadSingleNoTUIWithAgg(parentCtx, fileInfo, targetPath, req.IsSync, progWriter, &globalDownloaded, &globalMu, totalBytes); err != nil {
+			_ = taskstore.UpdateChild(context.Background(), child.ID, taskstore.StatusRunning, child.Downloaded, "")
+			reporter.Start(fileInfo.Path, int64(fileInfo.Size))
+			onProgress := func(downloaded int64) {
+				childMu.Lock()
+				childDownloaded[child.ID] = downloaded
+				childMu.Unlock()
+				reporter.Update(fileInfo.Path, downloaded)
+			}
+			err = h.downloadSingleNoTUIWithAgg(parentCtx, fileInfo, targetPath, req.IsSync, progWriter, &globalDownloaded, &globalMu, totalBytes, onProgress)
+			childMu.Lock()
+			downloaded := childDownloaded[child.ID]
+			delete(childDownloaded, child.ID)
+			childMu.Unlock()
+			if err != nil {
+				reporter.Fail(fileInfo.Path, err)
 				// 用户取消不重复打印
 				if errors.Is(err, context.Canceled) {
+					// 取消的子项重置为等待中，下次执行时继续下载
+					_ = taskstore.UpdateChild(context.Background(), child.ID, taskstore.StatusPending, downloaded, "")
 					if progWriter != nil {
 						activeMu.Lock()
 						delete(activeSet, relPath)
@@ -446,6 +759,7 @@ func (h *FileHandler) DownloadDir(file *bdpan.FileInfo, req *dto.DownloadReq) (s
 					errChan <- err
 					return
 				}
+				_ = taskstore.UpdateChild(context.Background(), child.ID, taskstore.StatusFailed, downloaded, err.Error())
 				errChan <- fmt.Errorf("下载 %s 失败: %w", fileInfo.Path, err)
 				mu.Lock()
 				failedCount++
@@ -455,10 +769,15 @@ func (h *FileHandler) DownloadDir(file *bdpan.FileInfo, req *dto.DownloadReq) (s
 					delete(activeSet, relPath)
 					activeMu.Unlock()
 					progWriter.UpdateStatus(buildActiveStatus())
-				} else {
+				} else if printLines {
 					fmt.Printf("✗ 下载失败: %s - %v\n", relPath, err)
 				}
 			} else {
+				if err := applyModTime(targetPath, item.File, req.PreserveTimes); err != nil {
+					logger.Errorf("%s: %v", targetPath, err)
+				}
+				reporter.Done(fileInfo.Path)
+				_ = taskstore.UpdateChild(context.Background(), child.ID, taskstore.StatusCompleted, child.Size, "")
 				mu.Lock()
 				successCount++
 				mu.Unlock()
@@ -467,7 +786,7 @@ func (h *FileHandler) DownloadDir(file *bdpan.FileInfo, req *dto.DownloadReq) (s
 					delete(activeSet, relPath)
 					activeMu.Unlock()
 					progWriter.UpdateStatus(buildActiveStatus())
-				} else {
+				} else if printLines {
 					fmt.Printf("✓ 下载完成: %s\n", relPath)
 				}
 			}
@@ -480,13 +799,15 @@ func (h *FileHandler) DownloadDir(file *bdpan.FileInfo, req *dto.DownloadReq) (s
 	// 统计结果
 	fmt.Println("\n================================")
 	fmt.Printf("下载完成！\n")
-	fmt.Printf("总数: %d, 成功: %d, 失败: %d\n", len(detailFiles), successCount, failedCount)
-	fmt.Printf("保存目录: %s\n", outputDir)
+	fmt.Printf("总数: %d, 成功: %d, 失败: %d\n", len(children), successCount, failedCount)
+	if spec.SaveDir != "" {
+		fmt.Printf("保存目录: %s\n", spec.SaveDir)
+	}
 	fmt.Println("================================")
 
 	// 结束聚合进度条
 	if progWriter != nil {
-		if successCount == len(detailFiles) {
+		if successCount == len(children) {
 			progWriter.Complete()
 		}
 		// 给 UI 一点渲染时间
@@ -502,7 +823,7 @@ func (h *FileHandler) DownloadDir(file *bdpan.FileInfo, req *dto.DownloadReq) (s
 			}
 			close(hbQuit)
 			_ = taskstore.SetCanceled(context.Background(), taskID)
-			return outputDir, context.Canceled
+			return false, context.Canceled
 		}
 		// 返回第一个非取消错误
 		if progWriter != nil {
@@ -510,13 +831,13 @@ func (h *FileHandler) DownloadDir(file *bdpan.FileInfo, req *dto.DownloadReq) (s
 			time.Sleep(100 * time.Millisecond)
 		}
 		close(hbQuit)
-		_ = taskstore.Fail(context.Background(), taskID, e.Error())
-		return outputDir, e
+		_ = taskstore.Fail(context.Background(), taskID, fmt.Sprintf("%d 个文件下载失败，使用 bdpan task status %s 查看: %v", failedCount, taskID, e))
+		return false, e
 	}
 
 	close(hbQuit)
 	_ = taskstore.Complete(context.Background(), taskID)
-	return outputDir, nil
+	return false, nil
 }
 
 // downloadSingleNoTUI 目录下载场景下的单文件下载（无 TUI），可继承外部上下文
@@ -526,17 +847,18 @@ func (h *FileHandler) downloadSingleNoTUI(ctx context.Context, file *bdpan.FileI
 		return err
 	}
 
-	// 创建缓存目录
+	// 旧版本以文件 md5 命名的分片缓存目录，开始下载时由下载器清理
 	cacheDir := filepath.Join(config.GetCacheDir(), file.MD5)
 
 	// 4. 创建分片下载器
 	d := downloader.NewChunkDownloader(file.Dlink, targetPath, cacheDir)
+	d.SetURLRefresher(h.dlinkRefresher(file.FSID))
+	d.SetIdentity(fmt.Sprintf("%d:%s", file.FSID, file.MD5))
+	d.EnablePartFile()
 
-	// 5. 设置并发数
+	// 5. 同步模式固定单线程，否则由下载器根据下载速度自动调整并发数和分片大小
 	if isSync {
 		d.SetConcurrency(1)
-	} else {
-		d.SetConcurrency(3)
 	}
 
 	// 无 TUI，不设置进度回调
@@ -558,40 +880,45 @@ func (h *FileHandler) downloadSingleNoTUIWithAgg(
 	globalDownloaded *int64,
 	globalMu *sync.Mutex,
 	totalBytes int64,
+	onProgress func(downloaded int64),
 ) error {
 	// 确保输出目录存在
 	if err := os.MkdirAll(filepath.Dir(targetPath), 0o755); err != nil {
 		return err
 	}
-	// 创建缓存目录
+	// 旧版本以文件 md5 命名的分片缓存目录，开始下载时由下载器清理
 	cacheDir := filepath.Join(config.GetCacheDir(), file.MD5)
 
 	d := downloader.NewChunkDownloader(file.Dlink, targetPath, cacheDir)
+	d.SetURLRefresher(h.dlinkRefresher(file.FSID))
+	d.SetIdentity(fmt.Sprintf("%d:%s", file.FSID, file.MD5))
+	d.EnablePartFile()
 	if ctx != nil {
 		d.SetContext(ctx, nil)
 	}
 	if isSync {
 		d.SetConcurrency(1)
-	} else {
-		d.SetConcurrency(3)
 	}
 
-	// 将该文件的进度累计到全局
+	// 将该文件的进度累计到全局，心跳使用全局进度，未启用 TUI 时同样累计
 	var last int64
 	d.SetProgressFunc(func(downloaded, _ int64) {
-		if progWriter == nil {
-			return
+		if onProgress != nil {
+			onProgress(downloaded)
 		}
+		globalMu.Lock()
 		delta := downloaded - last
 		if delta <= 0 {
+			globalMu.Unlock()
 			return
 		}
 		last = downloaded
-		globalMu.Lock()
 		*globalDownloaded += delta
 		current := *globalDownloaded
 		globalMu.Unlock()
-		progWriter.UpdateProgress(current, totalBytes)
+		if progWriter != nil {
+			progWriter.UpdateProgress(current, totalBytes)
+		}
 	})
 
 	return d.Start()
@@ -610,10 +937,10 @@ func (h *FileHandler) downloadSingleNoTUIWithAgg(
 // 实现逻辑:
 //
 // 1. 确定输出文件路径（优先级: OutputPath > OutputDir + filename）
-// 2. 处理文件名冲突（数字后缀递增）
-// 3. 创建缓存目录（使用 config.GetCacheDir() + file.MD5），下载完成后缓存目录需要一并删除
-// 4. 创建分片下载器，设置分片大小为 5MB
-// 5. 设置并发数（同步模式为 1，异步模式为 4）
+// 2. 按 req.OnConflict 处理本地已存在的文件，默认 rename（数字后缀递增），跳过时返回空路径，提示按 req.Progress 输出
+// 3. 分片直接写入预分配的 <输出文件>.bdpan-part，下载完成后重命名为输出文件，不再使用缓存目录合并，旧版本留下的分片缓存目录在开始下载时清理
+// 4. 创建分片下载器，分片大小由下载器根据下载速度在 1MB 到 50MB 之间调整
+// 5. 同步模式并发数固定为 1，否则由下载器根据下载速度自动调整
 // 6. 设置进度回调函数，显示下载进度
 // 7. 开始下载，支持断点续传
 // 8. 进度条样式使用 https://github.com/charmbracelet/bubbletea/tree/main/examples/progress-download
@@ -622,6 +949,9 @@ func (h *FileHandler) downloadSingleNoTUIWithAgg(
 // 11. 任务检测（幂等）：使用 `taskstore.BuildIdentitySHA1("download","file", 源文件Path, 输出目录)` 生成稳定 identity，避免因目标文件重命名导致命中失败
 // 12. 任务领取：`taskstore.ClaimOrCreate` 若已有“运行中且仍存活”的任务则返回 attached=true 并直接退出；否则创建/接管并返回 task_id
 // 13. 心跳与取消：循环每 5s `taskstore.Heartbeat` 更新进度，若返回 cancelRequested==true 则取消下载上下文
+// 14. 按 req.Progress 输出进度，只有 tui 方式且不是无终端模式（req.Headless，daemon 执行）时启用 TUI 进度条
+// 15. 分片失败时由下载器按指数退避重试，Dlink 过期时通过 h.dlinkRefresher 刷新
+// 16. 下载完成后按 req.PreserveTimes 将网盘中的修改时间设置到输出文件
 func (h *FileHandler) DownloadFile(file *bdpan.FileInfo, req *dto.DownloadReq) (string, error) {
 	// 1. 确定输出文件路径
 	var outputPath string
@@ -633,9 +963,26 @@ func (h *FileHandler) DownloadFile(file *bdpan.FileInfo, req *dto.DownloadReq) (
 	}
 
 	// 2. 处理文件名冲突
-	outputPath = h.resolveOutputPath(outputPath)
+	policy, err := checkConflictPolicy(req.OnConflict, ConflictRename)
+	if err != nil {
+		return "", err
+	}
+	outputPath, skip, err := h.resolveConflict(policy, req.PreserveTimes, file, outputPath)
+	if err != nil {
+		return "", err
+	}
+	mode := progress.Resolve(req.Progress)
+	reporter := progress.NewReporter(mode)
+	if skip {
+		// 与批量下载一致，plain 和 jsonl 方式由 Reporter 输出跳过事件，none 方式下不输出
+		if reporter == nil && mode != progress.ModeNone {
+			fmt.Printf("✓ 文件已存在，跳过: %s\n", outputPath)
+		}
+		reporter.Skip(file.Path)
+		return "", nil
+	}
 
-	// 3. 创建缓存目录
+	// 3. 旧版本以文件 md5 命名的分片缓存目录，开始下载时由下载器清理
 	cacheDir := filepath.Join(config.GetCacheDir(), file.MD5)
 
 	// ===== Task detection & claim =====
@@ -643,7 +990,7 @@ func (h *FileHandler) DownloadFile(file *bdpan.FileInfo, req *dto.DownloadReq) (
 	// 导致重复执行时无法命中同一任务。这里使用“源文件路径 + 输出目录”作为幂等键。
 	identity := taskstore.BuildIdentitySHA1("download", "file", file.Path, req.OutputDir)
 	// Data 中仍记录实际的目标路径，便于展示与排查。
-	tdata := taskstore.DownloadData{FSID: file.FSID, Path: file.Path, MD5: file.MD5, TargetPath: outputPath, OutputDir: req.OutputDir, IsDir: false}
+	tdata := taskstore.DownloadData{FSID: file.FSID, Path: file.Path, MD5: file.MD5, TargetPath: outputPath, OutputDir: req.OutputDir, IsDir: false, OnConflict: req.OnConflict, PreserveTimes: req.PreserveTimes}
 	taskID, attached, err := taskstore.ClaimOrCreate(context.Background(), taskstore.TaskTypeDownload, identity, "", int64(file.Size), tdata)
 	if err != nil {
 		return "", err
@@ -654,24 +1001,29 @@ func (h *FileHandler) DownloadFile(file *bdpan.FileInfo, req *dto.DownloadReq) (
 	}
 	// 新创建/接管的任务，打印 task_id 便于用户后续通过命令查看
 	fmt.Printf("任务ID: %s\n", taskID)
+	reporter.SetTaskID(taskID)
 
 	// 4. 创建分片下载器
 	d := downloader.NewChunkDownloader(file.Dlink, outputPath, cacheDir)
+	d.SetURLRefresher(h.dlinkRefresher(file.FSID))
+	d.SetIdentity(fmt.Sprintf("%d:%s", file.FSID, file.MD5))
+	d.EnablePartFile()
 
-	// 5. 设置并发数
+	// 5. 同步模式固定单线程，否则由下载器根据下载速度自动调整并发数和分片大小
 	if req.IsSync {
 		d.SetConcurrency(1)
-	} else {
-		d.SetConcurrency(4)
 	}
 
 	// 6. 进度回调 + 启用 TUI
-	var singleDownloaded int64
+	var singleDownloaded atomic.Int64
 	d.SetProgressFunc(func(downloaded, _ int64) {
-		singleDownloaded = downloaded
+		singleDownloaded.Store(downloaded)
+		reporter.Update(file.Path, downloaded)
 	})
-	_, filename := filepath.Split(file.Path)
-	d.EnableTUI(filename)
+	if mode == progress.ModeTUI && !req.Headless {
+		_, filename := filepath.Split(file.Path)
+		d.EnableTUI(filename)
+	}
 
 	// 7. Heartbeat 5s + cancel check
 	ctx, cancel := context.WithCancel(context.Background())
@@ -685,7 +1037,7 @@ func (h *FileHandler) DownloadFile(file *bdpan.FileInfo, req *dto.DownloadReq) (
 			case <-ctx.Done():
 				return
 			case <-hbTicker.C:
-				cur := singleDownloaded
+				cur := singleDownloaded.Load()
 				delta := cur - last
 				if delta < 0 {
 					delta = 0
@@ -711,10 +1063,20 @@ func (h *FileHandler) DownloadFile(file *bdpan.FileInfo, req *dto.DownloadReq) (
 	}()
 
 	// 8. 开始下载
+	reporter.Start(file.Path, int64(file.Size))
 	if err := d.Start(); err != nil {
+		reporter.Fail(file.Path, err)
+		if errors.Is(err, context.Canceled) {
+			_ = taskstore.SetCanceled(context.Background(), taskID)
+			return "", err
+		}
 		_ = taskstore.Fail(context.Background(), taskID, err.Error())
 		return "", fmt.Errorf("下载失败: %w", err)
 	}
+	if err := applyModTime(outputPath, file, req.PreserveTimes); err != nil {
+		logger.Errorf("%s: %v", outputPath, err)
+	}
+	reporter.Done(file.Path)
 
 	_ = taskstore.Complete(context.Background(), taskID)
 	return outputPath, nil
@@ -726,6 +1088,30 @@ func (h *FileHandler) GetFileByPath(path string) (*bdpan.FileInfo, error) {
 	return bdtools.GetFileByPath(h.accessToken, path)
 }
 
+// dlinkRefresher 返回通过 FSID 重新获取 Dlink 的函数，供下载器在链接过期时调用
+func (h *FileHandler) dlinkRefresher(fsid uint64) func() (string, error) {
+	return func() (string, error) {
+		info, err := bdtools.GetFileInfo(h.accessToken, fsid)
+		if err != nil {
+			return "", err
+		}
+		return info.Dlink, nil
+	}
+}
+
+// refreshFileInfo 根据任务数据重新获取文件详情，用于刷新过期的 Dlink
+// 优先使用 FSID 查询，FSID 缺失或查询失败时按路径查找
+func (h *FileHandler) refreshFileInfo(data taskstore.DownloadData) (*bdpan.FileInfo, error) {
+	if data.FSID > 0 {
+		info, err := bdtools.GetFileInfo(h.accessToken, data.FSID)
+		if err == nil && info.Path != "" {
+			return info, nil
+		}
+		logger.Infof("通过 FSID %d 获取文件详情失败，改为按路径查找: %v", data.FSID, err)
+	}
+	return h.GetFileByPath(data.Path)
+}
+
 func (h *FileHandler) CmdDelete(req *dto.DeleteReq) error {
 	var info *bdpan.FileInfo
 	var err error
@@ -845,9 +1231,19 @@ func (h *FileHandler) Limit(l int32) *FileHandler {
 }
 
 // 上传文件夹
+//
+// 以 `taskstore.BuildIdentitySHA1("upload","dir", 本地绝对路径, 远程目录)` 领取上传任务，
+// 每个文件记录为 model.TaskChild，心跳上报已上传字节，收到取消请求或 Ctrl+C 时在下一个分片前退出。
+// 遍历时按 req.Filter 和各级目录中的 .bdpanignore 过滤，被排除的文件夹整个跳过。
+// 有文件超过当前用户的单文件上限时不领取任务，返回所有超限的文件。
+// 只有 req.Progress 为 tui 时显示进度条，plain 和 jsonl 方式按文件输出进度
 func (h *FileHandler) UploadDir(req *dto.UploadReq, fromDir, toDir string) error {
 	logger.Printf("上传文件夹 %s => %s", fromDir, toDir)
 	req.IsRewrite = true
+	fil, err := filter.New(req.Filter)
+	if err != nil {
+		return err
+	}
 
 	begin := time.Now()
 	existFiles, err := bdtools.GetDirAllFiles(h.accessToken, toDir)
@@ -875,7 +1271,9 @@ func (h *FileHandler) UploadDir(req *dto.UploadReq, fromDir, toDir string) error
 	}
 
 	fromPaths := make([]any, 0)
-	err = filepath.Walk(fromDir,
+	children := make([]model.TaskChild, 0)
+	var totalBytes int64
+	err = fil.Walk(fromDir,
 		func(pathStr string, info os.FileInfo, err error) error {
 			if err != nil {
 				return err
@@ -885,30 +1283,125 @@ func (h *FileHandler) UploadDir(req *dto.UploadReq, fromDir, toDir string) error
 				return nil
 			}
 			fromPaths = append(fromPaths, pathStr)
+			children = append(children, model.TaskChild{Name: info.Name(), Path: pathStr, Size: info.Size()})
+			totalBytes += info.Size()
 			return nil
 		})
 	if err != nil {
 		return err
 	}
+	if len(fromPaths) == 0 {
+		logger.Printf("没有需要上传的文件: %s", fromDir)
+		return nil
+	}
+	// 有文件超过单文件上限时不领取任务，一次列出所有超限的文件
+	var sizeErrs []error
+	for _, c := range children {
+		if err := h.checkUploadSize(c.Path, c.Size); err != nil {
+			sizeErrs = append(sizeErrs, err)
+		}
+	}
+	if err := errors.Join(sizeErrs...); err != nil {
+		return err
+	}
+
+	// ===== Task detection & claim =====
+	localDir, err := filepath.Abs(fromDir)
+	if err != nil {
+		return err
+	}
+	identity := taskstore.BuildIdentitySHA1("upload", "dir", localDir, toDir)
+	tdata := taskstore.UploadData{LocalPath: localDir, RemotePath: toDir, IsDir: true}
+	taskID, attached, err := taskstore.ClaimOrCreate(context.Background(), taskstore.TaskTypeUpload, identity, "", totalBytes, tdata)
+	if err != nil {
+		return err
+	}
+	if attached {
+		fmt.Printf("已有上传任务正在运行: %s\n使用: bdpan task status %s 查看进度\n", taskID, taskID)
+		return nil
+	}
+	fmt.Printf("任务ID: %s\n", taskID)
+	mode := progress.Resolve(req.Progress)
+	reporter := progress.NewReporter(mode)
+	reporter.SetTaskID(taskID)
+	children, err = taskstore.EnsureChildren(context.Background(), taskID, children)
+	if err != nil {
+		_ = taskstore.Fail(context.Background(), taskID, err.Error())
+		return fmt.Errorf("记录子任务失败: %w", err)
+	}
+	childMap := make(map[string]model.TaskChild, len(children))
+	for _, c := range children {
+		childMap[c.Path] = c
+	}
 
-	tools.ExecLoop(fromPaths, len(fromPaths), func(total, index int, item any) error {
+	// 收到取消请求或 Ctrl+C 时，正在上传的文件在下一个分片前退出
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	var uploaded atomic.Int64
+	stopHeartbeat := startTaskHeartbeat(taskID, totalBytes, uploaded.Load, cancel)
+	defer stopHeartbeat()
+
+	loopArgs := []any{tools.Printf(logger.Infof)}
+	if mode == progress.ModeTUI {
+		loopArgs = append(loopArgs, gotasker.NewBubblesProgressBar())
+	}
+	var doneCount int
+	err = tools.ExecLoop(fromPaths, len(fromPaths), func(total, index int, item any) error {
 		fromPath := item.(string)
 		// logger.Printf("[%4d/%d] %s", index, total, fromPath)
 		toPath := path.Join(toDir, strings.ReplaceAll(fromPath, fromDir, ""))
 		toFile := existFileMap[toPath]
-		return h.UploadFile(
+		child := childMap[fromPath]
+		_ = taskstore.UpdateChild(context.Background(), child.ID, taskstore.StatusRunning, 0, "")
+		var fileUploaded int64
+		err := h.UploadFile(
 			req,
 			fromPath,
 			toPath,
 			toFile,
 			false,
 			tools.Printf(logger.Infof),
+			ctx,
+			reporter,
+			bdtools.OnPartUploaded(func(_ int, _ string, size int64) {
+				fileUploaded += size
+				uploaded.Add(size)
+			}),
+			onUploadRestart(func() {
+				uploaded.Add(-fileUploaded)
+				fileUploaded = 0
+			}),
 		)
-	},
-		tools.Printf(logger.Infof),
-		gotasker.NewBubblesProgressBar(),
-	)
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				_ = taskstore.UpdateChild(context.Background(), child.ID, taskstore.StatusPending, fileUploaded, "")
+			} else {
+				_ = taskstore.UpdateChild(context.Background(), child.ID, taskstore.StatusFailed, fileUploaded, err.Error())
+			}
+			return err
+		}
+		// 远程已存在而跳过的文件同样计入已上传字节
+		uploaded.Add(child.Size - fileUploaded)
+		_ = taskstore.UpdateChild(context.Background(), child.ID, taskstore.StatusCompleted, child.Size, "")
+		doneCount++
+		return nil
+	}, loopArgs...)
+	stopHeartbeat()
 
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			_ = taskstore.SetCanceled(context.Background(), taskID)
+			return err
+		}
+		_ = taskstore.Fail(context.Background(), taskID, fmt.Sprintf("上传失败，使用 bdpan task status %s 查看: %v", taskID, err))
+		return err
+	}
+	// ExecLoop 收到 Ctrl+C 时会在当前文件结束后停止，且不返回错误
+	if doneCount < len(fromPaths) {
+		_ = taskstore.SetCanceled(context.Background(), taskID)
+		return context.Canceled
+	}
+	_ = taskstore.Complete(context.Background(), taskID)
 	return nil
 }
 
@@ -925,6 +1418,20 @@ func (h *FileHandler) UploadDir(req *dto.UploadReq, fromDir, toDir string) error
 //	如果本地文件和远程文件md5不相同，则询问是否覆盖
 //
 // 上传成功后需要保存上传记录
+//
+// 不小于 256KB 的文件由 bdtools.UploadFile 先尝试秒传，网盘中已有相同内容时不上传文件内容，
+// 比对用的 md5 和秒传、分片上传需要的文件特征由 bdtools.HashFile 读取一次文件计算
+//
+// 大文件的 uploadid 和已上传的分片保存在 model.UploadSession 中，进程退出后再次上传同一文件时从第一个缺失的分片继续，
+// uploadid 已失效时删除会话并重新上传一次
+//
+// args 支持 tools.Printf 指定输出，*progress.Reporter 输出文件进度，
+// context.Context、bdtools.OnUploadID、bdtools.OnPartUploaded 透传给 bdtools.UploadFile，
+// onUploadRestart 在 uploadid 失效重新上传前回调，用于清零调用方累计的上传字节
+//
+// 只有 req.Progress 为 tui 时显示分片进度条，大文件同时上传的分片数优先使用 req.Concurrency，未指定时读取配置 upload.concurrency
+//
+// 分片大小和单文件上限按当前用户的会员类型确定，见 uploadVipType
 func (h *FileHandler) UploadFile(
 	req *dto.UploadReq,
 	fromPath, toPath string,
@@ -933,18 +1440,51 @@ func (h *FileHandler) UploadFile(
 	args ...any,
 ) error {
 	uPrintf := logger.Printf
+	concurrency := req.Concurrency
+	if concurrency <= 0 {
+		concurrency = config.GetUploadConcurrency()
+	}
+	vipType := h.uploadVipType()
+	uploadArgs := []any{
+		bdtools.Printf(logger.Infof),
+		bdtools.Limiter(ratelimit.Upload()),
+		bdtools.Concurrency(concurrency),
+		vipType,
+	}
+	if progress.Resolve(req.Progress) == progress.ModeTUI {
+		uploadArgs = append(uploadArgs, gotasker.NewBubblesProgressBar())
+	}
+	var (
+		reporter       *progress.Reporter
+		onUploadID     bdtools.OnUploadID
+		onPartUploaded bdtools.OnPartUploaded
+		onRestart      onUploadRestart
+	)
 	for _, arg := range args {
 		switch val := arg.(type) {
 		case tools.Printf:
 			uPrintf = val
+		case *progress.Reporter:
+			reporter = val
+		case bdtools.OnUploadID:
+			onUploadID = val
+		case bdtools.OnPartUploaded:
+			onPartUploaded = val
+		case onUploadRestart:
+			onRestart = val
+		case context.Context:
+			// 透传给 bdtools.UploadFile，用于取消
+			uploadArgs = append(uploadArgs, val)
 		}
 	}
 
 	uPrintf("上传文件 %s => %s", fromPath, toPath)
-	fileMD5, err := tools.Md5File(fromPath)
+	// 读取一次文件计算 md5 和上传需要的文件特征，上传时不再重复读取
+	hashes, err := bdtools.HashFile(fromPath, bdtools.PartSize(vipType))
 	if err != nil {
 		return err
 	}
+	fileMD5 := hashes.ContentMD5
 	logger.Infof("File MD5: %s", fileMD5)
 	if toFile != nil {
 		// 查找上传记录，直接比对 md5
@@ -960,6 +1500,7 @@ func (h *FileHandler) UploadFile(
 			logger.Infof("Remote File MD5: %s", remoteMD5)
 			if fileMD5 == remoteMD5 {
 				uPrintf("文件已存在: %s", toPath)
+				reporter.Skip(fromPath)
 				return nil
 			}
 		} else {
@@ -967,6 +1508,7 @@ func (h *FileHandler) UploadFile(
 			logger.Infof("获取到上次上传记录 FSID: %d md5: %s", existHistory.FSID, existHistory.MD5)
 			if existHistory.MD5 == toFile.MD5 {
 				uPrintf("文件已存在: %s", toPath)
+				reporter.Skip(fromPath)
 				return nil
 			}
 
@@ -984,22 +1526,60 @@ func (h *FileHandler) UploadFile(
 			}
 			if !confirm {
 				uPrintf("取消上传: %s", toPath)
+				reporter.Skip(fromPath)
 				return nil
 			}
 			req.IsRewrite = true
 		}
 	}
-	createFileRes, err := bdtools.UploadFile(
-		h.accessToken,
-		fromPath,
-		toPath,
-		gotasker.NewBubblesProgressBar(),
-		bdtools.Printf(logger.Infof),
+	session, err := newUploadSession(fromPath, toPath)
+	if err != nil {
+		return err
+	}
+	var sent int64
+	uploadArgs = append(uploadArgs,
+		hashes,
 		bdtools.IsRewrite(req.IsRewrite),
+		bdtools.OnUploadID(func(uploadID string, blockList []string) {
+			session.start(uploadID, blockList)
+			if onUploadID != nil {
+				onUploadID(uploadID, blockList)
+			}
+		}),
+		bdtools.OnPartUploaded(func(partseq int, md5 string, size int64) {
+			session.savePart(partseq, md5)
+			sent += size
+			reporter.Update(fromPath, sent)
+			if onPartUploaded != nil {
+				onPartUploaded(partseq, md5, size)
+			}
+		}),
 	)
+	reporter.Start(fromPath, session.size)
+	var createFileRes *bdpan.CreateFileRes
+	for {
+		fileArgs := uploadArgs
+		if session.resume != nil {
+			fileArgs = append(slices.Clip(fileArgs), *session.resume)
+		}
+		createFileRes, err = bdtools.UploadFile(h.accessToken, fromPath, toPath, fileArgs...)
+		if session.resume != nil && errors.Is(err, bdtools.ErrUploadIDInvalid) {
+			uPrintf("上次上传的 uploadid 已失效，重新上传: %v", err)
+			session.discard()
+			sent = 0
+			if onRestart != nil {
+				onRestart()
+			}
+			continue
+		}
+		break
+	}
 	if err != nil {
+		reporter.Fail(fromPath, err)
 		return err
 	}
+	session.discard()
+	reporter.Done(fromPath)
 	uPrintf("上传文件成功")
 	// 保存上传记录
 	saveHistory := &model.UploadHistory{
@@ -1027,21 +1607,117 @@ func (h *FileHandler) UploadFile(
 }
 
 func (h *FileHandler) CmdUpload(req *dto.UploadReq) error {
+	if err := progress.Check(req.Progress); err != nil {
+		return err
+	}
+	if err := setLimitRate(ratelimit.Upload(), req.LimitRate); err != nil {
+		return err
+	}
+	if _, err := filter.New(req.Filter); err != nil {
+		return err
+	}
 	fromPath := req.Local
 	toPath := req.Path
+	var err error
 	if tools.FileExists(fromPath) {
 		// 上传文件
 		toFile, _ := bdtools.GetFileByPath(h.accessToken, toPath)
-		return h.UploadFile(req, fromPath, toPath, toFile, true)
+		err = h.uploadFileTask(req, fromPath, toPath, toFile)
 	} else if tools.DirExists(fromPath) {
 		// 上传文件夹
-		return h.UploadDir(req, fromPath, toPath)
+		err = h.UploadDir(req, fromPath, toPath)
 	} else {
 		return fmt.Errorf("文件不存在: %s", fromPath)
 	}
+	if errors.Is(err, context.Canceled) {
+		fmt.Println("\n✗ 上传已取消")
+		return nil
+	}
+	return err
+}
+
+// uploadFileTask 以任务的形式上传单个文件
+//
+// 实现逻辑：
+//
+// 1. 文件超过当前用户的单文件上限时直接返回错误，不创建任务
+// 2. 任务检测（幂等）：使用 `taskstore.BuildIdentitySHA1("upload","file", 本地绝对路径, 远程路径)` 生成 identity
+// 3. 任务领取：`taskstore.ClaimOrCreate` 若已有“运行中且仍存活”的任务则直接退出
+// 4. 预上传成功后记录 uploadid，已上传的分片由 UploadFile 保存在 model.UploadSession 中，再次执行时续传
+// 5. 心跳与取消：每 5s 上报已上传字节，收到取消请求或 Ctrl+C 时在下一个分片前退出
+// 6. 按 req.Progress 输出进度，事件中的任务 ID 为本次领取的任务
+func (h *FileHandler) uploadFileTask(req *dto.UploadReq, fromPath, toPath string, toFile *bdpan.FileInfo) error {
+	localPath, err := filepath.Abs(fromPath)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return err
+	}
+	if err := h.checkUploadSize(fromPath, info.Size()); err != nil {
+		return err
+	}
+
+	identity := taskstore.BuildIdentitySHA1("upload", "file", localPath, toPath)
+	tdata := taskstore.UploadData{LocalPath: localPath, RemotePath: toPath}
+	taskID, attached, err := taskstore.ClaimOrCreate(context.Background(), taskstore.TaskTypeUpload, identity, "", info.Size(), tdata)
+	if err != nil {
+		return err
+	}
+	if attached {
+		fmt.Printf("已有上传任务正在运行: %s\n使用: bdpan task status %s 查看进度\n", taskID, taskID)
+		return nil
+	}
+	fmt.Printf("任务ID: %s\n", taskID)
+	reporter := progress.NewReporter(progress.Resolve(req.Progress))
+	reporter.SetTaskID(taskID)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	var uploaded atomic.Int64
+	stopHeartbeat := startTaskHeartbeat(taskID, info.Size(), uploaded.Load, cancel)
+	defer stopHeartbeat()
+
+	err = h.UploadFile(req, fromPath, toPath, toFile, true,
+		ctx,
+		reporter,
+		bdtools.OnUploadID(func(uploadID string, _ []string) {
+			tdata.UploadID = uploadID
+			_ = taskstore.UpdateData(context.Background(), taskID, tdata)
+		}),
+		bdtools.OnPartUploaded(func(_ int, _ string, size int64) {
+			uploaded.Add(size)
+		}),
+		onUploadRestart(func() {
+			uploaded.Store(0)
+		}),
+	)
+	if err == nil {
+		// 远程已存在而跳过上传时，同样视为全部完成
+		uploaded.Store(info.Size())
+	}
+	stopHeartbeat()
+
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			_ = taskstore.SetCanceled(context.Background(), taskID)
+			return err
+		}
+		_ = taskstore.Fail(context.Background(), taskID, err.Error())
+		return err
+	}
+	_ = taskstore.Complete(context.Background(), taskID)
+	return nil
 }
 
 func (h *FileHandler) CmdBackup(req *dto.BackupReq) error {
+	if err := progress.Check(req.Progress); err != nil {
+		return err
+	}
+	if err := setLimitRate(ratelimit.Upload(), req.LimitRate); err != nil {
+		return err
+	}
 	fromDir := req.Local
 	if !tools.FileExists(fromDir) && !tools.DirExists(fromDir) {
 		return fmt.Errorf("本地文件夹不存在: %s", fromDir)
@@ -1054,7 +1730,14 @@ func (h *FileHandler) CmdBackup(req *dto.BackupReq) error {
 	backupDir := path.Join(req.Path, "Backups", backupName)
 	uploadReq := dto.NewUploadReq()
 	uploadReq.IsRewrite = true
+	uploadReq.Filter = req.Filter
+	uploadReq.Progress = req.Progress
+	uploadReq.Concurrency = req.Concurrency
 	err := h.UploadDir(uploadReq, fromDir, backupDir)
+	if errors.Is(err, context.Canceled) {
+		fmt.Println("\n✗ 备份已取消")
+		return nil
+	}
 	if err != nil {
 		return err
 	}
@@ -1094,3 +1777,73 @@ func (h *FileHandler) resolveOutputPath(path string) string {
 		}
 	}
 }
+
+// startTaskHeartbeat 每 5s 通过 taskstore.Heartbeat 上报任务进度，返回 cancelRequested 时调用 cancel
+//
+// current 返回当前已处理的字节数；返回的 stop 停止心跳并写入最后一次进度，可重复调用
+func startTaskHeartbeat(taskID string, total int64, current func() int64, cancel context.CancelFunc) (stop func()) {
+	last := current()
+	report := func() bool {
+		cur := current()
+		speed := max(cur-last, 0) / 5
+		last = cur
+		prog := 0.0
+		if total > 0 {
+			prog = float64(cur) / float64(total)
+		}
+		var eta int64
+		if speed > 0 && total > cur {
+			eta = (total - cur) / speed
+		}
+		cancelReq, _ := taskstore.Heartbeat(context.Background(), taskID, taskstore.HeartbeatData{
+			DownloadedBytes: cur,
+			TotalBytes:      total,
+			Progress:        prog,
+			SpeedBPS:        speed,
+			ETASeconds:      eta,
+		})
+		return cancelReq
+	}
+
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(5 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-quit:
+				return
+			case <-ticker.C:
+				if report() {
+					cancel()
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(quit)
+			<-done
+			// 最后一次上报不计算速度
+			last = current()
+			report()
+		})
+	}
+}
+
+// setLimitRate 使用 --limit-rate 固定 limiter 的速率，覆盖配置中的 limit，为空时保持配置不变
+func setLimitRate(limiter *ratelimit.Limiter, rate string) error {
+	if rate == "" {
+		return nil
+	}
+	n, err := ratelimit.ParseRate(rate)
+	if err != nil {
+		return fmt.Errorf("--limit-rate: %w", err)
+	}
+	limiter.SetRate(n)
+	return nil
+}
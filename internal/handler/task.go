@@ -0,0 +1,473 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/huh"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/wxnacy/bdpan-cli/internal/common"
+	"github.com/wxnacy/bdpan-cli/internal/downloader"
+	"github.com/wxnacy/bdpan-cli/internal/dto"
+	"github.com/wxnacy/bdpan-cli/internal/logger"
+	"github.com/wxnacy/bdpan-cli/internal/model"
+	"github.com/wxnacy/bdpan-cli/internal/taskstore"
+	"github.com/wxnacy/go-tools"
+	"gorm.io/gorm"
+)
+
+var taskHandler *TaskHandler
+
+func GetTaskHandler() *TaskHandler {
+	if taskHandler == nil {
+		taskHandler = &TaskHandler{}
+	}
+	return taskHandler
+}
+
+// TaskHandler 处理 bdpan task 系列命令，数据来源于 taskstore 持久化的任务表
+type TaskHandler struct {
+}
+
+// 列出任务
+//
+// 实现逻辑：
+//
+// 1. req.Status 支持逗号分隔的多个状态，英文别名见 taskstore.ParseStatus，为空时列出全部
+// 2. 通过 taskstore.List 查询，按更新时间倒序
+// 3. 以表格形式输出，ID 只展示前 8 位，其他命令支持使用 ID 前缀
+func (h *TaskHandler) CmdList(req *dto.TaskListReq) error {
+	statuses, err := parseStatuses(req.Status)
+	if err != nil {
+		return err
+	}
+	tasks, err := taskstore.List(context.Background(), statuses...)
+	if err != nil {
+		return err
+	}
+	if len(tasks) == 0 {
+		fmt.Println("没有任务")
+		return nil
+	}
+
+	rows := [][]string{{"ID", "类型", "状态", "进度", "速度", "更新时间", "路径"}}
+	for _, t := range tasks {
+		rows = append(rows, []string{
+			shortTaskID(t.ID),
+			t.Type,
+			taskstore.DisplayStatus(t),
+			fmt.Sprintf("%.1f%%", t.Progress*100),
+			formatTaskSpeed(t),
+			formatTaskTime(t.UpdateTime),
+			taskSourcePath(&t),
+		})
+	}
+	printTable(rows)
+	return nil
+}
+
+// 查看任务详情
+//
+// 实现逻辑：
+//
+// 1. 通过 taskstore.Find 按 ID 或 ID 前缀查找任务
+// 2. 输出任务的来源、目标、进度、速度、剩余时间、执行进程和错误信息
+// 3. 文件夹任务额外输出子文件统计，并列出失败的文件及原因
+func (h *TaskHandler) CmdStatus(req *dto.TaskReq) error {
+	t, err := h.findTask(req.ID)
+	if err != nil {
+		return err
+	}
+
+	source, target, isDir := taskPaths(t)
+	rows := [][]string{
+		{"任务ID", t.ID},
+		{"类型", t.Type},
+		{"状态", taskstore.DisplayStatus(*t)},
+		{"来源", source},
+	}
+	if target != "" {
+		rows = append(rows, []string{"目标", target})
+	}
+	rows = append(rows,
+		[]string{"进度", fmt.Sprintf("%.1f%% (%s / %s)",
+			t.Progress*100,
+			tools.FormatSize(t.DownloadedBytes),
+			tools.FormatSize(t.TotalBytes))},
+		[]string{"速度", formatTaskSpeed(*t)},
+		[]string{"剩余", formatTaskETA(*t)},
+		[]string{"进程", fmt.Sprintf("%d@%s", t.PID, t.Hostname)},
+		[]string{"开始时间", formatTaskTime(t.StartTime)},
+		[]string{"更新时间", formatTaskTime(t.UpdateTime)},
+	)
+	if t.CancelRequested != 0 && t.Status == taskstore.StatusRunning {
+		if taskstore.IsPauseRequested(*t) {
+			rows = append(rows, []string{"暂停", "已请求暂停，等待执行端退出"})
+		} else {
+			rows = append(rows, []string{"取消", "已请求取消，等待执行端退出"})
+		}
+	}
+	if t.Error != "" {
+		rows = append(rows, []string{"错误", t.Error})
+	}
+	var failedChildren []model.TaskChild
+	if isDir {
+		counts, err := taskstore.CountChildren(context.Background(), t.ID)
+		if err != nil {
+			return err
+		}
+		var total int64
+		for _, n := range counts {
+			total += n
+		}
+		rows = append(rows, []string{"子文件", fmt.Sprintf("总数 %d，已完成 %d，失败 %d，等待中 %d，运行中 %d",
+			total,
+			counts[taskstore.StatusCompleted],
+			counts[taskstore.StatusFailed],
+			counts[taskstore.StatusPending],
+			counts[taskstore.StatusRunning])})
+		if counts[taskstore.StatusFailed] > 0 {
+			failedChildren, err = taskstore.ListChildren(context.Background(), t.ID, taskstore.StatusFailed)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	printTable(rows)
+
+	// 列出失败的子文件及原因
+	if len(failedChildren) > 0 {
+		fmt.Println("\n失败文件:")
+		failedRows := [][]string{{"路径", "大小", "已" + t.Type, "错误"}}
+		for _, c := range failedChildren {
+			failedRows = append(failedRows, []string{
+				c.Path,
+				tools.FormatSize(c.Size),
+				tools.FormatSize(c.Downloaded),
+				c.Error,
+			})
+		}
+		printTable(failedRows)
+	}
+	return nil
+}
+
+// 取消任务
+//
+// 实现逻辑：
+//
+// 1. 已结束的任务直接提示，不做修改
+// 2. 调用 taskstore.Cancel 请求协作式取消，执行端在下一次心跳（约 5 秒）时退出
+// 3. 排队中、已暂停和陈旧任务没有执行端响应，taskstore.Cancel 会直接将其置为已取消
+func (h *TaskHandler) CmdCancel(req *dto.TaskReq) error {
+	t, err := h.findTask(req.ID)
+	if err != nil {
+		return err
+	}
+	if taskstore.IsFinished(*t) {
+		fmt.Printf("任务已结束，无需取消: %s (%s)\n", t.ID, t.Status)
+		return nil
+	}
+	stale := taskstore.IsStale(*t)
+	if err := taskstore.Cancel(context.Background(), t.ID); err != nil {
+		return err
+	}
+	if t.Status == taskstore.StatusQueued {
+		fmt.Printf("已从队列中取消任务: %s\n", t.ID)
+	} else if t.Status == taskstore.StatusPaused {
+		fmt.Printf("已取消暂停的任务: %s\n", t.ID)
+	} else if stale {
+		fmt.Printf("任务已失去心跳，直接标记为已取消: %s\n", t.ID)
+	} else {
+		fmt.Printf("已请求取消任务: %s\n执行端将在下次心跳时退出，使用: bdpan task status %s 查看状态\n", t.ID, shortTaskID(t.ID))
+	}
+	return nil
+}
+
+// 暂停任务
+//
+// 实现逻辑：
+//
+// 1. 运行中的任务设置暂停请求，执行端在下一次心跳时退出，已下载的分片保留
+// 2. 排队中和陈旧任务直接置为已暂停
+// 3. 暂停的任务通过 bdpan task resume 继续
+func (h *TaskHandler) CmdPause(req *dto.TaskReq) error {
+	t, err := h.findTask(req.ID)
+	if err != nil {
+		return err
+	}
+	if t.Status == taskstore.StatusPaused {
+		fmt.Printf("任务已暂停: %s\n", t.ID)
+		return nil
+	}
+	if err := taskstore.Pause(context.Background(), t.ID); err != nil {
+		return err
+	}
+	if t.Status == taskstore.StatusRunning && !taskstore.IsStale(*t) {
+		fmt.Printf("已请求暂停任务: %s\n执行端将在下次心跳时退出，使用: bdpan task resume %s 继续\n", t.ID, shortTaskID(t.ID))
+	} else {
+		fmt.Printf("任务已暂停: %s\n使用: bdpan task resume %s 继续\n", t.ID, shortTaskID(t.ID))
+	}
+	return nil
+}
+
+// 清理已结束的任务
+//
+// 实现逻辑：
+//
+// 1. req.Status 为空时清理全部已完成、失败、已取消的任务，否则只清理指定状态
+// 2. 未指定 --yes 时需要确认
+// 3. 调用 taskstore.Purge 删除任务及其子项
+func (h *TaskHandler) CmdPurge(req *dto.TaskPurgeReq) error {
+	statuses, err := parseStatuses(req.Status)
+	if err != nil {
+		return err
+	}
+	if !req.Yes {
+		var confirm bool
+		err = huh.NewConfirm().
+			Title("是否确认清理已结束的任务记录").
+			Affirmative("Yes!").
+			Negative("No.").
+			Value(&confirm).WithTheme(huh.ThemeCatppuccin()).Run()
+		if err != nil {
+			return nil
+		}
+		if !confirm {
+			fmt.Println("取消清理")
+			return nil
+		}
+	}
+	count, err := taskstore.Purge(context.Background(), statuses...)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("已清理任务: %d 个\n", count)
+	return nil
+}
+
+// 恢复失败或已取消的下载任务
+//
+// 实现逻辑：
+//
+// 1. 指定 --all-failed 时恢复全部失败任务，否则恢复 req.ID 对应的任务
+// 2. 已完成或仍在运行的任务不恢复
+// 3. 从 taskstore.DownloadData 重建 dto.DownloadReq，保证 identity 与原任务一致，ClaimOrCreate 会接管原任务
+// 4. 通过 FSID 重新获取文件详情以刷新 Dlink，FSID 缺失或失效时退回按路径查找
+// 5. 下载进度保存在目标文件旁的 .bdpan-part 和分片清单中，ChunkDownloader 会跳过已完成的分片继续下载
+func (h *TaskHandler) CmdResume(req *dto.TaskResumeReq) error {
+	var tasks []model.Task
+	if req.AllFailed {
+		var err error
+		tasks, err = taskstore.List(context.Background(), taskstore.StatusFailed)
+		if err != nil {
+			return err
+		}
+		if len(tasks) == 0 {
+			fmt.Println("没有失败的任务")
+			return nil
+		}
+	} else {
+		t, err := h.findTask(req.ID)
+		if err != nil {
+			return err
+		}
+		tasks = append(tasks, *t)
+	}
+
+	var failed int
+	for i, t := range tasks {
+		if len(tasks) > 1 {
+			fmt.Printf("\n[%d/%d] ", i+1, len(tasks))
+		}
+		if err := h.resumeTask(&t, req.IsSync); err != nil {
+			if len(tasks) == 1 {
+				return err
+			}
+			failed++
+			logger.Printf("恢复任务 %s 失败: %v", shortTaskID(t.ID), err)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d 个任务恢复失败", failed)
+	}
+	return nil
+}
+
+func (h *TaskHandler) resumeTask(t *model.Task, isSync bool) error {
+	if t.Type != taskstore.TaskTypeDownload {
+		return fmt.Errorf("暂不支持恢复%s任务: %s", t.Type, t.ID)
+	}
+	if t.Status == taskstore.StatusCompleted {
+		return fmt.Errorf("任务已完成，无需恢复: %s", t.ID)
+	}
+	if t.Status == taskstore.StatusQueued {
+		return fmt.Errorf("任务已在队列中，等待 bdpan daemon 执行: %s", t.ID)
+	}
+	if t.Status == taskstore.StatusRunning && !taskstore.IsStale(*t) {
+		return fmt.Errorf("任务正在运行: %s\n使用: bdpan task status %s 查看进度", t.ID, shortTaskID(t.ID))
+	}
+
+	var data taskstore.DownloadData
+	if err := taskstore.DecodeData(t, &data); err != nil {
+		return fmt.Errorf("读取任务数据失败: %w", err)
+	}
+	req := newDownloadReqFromData(data)
+	req.IsSync = isSync
+	fmt.Printf("恢复任务: %s\n", t.ID)
+
+	fh := GetFileHandler()
+	if len(data.Items) > 0 {
+		return fh.runDownloadList(req, data.Path, data.Items)
+	}
+	file, err := fh.refreshFileInfo(data)
+	if err != nil {
+		return fmt.Errorf("查找文件失败: %w", err)
+	}
+	if !data.IsDir && data.MD5 != "" && file.MD5 != data.MD5 {
+		fmt.Println("远程文件内容已变化，已下载的分片无法复用，将重新下载")
+	}
+	return fh.runDownload(file, req)
+}
+
+// newDownloadReqFromData 从任务数据重建下载请求
+//
+// identity 由源路径和输出目录组成，这里还原的输出目录需与原任务保持一致：
+// - 文件任务记录的是 req.OutputDir，目标路径使用记录的 TargetPath
+// - 文件夹任务记录的是 req.OutputDir/文件夹名，需要取其上级目录
+func newDownloadReqFromData(data taskstore.DownloadData) *dto.DownloadReq {
+	req := dto.NewDownloadReq()
+	req.Path = data.Path
+	req.OnConflict = data.OnConflict
+	req.PreserveTimes = data.PreserveTimes
+	if data.Filter != nil {
+		req.Filter = *data.Filter
+	}
+	if data.IsDir {
+		req.OutputDir = filepath.Dir(data.OutputDir)
+		req.IsRecursion = data.IsRecursion
+	} else {
+		req.OutputDir = data.OutputDir
+		req.OutputPath = data.TargetPath
+	}
+	return req
+}
+
+func (h *TaskHandler) findTask(id string) (*model.Task, error) {
+	if id == "" {
+		return nil, errors.New("请输入任务 ID")
+	}
+	t, err := taskstore.Find(context.Background(), id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("任务不存在: %s", id)
+	}
+	return t, err
+}
+
+func parseStatuses(s string) ([]string, error) {
+	statuses := make([]string, 0)
+	for _, v := range strings.Split(s, ",") {
+		if strings.TrimSpace(v) == "" {
+			continue
+		}
+		status, err := taskstore.ParseStatus(v)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+func shortTaskID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
+
+// taskSourcePath 任务的来源路径，取自 Task.Data
+func taskSourcePath(t *model.Task) string {
+	source, _, _ := taskPaths(t)
+	return source
+}
+
+// taskPaths 按任务类型解析 Task.Data，返回来源、目标以及是否为文件夹任务
+//
+// 下载任务来源为网盘路径，上传任务来源为本地路径
+func taskPaths(t *model.Task) (source, target string, isDir bool) {
+	if t.Type == taskstore.TaskTypeUpload {
+		var data taskstore.UploadData
+		if err := taskstore.DecodeData(t, &data); err != nil {
+			return "", "", false
+		}
+		return data.LocalPath, data.RemotePath, data.IsDir
+	}
+	var data taskstore.DownloadData
+	if err := taskstore.DecodeData(t, &data); err != nil {
+		return "", "", false
+	}
+	target = data.TargetPath
+	if target == "" {
+		target = data.OutputDir
+	}
+	return data.Path, target, data.IsDir
+}
+
+func formatTaskSpeed(t model.Task) string {
+	if taskstore.IsFinished(t) || taskstore.IsStale(t) {
+		return "-"
+	}
+	return downloader.FormatSpeed(t.SpeedBPS)
+}
+
+func formatTaskETA(t model.Task) string {
+	if taskstore.IsFinished(t) || taskstore.IsStale(t) {
+		return "-"
+	}
+	eta := t.ETASeconds
+	if eta <= 0 && t.SpeedBPS > 0 && t.TotalBytes > t.DownloadedBytes {
+		eta = (t.TotalBytes - t.DownloadedBytes) / t.SpeedBPS
+	}
+	if eta <= 0 {
+		return "--:--"
+	}
+	return downloader.FormatDuration(time.Duration(eta) * time.Second)
+}
+
+func formatTaskTime(s string) string {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return s
+	}
+	return t.Local().Format(common.FMT_DATETIME)
+}
+
+// printTable 按列宽左对齐输出表格，使用 lipgloss.Width 计算中文宽度
+func printTable(rows [][]string) {
+	widths := make([]int, 0)
+	for _, row := range rows {
+		for i, cell := range row {
+			if i >= len(widths) {
+				widths = append(widths, 0)
+			}
+			if w := lipgloss.Width(cell); w > widths[i] {
+				widths[i] = w
+			}
+		}
+	}
+	for _, row := range rows {
+		var b strings.Builder
+		for i, cell := range row {
+			b.WriteString(cell)
+			if i < len(row)-1 {
+				b.WriteString(strings.Repeat(" ", widths[i]-lipgloss.Width(cell)+2))
+			}
+		}
+		fmt.Println(b.String())
+	}
+}
@@ -0,0 +1,389 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	ui "github.com/gizak/termui/v3"
+	"github.com/gizak/termui/v3/widgets"
+	"github.com/wxnacy/bdpan-cli/internal/downloader"
+	"github.com/wxnacy/bdpan-cli/internal/dto"
+	"github.com/wxnacy/bdpan-cli/internal/model"
+	"github.com/wxnacy/bdpan-cli/internal/taskstore"
+	"github.com/wxnacy/go-tools"
+)
+
+const (
+	// topHistorySize 每个任务保留的速度采样数
+	topHistorySize = 60
+	// topSparkWidth 表格中速度趋势列的宽度
+	topSparkWidth = 16
+)
+
+var sparkLevels = []rune("▁▂▃▄▅▆▇█")
+
+// 任务看板
+//
+// 实现逻辑：
+//
+// 1. 每隔 req.Interval 秒查询 tasks 表中运行中、排队中和已暂停的任务，跨进程展示
+// 2. 表格展示进度条、速度、剩余时间和速度趋势，速度在任务心跳时间变化时采样
+// 3. 下方展示选中任务的详情、进度和速度曲线，文件夹任务额外展示子文件统计与正在传输的文件
+// 4. 按键：j/k 或方向键选择，c 取消，p 暂停，r 恢复（放回队列由 bdpan daemon 执行），q 退出
+func (h *TaskHandler) CmdTop(req *dto.TaskTopReq) error {
+	interval := time.Duration(req.Interval) * time.Second
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	if err := ui.Init(); err != nil {
+		return err
+	}
+	defer ui.Close()
+
+	top := newTaskTop()
+	top.refresh()
+	top.resize(ui.TerminalDimensions())
+	top.render()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	events := ui.PollEvents()
+	for {
+		select {
+		case e := <-events:
+			switch e.ID {
+			case "q", "<C-c>", "<Escape>":
+				return nil
+			case "<Resize>":
+				payload := e.Payload.(ui.Resize)
+				top.resize(payload.Width, payload.Height)
+				ui.Clear()
+			case "j", "<Down>":
+				top.move(1)
+			case "k", "<Up>":
+				top.move(-1)
+			case "c":
+				top.operate("取消", taskstore.Cancel)
+			case "p":
+				top.operate("暂停", taskstore.Pause)
+			case "r":
+				top.operate("恢复", requeueTask)
+			default:
+				continue
+			}
+		case <-ticker.C:
+			top.refresh()
+		}
+		top.render()
+	}
+}
+
+// requeueTask 将已暂停、失败、已取消或陈旧的下载任务放回队列，由 bdpan daemon 继续执行
+//
+// 检查之后任务被其他进程恢复时 taskstore.Requeue 返回 taskstore.ErrTaskChanged，不会重复执行
+func requeueTask(ctx context.Context, taskID string) error {
+	t, err := taskstore.Get(ctx, taskID)
+	if err != nil {
+		return err
+	}
+	if t.Type != taskstore.TaskTypeDownload {
+		return fmt.Errorf("暂不支持恢复%s任务", t.Type)
+	}
+	switch {
+	case t.Status == taskstore.StatusPaused, t.Status == taskstore.StatusFailed, t.Status == taskstore.StatusCanceled:
+	case taskstore.IsStale(*t):
+	default:
+		return fmt.Errorf("任务%s，无需恢复", taskstore.DisplayStatus(*t))
+	}
+	return taskstore.Requeue(ctx, t)
+}
+
+// taskTop 任务看板的状态与控件
+type taskTop struct {
+	tasks    []model.Task
+	selected string               // 选中任务的 ID，刷新后按 ID 保持选中
+	history  map[string][]float64 // 任务速度采样
+	lastSeen map[string]string    // 任务上次采样时的心跳时间
+	message  string
+
+	grid   *ui.Grid
+	header *widgets.Paragraph
+	table  *widgets.Table
+	detail *widgets.Paragraph
+	gauge  *widgets.Gauge
+	spark  *widgets.Sparkline
+	sparks *widgets.SparklineGroup
+	footer *widgets.Paragraph
+}
+
+func newTaskTop() *taskTop {
+	t := &taskTop{
+		history:  make(map[string][]float64),
+		lastSeen: make(map[string]string),
+		header:   widgets.NewParagraph(),
+		table:    widgets.NewTable(),
+		detail:   widgets.NewParagraph(),
+		gauge:    widgets.NewGauge(),
+		spark:    widgets.NewSparkline(),
+		footer:   widgets.NewParagraph(),
+	}
+	t.header.Title = "bdpan task top"
+	t.table.Title = "任务"
+	t.table.RowSeparator = false
+	t.table.FillRow = true
+	t.table.TextStyle = ui.NewStyle(ui.ColorWhite)
+	t.detail.Title = "详情"
+	t.gauge.Title = "进度"
+	t.gauge.BarColor = ui.ColorGreen
+	t.spark.LineColor = ui.ColorCyan
+	t.sparks = widgets.NewSparklineGroup(t.spark)
+	t.sparks.Title = "速度"
+	t.footer.Border = false
+
+	t.grid = ui.NewGrid()
+	t.grid.Set(
+		ui.NewRow(0.1, t.header),
+		ui.NewRow(0.5, t.table),
+		ui.NewRow(0.35,
+			ui.NewCol(0.5, t.detail),
+			ui.NewCol(0.5,
+				ui.NewRow(0.4, t.gauge),
+				ui.NewRow(0.6, t.sparks),
+			),
+		),
+		ui.NewRow(0.05, t.footer),
+	)
+	return t
+}
+
+// refresh 重新查询任务并采样速度
+func (t *taskTop) refresh() {
+	tasks, err := taskstore.List(context.Background(), taskstore.StatusRunning, taskstore.StatusQueued, taskstore.StatusPaused)
+	if err != nil {
+		t.message = "查询任务失败: " + err.Error()
+		return
+	}
+	t.tasks = tasks
+
+	alive := make(map[string]struct{}, len(tasks))
+	for _, task := range tasks {
+		alive[task.ID] = struct{}{}
+		if t.lastSeen[task.ID] == task.UpdateTime {
+			continue
+		}
+		t.lastSeen[task.ID] = task.UpdateTime
+		var speed int64
+		if task.Status == taskstore.StatusRunning && !taskstore.IsStale(task) {
+			speed = task.SpeedBPS
+		}
+		h := append(t.history[task.ID], float64(speed))
+		if len(h) > topHistorySize {
+			h = h[len(h)-topHistorySize:]
+		}
+		t.history[task.ID] = h
+	}
+	for id := range t.history {
+		if _, ok := alive[id]; !ok {
+			delete(t.history, id)
+			delete(t.lastSeen, id)
+		}
+	}
+	if t.selectedIndex() < 0 && len(tasks) > 0 {
+		t.selected = tasks[0].ID
+	}
+}
+
+func (t *taskTop) selectedIndex() int {
+	for i, task := range t.tasks {
+		if task.ID == t.selected {
+			return i
+		}
+	}
+	return -1
+}
+
+func (t *taskTop) selectedTask() *model.Task {
+	if i := t.selectedIndex(); i >= 0 {
+		return &t.tasks[i]
+	}
+	return nil
+}
+
+func (t *taskTop) move(delta int) {
+	if len(t.tasks) == 0 {
+		return
+	}
+	i := min(max(t.selectedIndex()+delta, 0), len(t.tasks)-1)
+	t.selected = t.tasks[i].ID
+}
+
+// operate 对选中任务执行操作，结果显示在底部
+func (t *taskTop) operate(name string, fn func(context.Context, string) error) {
+	task := t.selectedTask()
+	if task == nil {
+		return
+	}
+	if err := fn(context.Background(), task.ID); err != nil {
+		t.message = fmt.Sprintf("%s失败: %v", name, err)
+	} else {
+		t.message = fmt.Sprintf("已请求%s: %s", name, shortTaskID(task.ID))
+	}
+	t.refresh()
+}
+
+func (t *taskTop) resize(width, height int) {
+	t.grid.SetRect(0, 0, width, height)
+	// 固定列宽，剩余宽度留给路径
+	widths := []int{10, 6, 8, 24, 12, 10, topSparkWidth + 2}
+	used := 2
+	for _, w := range widths {
+		used += w
+	}
+	t.table.ColumnWidths = append(widths, max(width-used, 10))
+}
+
+func (t *taskTop) render() {
+	var running int
+	var speed int64
+	for _, task := range t.tasks {
+		if task.Status == taskstore.StatusRunning && !taskstore.IsStale(task) {
+			running++
+			speed += task.SpeedBPS
+		}
+	}
+	t.header.Text = fmt.Sprintf("任务 %d 个，运行中 %d 个，总速度 %s    %s",
+		len(t.tasks), running, downloader.FormatSpeed(speed), time.Now().Format("15:04:05"))
+
+	rows := [][]string{{"ID", "类型", "状态", "进度", "速度", "剩余", "趋势", "路径"}}
+	t.table.RowStyles = map[int]ui.Style{0: ui.NewStyle(ui.ColorYellow, ui.ColorClear, ui.ModifierBold)}
+	for i, task := range t.tasks {
+		rows = append(rows, []string{
+			shortTaskID(task.ID),
+			task.Type,
+			taskstore.DisplayStatus(task),
+			textProgressBar(task.Progress, 14),
+			formatTaskSpeed(task),
+			formatTaskETA(task),
+			sparkText(t.history[task.ID], topSparkWidth),
+			taskSourcePath(&task),
+		})
+		if task.ID == t.selected {
+			t.table.RowStyles[i+1] = ui.NewStyle(ui.ColorBlack, ui.ColorCyan)
+		}
+	}
+	if len(t.tasks) == 0 {
+		rows = append(rows, []string{"", "", "没有运行中的任务", "", "", "", "", ""})
+	}
+	t.table.Rows = rows
+
+	t.renderDetail()
+
+	keys := "j/k 选择  c 取消  p 暂停  r 恢复(放回队列)  q 退出"
+	if t.message != "" {
+		keys += "    " + t.message
+	}
+	t.footer.Text = keys
+
+	ui.Render(t.grid)
+}
+
+func (t *taskTop) renderDetail() {
+	task := t.selectedTask()
+	if task == nil {
+		t.detail.Text = ""
+		t.gauge.Percent = 0
+		t.gauge.Label = ""
+		t.spark.Data = nil
+		t.spark.Title = ""
+		return
+	}
+
+	source, target, isDir := taskPaths(task)
+	lines := []string{
+		"任务ID: " + task.ID,
+		"来源: " + source,
+		"目标: " + target,
+		fmt.Sprintf("进程: %d@%s", task.PID, task.Hostname),
+		"开始时间: " + formatTaskTime(task.StartTime),
+	}
+	if task.CancelRequested != 0 && task.Status == taskstore.StatusRunning {
+		if taskstore.IsPauseRequested(*task) {
+			lines = append(lines, "已请求暂停，等待执行端退出")
+		} else {
+			lines = append(lines, "已请求取消，等待执行端退出")
+		}
+	}
+	if isDir {
+		if counts, err := taskstore.CountChildren(context.Background(), task.ID); err == nil {
+			var total int64
+			for _, n := range counts {
+				total += n
+			}
+			lines = append(lines, fmt.Sprintf("子文件: 总数 %d，已完成 %d，失败 %d，运行中 %d",
+				total,
+				counts[taskstore.StatusCompleted],
+				counts[taskstore.StatusFailed],
+				counts[taskstore.StatusRunning]))
+		}
+		if children, err := taskstore.ListChildren(context.Background(), task.ID, taskstore.StatusRunning); err == nil {
+			for i, c := range children {
+				if i >= 5 {
+					lines = append(lines, fmt.Sprintf("  ... 共 %d 个", len(children)))
+					break
+				}
+				lines = append(lines, fmt.Sprintf("  %s %s/%s", c.Name, tools.FormatSize(c.Downloaded), tools.FormatSize(c.Size)))
+			}
+		}
+	}
+	t.detail.Text = strings.Join(lines, "\n")
+
+	t.gauge.Percent = min(max(int(task.Progress*100), 0), 100)
+	t.gauge.Label = fmt.Sprintf("%.1f%% (%s / %s)",
+		task.Progress*100,
+		tools.FormatSize(task.DownloadedBytes),
+		tools.FormatSize(task.TotalBytes))
+
+	// 控件只绘制前 Inner.Dx() 个采样，保留最近的部分
+	data := t.history[task.ID]
+	if n := t.sparks.Inner.Dx(); n > 0 && len(data) > n {
+		data = data[len(data)-n:]
+	}
+	t.spark.Data = data
+	t.spark.MaxVal = 0
+	if peak := slices.Max(append([]float64{0}, data...)); peak == 0 {
+		// 全部为 0 时避免除零
+		t.spark.MaxVal = 1
+	}
+	t.spark.Title = fmt.Sprintf("当前 %s  剩余 %s", formatTaskSpeed(*task), formatTaskETA(*task))
+}
+
+// textProgressBar 文本进度条，用于表格内展示
+func textProgressBar(progress float64, width int) string {
+	progress = min(max(progress, 0), 1)
+	filled := int(progress * float64(width))
+	return strings.Repeat("█", filled) + strings.Repeat("░", width-filled) + fmt.Sprintf(" %5.1f%%", progress*100)
+}
+
+// sparkText 使用块字符绘制最近 width 个采样的趋势
+func sparkText(data []float64, width int) string {
+	if len(data) > width {
+		data = data[len(data)-width:]
+	}
+	var peak float64
+	for _, v := range data {
+		peak = max(peak, v)
+	}
+	var b strings.Builder
+	for _, v := range data {
+		i := 0
+		if peak > 0 {
+			i = int(v / peak * float64(len(sparkLevels)-1))
+		}
+		b.WriteRune(sparkLevels[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/wxnacy/go-bdpan"
+)
+
+// 下载完成后本地文件修改时间的设置方式
+const (
+	PreserveTimesNone   = "none"   // 不设置，使用下载完成的时间
+	PreserveTimesLocal  = "local"  // 上传时本地文件的修改时间（local_mtime）
+	PreserveTimesServer = "server" // 网盘中的修改时间（server_mtime）
+)
+
+// checkPreserveTimes 校验修改时间的设置方式，为空时不设置
+func checkPreserveTimes(mode string) (string, error) {
+	switch mode {
+	case "":
+		return PreserveTimesNone, nil
+	case PreserveTimesNone, PreserveTimesLocal, PreserveTimesServer:
+		return mode, nil
+	}
+	return "", fmt.Errorf("--preserve-times 不支持 %s，可选值: none、local、server", mode)
+}
+
+// remoteModTime 远程文件的修改时间
+//
+// local 模式使用 local_mtime，为 0 时（如通过网页上传）退回 server_mtime；其他模式使用 server_mtime
+func remoteModTime(file *bdpan.FileInfo, mode string) time.Time {
+	if mode == PreserveTimesLocal && file.LocalMTime > 0 {
+		return time.Unix(file.LocalMTime, 0)
+	}
+	return time.Unix(file.ServerMTime, 0)
+}
+
+// applyModTime 按 mode 将远程文件的修改时间设置到本地文件或文件夹，none 模式不处理
+func applyModTime(path string, file *bdpan.FileInfo, mode string) error {
+	if mode == "" || mode == PreserveTimesNone {
+		return nil
+	}
+	t := remoteModTime(file, mode)
+	if t.Unix() <= 0 {
+		return nil
+	}
+	if err := os.Chtimes(path, t, t); err != nil {
+		return fmt.Errorf("设置修改时间失败: %w", err)
+	}
+	return nil
+}
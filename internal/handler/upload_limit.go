@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/wxnacy/bdpan-cli/internal/logger"
+	"github.com/wxnacy/bdpan-cli/pkg/bdtools"
+)
+
+var (
+	uploadVipOnce sync.Once
+	uploadVip     = bdtools.VipTypeUnknown
+)
+
+// uploadVipType 当前用户的会员类型，决定上传的分片大小和单文件上限，每个进程只查询一次
+//
+// 查询失败时返回 bdtools.VipTypeUnknown，使用普通用户的分片大小，不检查单文件上限，由服务端判断
+func (h *FileHandler) uploadVipType() bdtools.VipType {
+	uploadVipOnce.Do(func() {
+		user, err := GetAuthHandler().GetUser()
+		if err != nil {
+			logger.Errorf("获取会员类型失败，按普通用户分片上传: %v", err)
+			return
+		}
+		uploadVip = bdtools.VipType(user.VipType)
+		logger.Infof("会员类型: %s 分片大小: %d 单文件上限: %d",
+			uploadVip.Name(), bdtools.PartSize(uploadVip), bdtools.MaxFileSize(uploadVip))
+	})
+	return uploadVip
+}
+
+// checkUploadSize 上传前检查本地文件是否超过当前用户的单文件上限
+func (h *FileHandler) checkUploadSize(fromPath string, size int64) error {
+	if err := bdtools.CheckFileSize(h.uploadVipType(), size); err != nil {
+		return fmt.Errorf("%s: %w", fromPath, err)
+	}
+	return nil
+}
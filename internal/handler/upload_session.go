@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/wxnacy/bdpan-cli/internal/logger"
+	"github.com/wxnacy/bdpan-cli/internal/model"
+	"github.com/wxnacy/bdpan-cli/pkg/bdtools"
+)
+
+// uploadSessionTTL 超过该时间未更新的上传会话不再续传，重新预上传
+//
+// uploadid 在服务端的有效期有限，未超过该时间但已失效时由 bdtools.ErrUploadIDInvalid 兜底
+const uploadSessionTTL = 7 * 24 * time.Hour
+
+// onUploadRestart 续传的 uploadid 已失效、重新上传前回调，之前通过 bdtools.OnPartUploaded 累计的字节数需要清零
+type onUploadRestart func()
+
+// uploadSession 记录一次分片上传的进度，进程退出后重新上传同一文件时续传，见 model.UploadSession
+type uploadSession struct {
+	localPath  string
+	remotePath string
+	size       int64
+	mtime      int64
+	id         int64
+	parts      map[int]string
+	resume     *bdtools.ResumeFrom // 可续传的上次上传，为 nil 时重新预上传
+}
+
+// newUploadSession 查找本地文件上传到 remotePath 的未完成会话
+//
+// 实现逻辑：
+//
+// 1. 本地文件的大小和修改时间与会话一致，且会话未超过 uploadSessionTTL 时续传
+// 2. 否则删除会话，本次重新预上传
+// 3. 分块 md5 是否一致由 bdtools.UploadFile 计算后比较，不一致时同样重新预上传
+func newUploadSession(fromPath, remotePath string) (*uploadSession, error) {
+	localPath, err := filepath.Abs(fromPath)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return nil, err
+	}
+	s := &uploadSession{
+		localPath:  localPath,
+		remotePath: remotePath,
+		size:       info.Size(),
+		mtime:      info.ModTime().UnixNano(),
+		parts:      make(map[int]string),
+	}
+
+	old := model.FindUploadSession(localPath, remotePath)
+	if old == nil {
+		return s, nil
+	}
+	updated, _ := time.Parse(time.RFC3339, old.UpdateTime)
+	if old.Size != s.size || old.MTime != s.mtime || time.Since(updated) > uploadSessionTTL {
+		logger.Infof("上传会话已失效，重新上传: %s", localPath)
+		s.discard()
+		return s, nil
+	}
+	s.id = old.ID
+	s.parts = old.GetParts()
+	s.resume = &bdtools.ResumeFrom{
+		UploadID:  old.UploadID,
+		BlockList: old.GetBlockList(),
+		Parts:     maps.Clone(s.parts),
+	}
+	return s, nil
+}
+
+// start 预上传成功后保存新的会话，只有一个分片的文件不需要续传
+func (s *uploadSession) start(uploadID string, blockList []string) {
+	if len(blockList) <= 1 {
+		return
+	}
+	saved, err := model.StartUploadSession(s.localPath, s.remotePath, s.size, s.mtime, blockList, uploadID)
+	if err != nil {
+		logger.Errorf("保存上传会话失败: %v", err)
+		return
+	}
+	s.id = saved.ID
+	s.parts = make(map[int]string)
+}
+
+// savePart 记录分片上传成功，续传时已上传的分片不重复保存
+func (s *uploadSession) savePart(partseq int, md5 string) {
+	if s.id == 0 || s.parts[partseq] == md5 {
+		return
+	}
+	s.parts[partseq] = md5
+	if err := model.SaveUploadSessionParts(s.id, s.parts); err != nil {
+		logger.Errorf("保存上传会话失败: %v", err)
+	}
+}
+
+// discard 删除会话，上传成功或 uploadid 失效后调用
+func (s *uploadSession) discard() {
+	if err := model.DeleteUploadSession(s.localPath, s.remotePath); err != nil {
+		logger.Errorf("删除上传会话失败: %v", err)
+	}
+	s.id = 0
+	s.parts = make(map[int]string)
+	s.resume = nil
+}
@@ -0,0 +1,166 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/wxnacy/bdpan-cli/internal/config"
+)
+
+// 未配置时的默认值
+const (
+	DefaultConnectTimeout      = 30 * time.Second
+	DefaultIdleTimeout         = 90 * time.Second
+	DefaultMaxIdleConnsPerHost = 16 // 与分片下载的最大并发数一致
+)
+
+var (
+	mu        sync.RWMutex
+	transport = mustNewTransport(config.HTTP{KeepAlive: true})
+)
+
+// Transport 所有网络请求共享的 http.Transport，Init 前为默认配置
+func Transport() *http.Transport {
+	mu.RLock()
+	defer mu.RUnlock()
+	return transport
+}
+
+// NewClient 创建使用共享 Transport 的 http.Client，timeout 为 0 时不限制请求总时长
+func NewClient(timeout time.Duration) *http.Client {
+	return &http.Client{Transport: Transport(), Timeout: timeout}
+}
+
+// Init 根据配置 http 创建共享的 Transport
+//
+// 实现逻辑：
+//
+// 1. 代理支持 http、https、socks5，未配置时使用 HTTP_PROXY、HTTPS_PROXY 环境变量
+// 2. connect_timeout 同时用于 TCP 连接和 TLS 握手，idle_timeout 为空闲连接保留的时间
+// 3. ca_files 中的证书追加到系统根证书中
+// 4. keep_alive 为 false 时每个请求使用新连接
+// 5. 同时替换 http.DefaultTransport，使用默认客户端的请求（如 go-bdpan 的接口调用）同样生效
+func Init(cfg config.HTTP) error {
+	t, err := newTransport(cfg)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	transport = t
+	mu.Unlock()
+	http.DefaultTransport = t
+	return nil
+}
+
+func mustNewTransport(cfg config.HTTP) *http.Transport {
+	t, err := newTransport(cfg)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func newTransport(cfg config.HTTP) (*http.Transport, error) {
+	connectTimeout, err := parseDuration(cfg.ConnectTimeout, DefaultConnectTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("http.connect_timeout: %w", err)
+	}
+	idleTimeout, err := parseDuration(cfg.IdleTimeout, DefaultIdleTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("http.idle_timeout: %w", err)
+	}
+	maxIdle := cfg.MaxIdleConnsPerHost
+	if maxIdle <= 0 {
+		maxIdle = DefaultMaxIdleConnsPerHost
+	}
+
+	dialer := &net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second}
+	// 分片下载依赖多个连接并发，不启用 HTTP/2 多路复用
+	t := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   connectTimeout,
+		IdleConnTimeout:       idleTimeout,
+		MaxIdleConns:          maxIdle * 4,
+		MaxIdleConnsPerHost:   maxIdle,
+		ExpectContinueTimeout: time.Second,
+		DisableKeepAlives:     !cfg.KeepAlive,
+	}
+
+	if cfg.Proxy != "" {
+		proxy, err := parseProxy(cfg.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("http.proxy: %w", err)
+		}
+		t.Proxy = http.ProxyURL(proxy)
+	}
+
+	if len(cfg.CAFiles) > 0 {
+		pool, err := loadCertPool(cfg.CAFiles)
+		if err != nil {
+			return nil, fmt.Errorf("http.ca_files: %w", err)
+		}
+		t.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	return t, nil
+}
+
+func parseDuration(s string, def time.Duration) (time.Duration, error) {
+	if s == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("需大于 0: %s", s)
+	}
+	return d, nil
+}
+
+func parseProxy(s string) (*url.URL, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("不支持的代理协议 %q，可选值: http、https、socks5", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("缺少代理地址: %s", s)
+	}
+	return u, nil
+}
+
+// loadCertPool 在系统根证书的基础上追加 files 中的证书
+func loadCertPool(files []string) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	for _, name := range files {
+		path, err := homedir.Expand(name)
+		if err != nil {
+			return nil, err
+		}
+		pem, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s 中没有有效的 PEM 证书", name)
+		}
+	}
+	return pool, nil
+}
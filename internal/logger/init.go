@@ -1,6 +1,7 @@
 package logger
 
 import (
+	"log"
 	"os"
 	"path/filepath"
 	"time"
@@ -36,6 +37,8 @@ func SetLogFile() {
 		return
 	}
 	GetLogger().SetOutput(logf)
+	// 标准库日志（如 net/http 复用连接时的提示）同样写入日志文件，避免打乱终端输出
+	log.SetOutput(logf)
 }
 
 func ClearLogFile() {
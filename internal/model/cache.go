@@ -0,0 +1,18 @@
+package model
+
+// CacheEntry 缓存目录（config.GetCacheDir()）第一层的一个文件或文件夹
+//
+// 由 internal/cache 维护，用于统计缓存大小和按最近最少使用清理。
+// 缓存目录是实际数据，索引与其不一致时以缓存目录为准，见 cache.Scan。
+//
+// 表名：cache_entries
+// 时间字段使用 RFC3339，数据库内保存 TEXT。
+type CacheEntry struct {
+	Path       string `gorm:"primaryKey;column:path" json:"path"` // 相对于缓存目录的路径
+	Kind       string `gorm:"column:kind;index" json:"kind"`
+	Size       int64  `gorm:"column:size" json:"size"`
+	AccessTime string `gorm:"column:access_time;index" json:"access_time"`
+	CreateTime string `gorm:"column:create_time" json:"create_time"`
+}
+
+func (CacheEntry) TableName() string { return "cache_entries" }
@@ -88,7 +88,7 @@ func InitSqlite() {
 
 	// 6. 自动迁移表结构
 	begin := time.Now()
-	if err := db.AutoMigrate(&UploadHistory{}, &File{}, &Quick{}, &Task{}, &TaskChild{}); err != nil {
+	if err := db.AutoMigrate(&UploadHistory{}, &File{}, &Quick{}, &Task{}, &TaskChild{}, &CacheEntry{}, &UploadSession{}); err != nil {
 		panic("InitSqlite: AutoMigrate failed: " + err.Error())
 	}
 	log.Debugf("DB AutoMigrate time used %v", time.Since(begin))
@@ -9,10 +9,10 @@ import (
 // 字段保持通用化，类型相关的数据放在 Data(JSON 字符串) 中。
 // identity 是任务的幂等键，例如 sha1("download|/src|/out")。
 //
-// 状态流转：queued -> running -> completed | failed | canceled | stale。
+// 状态流转：queued -> running -> completed | failed | canceled | paused | stale。
 // stale 由外部逻辑判断（心跳超时 + 进程不存在）。
 //
-// CancelRequested 是协作取消标记，下载/上传循环需主动检查以便优雅退出。
+// CancelRequested 是协作取消标记（1 取消，2 暂停），下载/上传循环需主动检查以便优雅退出。
 // Progress 取值 [0,1]，配合字节字段用于计算速率和 ETA。
 //
 // GORM 标签建立必要索引，便于列表/状态查询。
@@ -79,12 +79,14 @@ func NewTask(id, typ, identity string, totalBytes int64, pid int, hostname strin
 }
 
 // TaskChild 描述目录类任务的子文件条目，仅在需要跟踪子项时使用。
+//
+// 同一任务下 Path 唯一，重复执行同一 identity 的任务时按 Path 复用已有条目。
 
 type TaskChild struct {
 	ID          int64  `gorm:"primaryKey;column:id" json:"id"`
-	TaskID      string `gorm:"column:task_id;index" json:"task_id"`
+	TaskID      string `gorm:"column:task_id;index;uniqueIndex:idx_task_children_task_path" json:"task_id"`
 	Name        string `gorm:"column:name" json:"name"`
-	Path        string `gorm:"column:path" json:"path"`
+	Path        string `gorm:"column:path;uniqueIndex:idx_task_children_task_path" json:"path"`
 	Size        int64  `gorm:"column:size" json:"size"`
 	Downloaded  int64  `gorm:"column:downloaded" json:"downloaded"`
 	Status      string `gorm:"column:status;index" json:"status"`
@@ -1,5 +1,12 @@
 package model
 
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
 type UploadHistory struct {
 	FSID           uint64 `json:"fs_id" gorm:"primaryKey;column:fs_id"`
 	Path           string `json:"path"`
@@ -25,3 +32,96 @@ func FindUploadHistoryByLocalMD5(md5 string) *UploadHistory {
 	).First(&m)
 	return &m
 }
+
+// UploadSession 未完成的分片上传，进程退出后重新上传同一文件时从第一个缺失的分片继续
+//
+// 以本地文件路径和远程路径唯一确定，本地文件的大小、修改时间或分块 md5 变化后不再续传。
+// 上传成功或 uploadid 失效后删除。
+//
+// 表名：upload_sessions
+// BlockList、Parts 为 JSON 字符串，时间字段使用 RFC3339，数据库内保存 TEXT。
+type UploadSession struct {
+	ID         int64  `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	LocalPath  string `gorm:"column:local_path;uniqueIndex:idx_upload_sessions_path" json:"local_path"`
+	RemotePath string `gorm:"column:remote_path;uniqueIndex:idx_upload_sessions_path" json:"remote_path"`
+	Size       int64  `gorm:"column:size" json:"size"`
+	MTime      int64  `gorm:"column:mtime" json:"mtime"`                     // 本地文件的修改时间，Unix 纳秒
+	BlockList  string `gorm:"column:block_list;type:text" json:"block_list"` // 本地文件的分块 md5 列表
+	UploadID   string `gorm:"column:upload_id" json:"upload_id"`
+	Parts      string `gorm:"column:parts;type:text" json:"parts"` // 已上传的分片，分片序号 => 分片 md5
+	CreateTime string `gorm:"column:create_time" json:"create_time"`
+	UpdateTime string `gorm:"column:update_time" json:"update_time"`
+}
+
+func (UploadSession) TableName() string { return "upload_sessions" }
+
+// GetBlockList 本地文件的分块 md5 列表
+func (s *UploadSession) GetBlockList() []string {
+	var list []string
+	_ = json.Unmarshal([]byte(s.BlockList), &list)
+	return list
+}
+
+// GetParts 已上传的分片，分片序号 => 分片 md5
+func (s *UploadSession) GetParts() map[int]string {
+	parts := make(map[int]string)
+	_ = json.Unmarshal([]byte(s.Parts), &parts)
+	return parts
+}
+
+// FindUploadSession 查找本地文件上传到 remotePath 的未完成会话，不存在时返回 nil
+func FindUploadSession(localPath, remotePath string) *UploadSession {
+	var s UploadSession
+	err := GetDB().Where("local_path = ? AND remote_path = ?", localPath, remotePath).First(&s).Error
+	if err != nil {
+		return nil
+	}
+	return &s
+}
+
+// StartUploadSession 预上传成功后保存新的会话，替换同一文件之前的会话
+func StartUploadSession(localPath, remotePath string, size, mtime int64, blockList []string, uploadID string) (*UploadSession, error) {
+	b, err := json.Marshal(blockList)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().Format(time.RFC3339)
+	s := &UploadSession{
+		LocalPath:  localPath,
+		RemotePath: remotePath,
+		Size:       size,
+		MTime:      mtime,
+		BlockList:  string(b),
+		UploadID:   uploadID,
+		Parts:      "{}",
+		CreateTime: now,
+		UpdateTime: now,
+	}
+	err = GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("local_path = ? AND remote_path = ?", localPath, remotePath).Delete(&UploadSession{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(s).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// SaveUploadSessionParts 更新会话中已上传的分片
+func SaveUploadSessionParts(id int64, parts map[int]string) error {
+	b, err := json.Marshal(parts)
+	if err != nil {
+		return err
+	}
+	return GetDB().Model(&UploadSession{}).Where("id = ?", id).Updates(map[string]any{
+		"parts":       string(b),
+		"update_time": time.Now().Format(time.RFC3339),
+	}).Error
+}
+
+// DeleteUploadSession 删除本地文件上传到 remotePath 的会话
+func DeleteUploadSession(localPath, remotePath string) error {
+	return GetDB().Where("local_path = ? AND remote_path = ?", localPath, remotePath).Delete(&UploadSession{}).Error
+}
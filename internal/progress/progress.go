@@ -0,0 +1,243 @@
+package progress
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/wxnacy/go-tools"
+	"golang.org/x/term"
+)
+
+// 进度输出方式
+const (
+	ModeAuto  = "auto"  // 终端中使用 tui，否则使用 plain
+	ModeTUI   = "tui"   // bubbletea 进度条
+	ModePlain = "plain" // 定时输出一行文字进度
+	ModeJSONL = "jsonl" // 每次状态变化输出一行 JSON，见 Event
+	ModeNone  = "none"  // 不输出进度
+)
+
+// 事件状态
+const (
+	StatusStart    = "start"
+	StatusProgress = "progress"
+	StatusDone     = "done"
+	StatusSkipped  = "skipped"
+	StatusFailed   = "failed"
+	StatusCanceled = "canceled"
+)
+
+// 两次进度输出的最小间隔，状态变化不受限制
+const (
+	plainInterval = 5 * time.Second
+	jsonlInterval = time.Second
+)
+
+// Event jsonl 模式下输出的事件，一行一个
+type Event struct {
+	Time   string `json:"time"`
+	TaskID string `json:"task_id,omitempty"`
+	File   string `json:"file"` // 传输的源文件，下载为网盘路径，上传为本地路径
+	Status string `json:"status"`
+	Bytes  int64  `json:"bytes"`
+	Total  int64  `json:"total"`
+	Speed  int64  `json:"speed_bps"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Check 校验进度输出方式
+func Check(mode string) error {
+	switch mode {
+	case "", ModeAuto, ModeTUI, ModePlain, ModeJSONL, ModeNone:
+		return nil
+	}
+	return fmt.Errorf("--progress 不支持 %s，可选值: auto、tui、plain、jsonl、none", mode)
+}
+
+// Resolve 返回实际使用的进度输出方式，为空或 auto 时标准输入和标准输出都是终端才使用 tui，否则使用 plain
+func Resolve(mode string) string {
+	switch mode {
+	case ModeTUI, ModePlain, ModeJSONL, ModeNone:
+		return mode
+	}
+	if term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stdout.Fd())) {
+		return ModeTUI
+	}
+	return ModePlain
+}
+
+// Reporter 以 plain 或 jsonl 方式向标准错误输出传输进度，tui 和 none 方式下为 nil，所有方法对 nil 安全
+//
+// 实现细节：
+// - 开始、完成、跳过、失败、取消每次都输出
+// - 传输中的进度按文件限制输出频率，plain 每 5 秒一次，jsonl 每秒一次
+// - 速度为两次输出之间的平均速度，完成时为整个文件的平均速度
+type Reporter struct {
+	mode     string
+	w        io.Writer
+	interval time.Duration
+	mu       sync.Mutex
+	taskID   string
+	files    map[string]*fileState
+}
+
+type fileState struct {
+	total     int64
+	bytes     int64
+	start     time.Time
+	lastTime  time.Time
+	lastBytes int64
+}
+
+// NewReporter 创建 mode 对应的 Reporter，mode 需为 Resolve 的返回值，tui 和 none 时返回 nil
+func NewReporter(mode string) *Reporter {
+	r := &Reporter{mode: mode, w: os.Stderr, files: make(map[string]*fileState)}
+	switch mode {
+	case ModePlain:
+		r.interval = plainInterval
+	case ModeJSONL:
+		r.interval = jsonlInterval
+	default:
+		return nil
+	}
+	return r
+}
+
+// SetOutput 设置输出，默认为标准错误
+func (r *Reporter) SetOutput(w io.Writer) *Reporter {
+	if r != nil {
+		r.w = w
+	}
+	return r
+}
+
+// SetTaskID 设置事件中的任务 ID
+func (r *Reporter) SetTaskID(id string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	r.taskID = id
+	r.mu.Unlock()
+}
+
+// Start 开始传输文件
+func (r *Reporter) Start(file string, total int64) {
+	if r == nil {
+		return
+	}
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.files[file] = &fileState{total: total, start: now, lastTime: now}
+	r.emit(Event{File: file, Status: StatusStart, Total: total})
+}
+
+// Update 更新文件已传输的字节数
+func (r *Reporter) Update(file string, bytes int64) {
+	if r == nil {
+		return
+	}
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.state(file, now)
+	s.bytes = bytes
+	if now.Sub(s.lastTime) < r.interval {
+		return
+	}
+	speed := int64(float64(bytes-s.lastBytes) / now.Sub(s.lastTime).Seconds())
+	s.lastTime, s.lastBytes = now, bytes
+	r.emit(Event{File: file, Status: StatusProgress, Bytes: bytes, Total: s.total, Speed: max(speed, 0)})
+}
+
+// Done 文件传输完成
+func (r *Reporter) Done(file string) {
+	r.finish(file, StatusDone, nil)
+}
+
+// Skip 文件已存在等原因跳过
+func (r *Reporter) Skip(file string) {
+	r.finish(file, StatusSkipped, nil)
+}
+
+// Fail 文件传输失败，err 为 context.Canceled 时为取消
+func (r *Reporter) Fail(file string, err error) {
+	if errors.Is(err, context.Canceled) {
+		r.finish(file, StatusCanceled, nil)
+		return
+	}
+	r.finish(file, StatusFailed, err)
+}
+
+func (r *Reporter) finish(file, status string, err error) {
+	if r == nil {
+		return
+	}
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.state(file, now)
+	delete(r.files, file)
+	e := Event{File: file, Status: status, Bytes: s.bytes, Total: s.total}
+	if status == StatusDone {
+		e.Bytes = max(s.bytes, s.total)
+		if elapsed := now.Sub(s.start).Seconds(); elapsed > 0 {
+			e.Speed = int64(float64(e.Bytes) / elapsed)
+		}
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	r.emit(e)
+}
+
+// state 返回文件的传输状态，未调用 Start 时新建
+func (r *Reporter) state(file string, now time.Time) *fileState {
+	s, ok := r.files[file]
+	if !ok {
+		s = &fileState{start: now, lastTime: now}
+		r.files[file] = s
+	}
+	return s
+}
+
+func (r *Reporter) emit(e Event) {
+	e.TaskID = r.taskID
+	e.Time = time.Now().Format(time.RFC3339)
+	if r.mode == ModeJSONL {
+		b, _ := json.Marshal(e)
+		fmt.Fprintf(r.w, "%s\n", b)
+		return
+	}
+	fmt.Fprintln(r.w, formatPlain(e))
+}
+
+// formatPlain plain 模式下的一行输出
+func formatPlain(e Event) string {
+	switch e.Status {
+	case StatusStart:
+		return fmt.Sprintf("开始: %s (%s)", e.File, tools.FormatSize(e.Total))
+	case StatusProgress:
+		percent := 0.0
+		if e.Total > 0 {
+			percent = float64(e.Bytes) / float64(e.Total) * 100
+		}
+		return fmt.Sprintf("进度: %s %s / %s %.1f%% 速度: %s/s", e.File,
+			tools.FormatSize(e.Bytes), tools.FormatSize(e.Total), percent, tools.FormatSize(e.Speed))
+	case StatusDone:
+		return fmt.Sprintf("完成: %s (%s，平均速度: %s/s)", e.File, tools.FormatSize(e.Bytes), tools.FormatSize(e.Speed))
+	case StatusSkipped:
+		return fmt.Sprintf("跳过: %s", e.File)
+	case StatusCanceled:
+		return fmt.Sprintf("已取消: %s", e.File)
+	default:
+		return fmt.Sprintf("失败: %s - %s", e.File, e.Error)
+	}
+}
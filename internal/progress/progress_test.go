@@ -0,0 +1,236 @@
+package progress
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// newTestReporter 创建输出到 buf 的 Reporter，并缩短进度输出间隔
+func newTestReporter(t *testing.T, mode string, interval time.Duration) (*Reporter, *bytes.Buffer) {
+	t.Helper()
+	var buf bytes.Buffer
+	r := NewReporter(mode).SetOutput(&buf)
+	if r == nil {
+		t.Fatalf("NewReporter(%s) = nil", mode)
+	}
+	r.interval = interval
+	return r, &buf
+}
+
+// readEvents 解析 jsonl 输出的全部事件
+func readEvents(t *testing.T, buf *bytes.Buffer) []Event {
+	t.Helper()
+	var events []Event
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var e Event
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("解析事件 %q 失败: %v", line, err)
+		}
+		events = append(events, e)
+	}
+	buf.Reset()
+	return events
+}
+
+func TestResolve(t *testing.T) {
+	auto := ModePlain
+	if term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stdout.Fd())) {
+		auto = ModeTUI
+	}
+	tests := []struct {
+		mode string
+		want string
+	}{
+		{ModeTUI, ModeTUI},
+		{ModePlain, ModePlain},
+		{ModeJSONL, ModeJSONL},
+		{ModeNone, ModeNone},
+		{ModeAuto, auto},
+		{"", auto},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			if got := Resolve(tt.mode); got != tt.want {
+				t.Errorf("Resolve(%q) = %s, want %s", tt.mode, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	for _, mode := range []string{"", ModeAuto, ModeTUI, ModePlain, ModeJSONL, ModeNone} {
+		if err := Check(mode); err != nil {
+			t.Errorf("Check(%q) error = %v", mode, err)
+		}
+	}
+	if err := Check("json"); err == nil {
+		t.Errorf("Check(json) error = nil, want error")
+	}
+}
+
+func TestNewReporter_Nil(t *testing.T) {
+	for _, mode := range []string{ModeTUI, ModeNone} {
+		r := NewReporter(mode)
+		if r != nil {
+			t.Fatalf("NewReporter(%s) = %v, want nil", mode, r)
+		}
+		// nil Reporter 的方法不输出也不 panic
+		r.SetOutput(&bytes.Buffer{}).SetTaskID("task")
+		r.Start("/a", 100)
+		r.Update("/a", 50)
+		r.Skip("/b")
+		r.Fail("/a", errors.New("boom"))
+		r.Done("/a")
+	}
+}
+
+func TestReporter_UpdateThrottle(t *testing.T) {
+	const interval = 100 * time.Millisecond
+	r, buf := newTestReporter(t, ModeJSONL, interval)
+	r.SetTaskID("task-1")
+
+	r.Start("/a", 1000)
+	r.Update("/a", 100)
+	r.Update("/b", 100) // 各文件分别限制频率
+	events := readEvents(t, buf)
+	if len(events) != 1 || events[0].Status != StatusStart || events[0].Total != 1000 || events[0].TaskID != "task-1" {
+		t.Fatalf("间隔内的进度不应输出, events = %+v", events)
+	}
+
+	time.Sleep(interval + 20*time.Millisecond)
+	r.Update("/a", 300)
+	r.Update("/a", 400)
+	events = readEvents(t, buf)
+	if len(events) != 1 {
+		t.Fatalf("events = %+v, want 1 个进度事件", events)
+	}
+	e := events[0]
+	if e.Status != StatusProgress || e.File != "/a" || e.Bytes != 300 || e.Total != 1000 {
+		t.Errorf("进度事件 = %+v", e)
+	}
+	// 速度为两次输出之间的平均速度：300 字节 / 约 120ms
+	if e.Speed < 300*1000/400 || e.Speed > 300*1000/int64(interval/time.Millisecond) {
+		t.Errorf("Speed = %d, want 约 %d", e.Speed, 300*1000/120)
+	}
+
+	// 状态变化不受频率限制
+	r.Done("/a")
+	events = readEvents(t, buf)
+	if len(events) != 1 || events[0].Status != StatusDone {
+		t.Errorf("events = %+v, want 完成事件", events)
+	}
+}
+
+func TestReporter_DoneSpeed(t *testing.T) {
+	r, buf := newTestReporter(t, ModeJSONL, time.Hour)
+	const total = 10_000
+	r.Start("/a", total)
+	time.Sleep(100 * time.Millisecond)
+	r.Update("/a", total/2) // 最后一次回调可能早于写完
+	r.Done("/a")
+
+	events := readEvents(t, buf)
+	if len(events) != 2 {
+		t.Fatalf("events = %+v, want 开始和完成事件", events)
+	}
+	e := events[1]
+	if e.Status != StatusDone || e.Bytes != total || e.Total != total {
+		t.Errorf("完成事件 = %+v, want Bytes = Total = %d", e, total)
+	}
+	// 整个文件的平均速度：10000 字节 / 约 100ms
+	if e.Speed < total*1000/400 || e.Speed > total*1000/100 {
+		t.Errorf("Speed = %d, want 约 %d", e.Speed, total*10)
+	}
+}
+
+func TestReporter_JSONL(t *testing.T) {
+	r, buf := newTestReporter(t, ModeJSONL, time.Hour)
+	r.SetTaskID("task-1")
+	r.Skip("/skip")
+	r.Start("/fail", 10)
+	r.Update("/fail", 4)
+	r.Fail("/fail", errors.New("网络错误"))
+	r.Start("/cancel", 10)
+	r.Fail("/cancel", context.Canceled)
+
+	want := []Event{
+		{File: "/skip", Status: StatusSkipped},
+		{File: "/fail", Status: StatusStart, Total: 10},
+		{File: "/fail", Status: StatusFailed, Bytes: 4, Total: 10, Error: "网络错误"},
+		{File: "/cancel", Status: StatusStart, Total: 10},
+		{File: "/cancel", Status: StatusCanceled, Total: 10},
+	}
+	events := readEvents(t, buf)
+	if len(events) != len(want) {
+		t.Fatalf("events = %+v, want %d 个事件", events, len(want))
+	}
+	for i, e := range events {
+		if _, err := time.Parse(time.RFC3339, e.Time); err != nil {
+			t.Errorf("事件 %d 时间 %q 不是 RFC3339 格式", i, e.Time)
+		}
+		w := want[i]
+		w.Time, w.TaskID = e.Time, "task-1"
+		if e != w {
+			t.Errorf("事件 %d = %+v, want %+v", i, e, w)
+		}
+	}
+}
+
+func TestReporter_Plain(t *testing.T) {
+	r, buf := newTestReporter(t, ModePlain, 0)
+	r.Start("/a", 2048)
+	r.Update("/a", 1024)
+	r.Skip("/b")
+	r.Fail("/c", errors.New("网络错误"))
+	r.Fail("/d", context.Canceled)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	want := []string{
+		"开始: /a (2K)",
+		"进度: /a 1K / 2K 50.0% 速度: ",
+		"跳过: /b",
+		"失败: /c - 网络错误",
+		"已取消: /d",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("输出 %q, want %d 行", lines, len(want))
+	}
+	for i, line := range lines {
+		if !strings.HasPrefix(line, want[i]) {
+			t.Errorf("第 %d 行 = %q, want 前缀 %q", i+1, line, want[i])
+		}
+	}
+}
+
+func TestFormatPlain(t *testing.T) {
+	tests := []struct {
+		e    Event
+		want string
+	}{
+		{Event{File: "/a", Status: StatusStart, Total: 1 << 20}, "开始: /a (1M)"},
+		{Event{File: "/a", Status: StatusProgress, Bytes: 512, Total: 2048, Speed: 1024}, "进度: /a 512B / 2K 25.0% 速度: 1K/s"},
+		{Event{File: "/a", Status: StatusProgress, Bytes: 512}, "进度: /a 512B / 0B 0.0% 速度: 0B/s"},
+		{Event{File: "/a", Status: StatusDone, Bytes: 3 << 20, Speed: 1 << 20}, "完成: /a (3M，平均速度: 1M/s)"},
+		{Event{File: "/a", Status: StatusSkipped}, "跳过: /a"},
+		{Event{File: "/a", Status: StatusCanceled}, "已取消: /a"},
+		{Event{File: "/a", Status: StatusFailed, Error: "boom"}, "失败: /a - boom"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.e.Status, func(t *testing.T) {
+			if got := formatPlain(tt.e); got != tt.want {
+				t.Errorf("formatPlain() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
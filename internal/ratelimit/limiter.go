@@ -0,0 +1,111 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// scheduleCheckInterval 按时间段重新计算速率的间隔
+const scheduleCheckInterval = 10 * time.Second
+
+// Limiter 令牌桶限速器，多个 goroutine 共享同一个 Limiter 时总速率不超过限制
+//
+// 实现细节：
+// - 速率单位为字节/秒，<= 0 表示不限速
+// - 桶容量为 1 秒的速率，单次取用超过桶内令牌时允许透支，透支部分通过等待偿还
+// - 未通过 SetRate 固定速率时，每 10 秒按 Schedule 重新计算当前时间段的速率
+type Limiter struct {
+	mu          sync.Mutex
+	schedule    Schedule
+	override    int64
+	hasOverride bool
+	rate        int64
+	tokens      float64
+	last        time.Time
+	nextCheck   time.Time
+}
+
+// NewLimiter 创建按 schedule 限速的 Limiter
+func NewLimiter(schedule Schedule) *Limiter {
+	return &Limiter{schedule: schedule}
+}
+
+// SetSchedule 设置按时间段生效的速率
+func (l *Limiter) SetSchedule(schedule Schedule) *Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.schedule = schedule
+	l.nextCheck = time.Time{}
+	return l
+}
+
+// SetRate 固定速率，覆盖 Schedule，rate <= 0 表示不限速
+func (l *Limiter) SetRate(rate int64) *Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.override = rate
+	l.hasOverride = true
+	l.nextCheck = time.Time{}
+	return l
+}
+
+// Rate 返回当前生效的速率
+func (l *Limiter) Rate() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refresh(time.Now())
+	return l.rate
+}
+
+// WaitN 取用 n 个字节的令牌，令牌不足时等待，ctx 结束时返回 ctx.Err()
+func (l *Limiter) WaitN(ctx context.Context, n int) error {
+	if l == nil || n <= 0 {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	l.refresh(now)
+	if l.rate <= 0 {
+		l.mu.Unlock()
+		return nil
+	}
+	rate := float64(l.rate)
+	l.tokens = min(l.tokens+now.Sub(l.last).Seconds()*rate, rate)
+	l.last = now
+	l.tokens -= float64(n)
+	var wait time.Duration
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// refresh 更新当前速率，速率变化时重新装满令牌桶，调用方需持有锁
+func (l *Limiter) refresh(now time.Time) {
+	if now.Before(l.nextCheck) {
+		return
+	}
+	l.nextCheck = now.Add(scheduleCheckInterval)
+	rate := l.override
+	if !l.hasOverride {
+		rate = l.schedule.RateAt(now)
+	}
+	if rate != l.rate {
+		l.rate = rate
+		l.tokens = float64(max(rate, 0))
+		l.last = now
+	}
+}
@@ -0,0 +1,255 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/wxnacy/bdpan-cli/internal/config"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"0", 0, false},
+		{"unlimited", 0, false},
+		{" Unlimited ", 0, false},
+		{"1048576", 1048576, false},
+		{"512K", 512 << 10, false},
+		{"512k", 512 << 10, false},
+		{"1.5M", 3 << 19, false},
+		{"2M", 2 << 20, false},
+		{"1MB", 1 << 20, false},
+		{"1MB/s", 1 << 20, false},
+		{"1m/s", 1 << 20, false},
+		{"1G", 1 << 30, false},
+		{"100B", 100, false},
+		{"-1M", 0, true},
+		{"fast", 0, true},
+		{"M", 0, true},
+		{"1T", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseRate(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRate(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseRate(%q) = %d, want %d", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseClock(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int
+		wantErr bool
+	}{
+		{"00:00", 0, false},
+		{"08:30", 8*60 + 30, false},
+		{" 22:00 ", 22 * 60, false},
+		{"9:05", 9*60 + 5, false},
+		{"23:59", 23*60 + 59, false},
+		{"24:00", 24 * 60, false},
+		{"24:01", 0, true},
+		{"25:00", 0, true},
+		{"12:60", 0, true},
+		{"-1:00", 0, true},
+		{"12", 0, true},
+		{"noon", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseClock(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseClock(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseClock(%q) = %d, want %d", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRule_Contains(t *testing.T) {
+	const hour = 60
+	tests := []struct {
+		name   string
+		rule   Rule
+		minute int
+		want   bool
+	}{
+		{"当天区间开始", Rule{Start: 9 * hour, End: 18 * hour}, 9 * hour, true},
+		{"当天区间内", Rule{Start: 9 * hour, End: 18 * hour}, 12 * hour, true},
+		{"当天区间结束不包含", Rule{Start: 9 * hour, End: 18 * hour}, 18 * hour, false},
+		{"当天区间之前", Rule{Start: 9 * hour, End: 18 * hour}, 9*hour - 1, false},
+		{"跨天开始", Rule{Start: 22 * hour, End: 6 * hour}, 22 * hour, true},
+		{"跨天午夜前", Rule{Start: 22 * hour, End: 6 * hour}, 24*hour - 1, true},
+		{"跨天午夜", Rule{Start: 22 * hour, End: 6 * hour}, 0, true},
+		{"跨天结束前", Rule{Start: 22 * hour, End: 6 * hour}, 6*hour - 1, true},
+		{"跨天结束不包含", Rule{Start: 22 * hour, End: 6 * hour}, 6 * hour, false},
+		{"跨天白天", Rule{Start: 22 * hour, End: 6 * hour}, 12 * hour, false},
+		{"到 24:00 结束", Rule{Start: 20 * hour, End: 24 * hour}, 24*hour - 1, true},
+		{"到 24:00 结束不含次日", Rule{Start: 20 * hour, End: 24 * hour}, 0, false},
+		{"开始结束相同", Rule{Start: 8 * hour, End: 8 * hour}, 8 * hour, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.contains(tt.minute); got != tt.want {
+				t.Errorf("contains(%d) = %v, want %v", tt.minute, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchedule_RateAt(t *testing.T) {
+	const hour = 60
+	s := Schedule{
+		Default: 100,
+		Rules: []Rule{
+			{Start: 22 * hour, End: 6 * hour, Rate: 0},         // 夜间不限速
+			{Start: 9 * hour, End: 18 * hour, Rate: 10},        // 白天限速
+			{Start: 12 * hour, End: 13 * hour, Rate: 50},       // 与白天重叠，以先配置的为准
+			{Start: 5 * hour, End: 7*hour + 30, Rate: 20},      // 与夜间重叠的部分以夜间为准
+			{Start: 23 * hour, End: 24 * hour, Rate: 30 << 20}, // 被夜间完全覆盖
+		},
+	}
+	at := func(h, m int) time.Time { return time.Date(2026, 1, 2, h, m, 0, 0, time.Local) }
+	tests := []struct {
+		name string
+		t    time.Time
+		want int64
+	}{
+		{"午夜", at(0, 0), 0},
+		{"夜间结束前", at(5, 59), 0},
+		{"夜间结束后命中清晨规则", at(6, 0), 20},
+		{"清晨规则结束", at(7, 30), 100},
+		{"白天开始", at(9, 0), 10},
+		{"重叠以先配置的为准", at(12, 30), 10},
+		{"白天结束", at(18, 0), 100},
+		{"夜间开始", at(22, 0), 0},
+		{"跨天规则午夜前", at(23, 59), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.RateAt(tt.t); got != tt.want {
+				t.Errorf("RateAt(%s) = %d, want %d", tt.t.Format("15:04"), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildSchedule(t *testing.T) {
+	rules := []config.LimitRule{
+		{Start: "22:00", End: "06:00", Download: "0", Upload: "1M"},
+		{Start: "09:00", End: "18:00", Download: "512K"},
+	}
+	download := func(r config.LimitRule) string { return r.Download }
+	upload := func(r config.LimitRule) string { return r.Upload }
+
+	s, err := buildSchedule("2M", rules, download)
+	if err != nil {
+		t.Fatalf("buildSchedule() error = %v", err)
+	}
+	want := Schedule{Default: 2 << 20, Rules: []Rule{
+		{Start: 22 * 60, End: 6 * 60, Rate: 0},
+		{Start: 9 * 60, End: 18 * 60, Rate: 512 << 10},
+	}}
+	if s.Default != want.Default || len(s.Rules) != len(want.Rules) || s.Rules[0] != want.Rules[0] || s.Rules[1] != want.Rules[1] {
+		t.Errorf("buildSchedule(download) = %+v, want %+v", s, want)
+	}
+
+	// 未设置上传速率的规则跳过
+	s, err = buildSchedule("", rules, upload)
+	if err != nil {
+		t.Fatalf("buildSchedule() error = %v", err)
+	}
+	if s.Default != 0 || len(s.Rules) != 1 || s.Rules[0] != (Rule{Start: 22 * 60, End: 6 * 60, Rate: 1 << 20}) {
+		t.Errorf("buildSchedule(upload) = %+v", s)
+	}
+
+	for _, bad := range []config.LimitRule{
+		{Start: "25:00", End: "06:00", Download: "1M"},
+		{Start: "22:00", End: "6", Download: "1M"},
+		{Start: "22:00", End: "06:00", Download: "fast"},
+	} {
+		if _, err := buildSchedule("", []config.LimitRule{bad}, download); err == nil {
+			t.Errorf("buildSchedule(%+v) error = nil, want error", bad)
+		}
+	}
+}
+
+func TestLimiter_WaitN(t *testing.T) {
+	const rate = 100_000 // 字节/秒，令牌桶容量同样为 100_000
+	tests := []struct {
+		name  string
+		rate  int64
+		takes []int         // 依次取用的字节数
+		want  time.Duration // 全部取用完成的总耗时
+	}{
+		{"不限速", 0, []int{10 * rate}, 0},
+		{"桶内令牌足够", rate, []int{rate / 2, rate / 2}, 0},
+		{"单次透支后等待偿还", rate, []int{rate + rate/5}, 200 * time.Millisecond},
+		{"透支后下一次继续等待", rate, []int{rate + rate/5, rate / 10}, 300 * time.Millisecond},
+		{"令牌用完后按速率等待", rate, []int{rate, rate / 4, rate / 4}, 500 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLimiter(Schedule{}).SetRate(tt.rate)
+			start := time.Now()
+			for _, n := range tt.takes {
+				if err := l.WaitN(context.Background(), n); err != nil {
+					t.Fatalf("WaitN(%d) error = %v", n, err)
+				}
+			}
+			elapsed := time.Since(start)
+			if elapsed < tt.want-20*time.Millisecond || elapsed > tt.want+150*time.Millisecond {
+				t.Errorf("耗时 %v, want %v", elapsed, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimiter_WaitNCanceled(t *testing.T) {
+	l := NewLimiter(Schedule{}).SetRate(1000)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	// 透支 10 秒的令牌，上下文结束时立即返回
+	err := l.WaitN(ctx, 11000)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("WaitN() error = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("上下文结束后仍等待了 %v", elapsed)
+	}
+}
+
+func TestLimiter_Rate(t *testing.T) {
+	var nilLimiter *Limiter
+	if err := nilLimiter.WaitN(context.Background(), 1<<30); err != nil {
+		t.Errorf("nil Limiter WaitN() error = %v", err)
+	}
+	l := NewLimiter(Schedule{Default: 100})
+	if got := l.Rate(); got != 100 {
+		t.Errorf("Rate() = %d, want 100", got)
+	}
+	// SetRate 覆盖时间段速率，0 表示不限速
+	l.SetRate(0)
+	if got := l.Rate(); got != 0 {
+		t.Errorf("Rate() after SetRate(0) = %d, want 0", got)
+	}
+	l.SetRate(200)
+	if got := l.Rate(); got != 200 {
+		t.Errorf("Rate() after SetRate(200) = %d, want 200", got)
+	}
+}
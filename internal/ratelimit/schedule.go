@@ -0,0 +1,138 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wxnacy/bdpan-cli/internal/config"
+)
+
+var (
+	download = NewLimiter(Schedule{})
+	upload   = NewLimiter(Schedule{})
+)
+
+// Download 所有下载共享的限速器
+func Download() *Limiter {
+	return download
+}
+
+// Upload 所有上传共享的限速器
+func Upload() *Limiter {
+	return upload
+}
+
+// Init 根据配置 limit 设置上传、下载的默认速率和时间段速率
+func Init(cfg config.Limit) error {
+	downloadSchedule, err := buildSchedule(cfg.Download, cfg.Schedule, func(r config.LimitRule) string { return r.Download })
+	if err != nil {
+		return fmt.Errorf("limit.download: %w", err)
+	}
+	uploadSchedule, err := buildSchedule(cfg.Upload, cfg.Schedule, func(r config.LimitRule) string { return r.Upload })
+	if err != nil {
+		return fmt.Errorf("limit.upload: %w", err)
+	}
+	download.SetSchedule(downloadSchedule)
+	upload.SetSchedule(uploadSchedule)
+	return nil
+}
+
+// buildSchedule 解析默认速率和时间段规则，规则中未设置该方向速率时跳过
+func buildSchedule(defaultRate string, rules []config.LimitRule, rateOf func(config.LimitRule) string) (Schedule, error) {
+	var s Schedule
+	var err error
+	if s.Default, err = ParseRate(defaultRate); err != nil {
+		return s, err
+	}
+	for i, r := range rules {
+		if strings.TrimSpace(rateOf(r)) == "" {
+			continue
+		}
+		rule := Rule{}
+		if rule.Rate, err = ParseRate(rateOf(r)); err != nil {
+			return s, fmt.Errorf("schedule[%d]: %w", i, err)
+		}
+		if rule.Start, err = parseClock(r.Start); err != nil {
+			return s, fmt.Errorf("schedule[%d].start: %w", i, err)
+		}
+		if rule.End, err = parseClock(r.End); err != nil {
+			return s, fmt.Errorf("schedule[%d].end: %w", i, err)
+		}
+		s.Rules = append(s.Rules, rule)
+	}
+	return s, nil
+}
+
+// Schedule 按一天中的时间段生效的速率，没有命中任何规则时使用 Default
+type Schedule struct {
+	Default int64
+	Rules   []Rule
+}
+
+// Rule 时间段规则，Start、End 为当天的分钟数，End 小于 Start 时表示跨天（如 22:00-06:00）
+type Rule struct {
+	Start int
+	End   int
+	Rate  int64
+}
+
+// RateAt 返回 t 时刻生效的速率，多个规则重叠时以先配置的为准
+func (s Schedule) RateAt(t time.Time) int64 {
+	minute := t.Hour()*60 + t.Minute()
+	for _, r := range s.Rules {
+		if r.contains(minute) {
+			return r.Rate
+		}
+	}
+	return s.Default
+}
+
+func (r Rule) contains(minute int) bool {
+	if r.Start <= r.End {
+		return minute >= r.Start && minute < r.End
+	}
+	return minute >= r.Start || minute < r.End
+}
+
+// ParseRate 解析速率，支持 1048576、512K、1.5M、1MB/s 等格式，单位按 1024 换算
+//
+// 空字符串、0、unlimited 表示不限速
+func ParseRate(s string) (int64, error) {
+	v := strings.ToUpper(strings.TrimSpace(s))
+	if v == "" || v == "0" || v == "UNLIMITED" {
+		return 0, nil
+	}
+	v = strings.TrimSuffix(v, "/S")
+	v = strings.TrimSuffix(v, "B")
+	unit := int64(1)
+	switch {
+	case strings.HasSuffix(v, "K"):
+		unit = 1 << 10
+	case strings.HasSuffix(v, "M"):
+		unit = 1 << 20
+	case strings.HasSuffix(v, "G"):
+		unit = 1 << 30
+	}
+	if unit > 1 {
+		v = v[:len(v)-1]
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("无效的速率: %s", s)
+	}
+	return int64(n * float64(unit)), nil
+}
+
+// parseClock 解析 HH:MM 为当天的分钟数，24:00 表示一天结束
+func parseClock(s string) (int, error) {
+	var h, m int
+	if _, err := fmt.Sscanf(strings.TrimSpace(s), "%d:%d", &h, &m); err != nil {
+		return 0, fmt.Errorf("无效的时间: %s，格式为 HH:MM", s)
+	}
+	if h < 0 || m < 0 || m > 59 || h*60+m > 24*60 {
+		return 0, fmt.Errorf("无效的时间: %s，格式为 HH:MM", s)
+	}
+	return h*60 + m, nil
+}
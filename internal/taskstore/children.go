@@ -0,0 +1,141 @@
+package taskstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/wxnacy/bdpan-cli/internal/model"
+	"gorm.io/gorm"
+)
+
+// 子项（model.TaskChild）用于记录目录类任务中每个文件的状态。
+//
+// - EnsureChildren 在任务开始时同步子项列表：新增的文件置为等待中，已存在的保留原状态
+// - 重新执行同一 identity 的任务时，调用方只需处理未完成的子项
+// - 子项状态复用任务状态常量，另增加 StatusPending 表示尚未开始
+
+// StatusPending 子项尚未开始下载
+const StatusPending = "等待中"
+
+// EnsureChildren 按 Path 同步任务的子项列表并返回最新的子项，顺序与 children 一致。
+//
+// - 不存在的子项以等待中状态创建
+// - 已存在的子项更新名称和大小，保留状态与已下载字节；大小变化说明文件已被修改，重置为等待中
+// - 不在 children 中的旧子项会被删除（远程文件已删除）
+func EnsureChildren(ctx context.Context, taskID string, children []model.TaskChild) ([]model.TaskChild, error) {
+	result := make([]model.TaskChild, 0, len(children))
+	err := model.GetDB().Transaction(func(tx *gorm.DB) error {
+		var existing []model.TaskChild
+		if err := tx.Where("task_id = ?", taskID).Find(&existing).Error; err != nil {
+			return err
+		}
+		existMap := make(map[string]model.TaskChild, len(existing))
+		for _, c := range existing {
+			existMap[c.Path] = c
+		}
+
+		now := time.Now().Format(time.RFC3339)
+		keep := make(map[string]struct{}, len(children))
+		for _, c := range children {
+			keep[c.Path] = struct{}{}
+			if old, ok := existMap[c.Path]; ok {
+				updates := map[string]any{"name": c.Name, "size": c.Size}
+				old.Name = c.Name
+				if old.Size != c.Size {
+					updates["status"] = StatusPending
+					updates["downloaded"] = 0
+					updates["error"] = ""
+					updates["update_time"] = now
+					old.Size = c.Size
+					old.Status = StatusPending
+					old.Downloaded = 0
+					old.Error = ""
+				}
+				if err := tx.Model(&model.TaskChild{}).Where("id = ?", old.ID).Updates(updates).Error; err != nil {
+					return err
+				}
+				result = append(result, old)
+				continue
+			}
+			nc := model.TaskChild{
+				TaskID:     taskID,
+				Name:       c.Name,
+				Path:       c.Path,
+				Size:       c.Size,
+				Status:     StatusPending,
+				UpdateTime: now,
+			}
+			if err := tx.Create(&nc).Error; err != nil {
+				return err
+			}
+			result = append(result, nc)
+		}
+
+		for _, c := range existing {
+			if _, ok := keep[c.Path]; !ok {
+				if err := tx.Delete(&model.TaskChild{}, c.ID).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ListChildren 列出任务的子项，statuses 为空时返回全部。
+func ListChildren(ctx context.Context, taskID string, statuses ...string) ([]model.TaskChild, error) {
+	var list []model.TaskChild
+	q := model.GetDB().Where("task_id = ?", taskID)
+	if len(statuses) > 0 {
+		q = q.Where("status IN ?", statuses)
+	}
+	if err := q.Order("path").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// CountChildren 按状态统计任务的子项数量。
+func CountChildren(ctx context.Context, taskID string) (map[string]int64, error) {
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	err := model.GetDB().Model(&model.TaskChild{}).
+		Select("status, count(*) as count").
+		Where("task_id = ?", taskID).
+		Group("status").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int64, len(rows))
+	for _, r := range rows {
+		counts[r.Status] = r.Count
+	}
+	return counts, nil
+}
+
+// UpdateChild 更新子项状态、已下载字节和错误信息。
+func UpdateChild(ctx context.Context, childID int64, status string, downloaded int64, errMsg string) error {
+	return model.GetDB().Model(&model.TaskChild{}).Where("id = ?", childID).
+		Updates(map[string]any{
+			"status":      status,
+			"downloaded":  downloaded,
+			"error":       errMsg,
+			"update_time": time.Now().Format(time.RFC3339),
+		}).Error
+}
+
+// UpdateChildProgress 只更新子项的已下载字节，用于心跳时刷新进行中的文件。
+func UpdateChildProgress(ctx context.Context, childID int64, downloaded int64) error {
+	return model.GetDB().Model(&model.TaskChild{}).Where("id = ?", childID).
+		Updates(map[string]any{
+			"downloaded":  downloaded,
+			"update_time": time.Now().Format(time.RFC3339),
+		}).Error
+}
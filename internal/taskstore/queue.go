@@ -0,0 +1,129 @@
+package taskstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/wxnacy/bdpan-cli/internal/model"
+	"gorm.io/gorm"
+)
+
+// 任务队列：前台命令只负责入队，由 bdpan daemon 按入队顺序领取执行。
+//
+// - Enqueue 以 identity 幂等入队，已在排队或运行中的任务不会重复入队
+// - ClaimQueued 原子地将最早入队的任务置为运行中，多个 daemon 同时领取也不会重复执行
+// - 入队顺序以 start_time 记录，重新入队时更新为当前时间，排在已在队列中的任务之后
+// - Requeue 将 daemon 退出时中断的任务放回队列，保留原来的 start_time，下次启动优先继续执行
+// - Requeue 以调用方读取到的状态、pid 和 hostname 作为更新条件，任务已被其他进程恢复时不会被放回队列
+
+// ErrTaskChanged 任务在调用方检查之后被其他进程修改，如已被恢复执行
+var ErrTaskChanged = errors.New("任务状态已变化，请刷新后重试")
+
+// Enqueue 以 identity 将任务加入队列，返回任务 ID。
+// existed==true 表示相同 identity 的任务已在排队或正在运行，未重复入队。
+func Enqueue(ctx context.Context, typ, identity string, totalBytes int64, data any) (taskID string, existed bool, err error) {
+	var dataStr string
+	if data != nil {
+		b, _ := json.Marshal(data)
+		dataStr = string(b)
+	}
+
+	err = model.GetDB().Transaction(func(tx *gorm.DB) error {
+		var task model.Task
+		errq := tx.Where("identity = ?", identity).Take(&task).Error
+		if errq == nil {
+			taskID = task.ID
+			if task.Status == StatusQueued || (task.Status == StatusRunning && isAlive(task)) {
+				existed = true
+				return nil
+			}
+			// 已结束或陈旧的任务重新入队，清空上一次的执行信息，按本次入队时间排队
+			now := time.Now().Format(time.RFC3339)
+			updates := map[string]any{
+				"status":           StatusQueued,
+				"pid":              0,
+				"hostname":         "",
+				"total_bytes":      totalBytes,
+				"speed_bps":        0,
+				"eta_seconds":      0,
+				"cancel_requested": 0,
+				"error":            "",
+				"start_time":       now,
+				"update_time":      now,
+			}
+			if dataStr != "" {
+				updates["data"] = dataStr
+			}
+			return tx.Model(&model.Task{}).Where("id = ?", task.ID).Updates(updates).Error
+		} else if !errors.Is(errq, gorm.ErrRecordNotFound) {
+			return errq
+		}
+
+		nt := model.NewTask(identity, typ, identity, totalBytes, 0, "", 0, dataStr)
+		nt.Status = StatusQueued
+		if err := tx.Create(nt).Error; err != nil {
+			return err
+		}
+		taskID = nt.ID
+		return nil
+	})
+	return taskID, existed, err
+}
+
+// ClaimQueued 领取最早入队的任务并置为运行中，队列为空时返回 nil。
+func ClaimQueued(ctx context.Context) (*model.Task, error) {
+	db := model.GetDB()
+	host, _ := os.Hostname()
+	for {
+		var task model.Task
+		err := db.Where("status = ?", StatusQueued).Order("start_time ASC").Take(&task).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+		// 以 status 作为条件更新，被其他 daemon 抢先领取时影响行数为 0，继续领取下一个
+		r := db.Model(&model.Task{}).
+			Where("id = ? AND status = ?", task.ID, StatusQueued).
+			Updates(map[string]any{
+				"status":      StatusRunning,
+				"pid":         os.Getpid(),
+				"hostname":    host,
+				"update_time": time.Now().Format(time.RFC3339),
+			})
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		if r.RowsAffected == 1 {
+			return Get(ctx, task.ID)
+		}
+	}
+}
+
+// Requeue 将任务放回队列，用于 daemon 退出时中断的任务和任务看板中恢复的任务。
+//
+// t 为调用方检查过的任务，只有状态、pid 和 hostname 仍与 t 一致时才更新，否则返回 ErrTaskChanged。
+// 检查之后任务被其他进程恢复（陈旧任务被接管时状态仍为运行中，但 pid 会变化）时不会被放回队列重复执行。
+func Requeue(ctx context.Context, t *model.Task) error {
+	r := model.GetDB().Model(&model.Task{}).
+		Where("id = ? AND status = ? AND pid = ? AND hostname = ?", t.ID, t.Status, t.PID, t.Hostname).
+		Updates(map[string]any{
+			"status":           StatusQueued,
+			"pid":              0,
+			"hostname":         "",
+			"speed_bps":        0,
+			"eta_seconds":      0,
+			"cancel_requested": 0,
+			"update_time":      time.Now().Format(time.RFC3339),
+		})
+	if r.Error != nil {
+		return r.Error
+	}
+	if r.RowsAffected == 0 {
+		return ErrTaskChanged
+	}
+	return nil
+}
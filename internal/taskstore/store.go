@@ -6,10 +6,14 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
+	"slices"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/wxnacy/bdpan-cli/internal/filter"
 	"github.com/wxnacy/bdpan-cli/internal/model"
 	"gorm.io/gorm"
 )
@@ -24,19 +28,75 @@ import (
 //     * 如存在“运行中且仍存活”的任务 => attached=true，直接复用
 //     * 如任务陈旧或不存在 => 接管为运行中或新建
 // - Heartbeat 更新进度、时间等字段，并返回 CancelRequested，用于协作式取消。
-// - Cancel 将 CancelRequested 置为 1，提示执行端尽快退出。
+// - Cancel 将 CancelRequested 置为 1，提示执行端尽快退出；Pause 置为 2，执行端退出后任务为已暂停。
+// - Enqueue/ClaimQueued 实现任务队列，由 bdpan daemon 领取执行（见 queue.go）。
 //
 // 注意：本模块依赖 WAL 模式和小连接池配置（见 model.InitSqlite）。
 
 const (
 	TaskTypeDownload = "下载"
-	statusRunning    = "运行中"
-	statusCompleted  = "已完成"
-	statusFailed     = "失败"
-	statusCanceled   = "已取消"
-	statusStale      = "陈旧"
+	TaskTypeUpload   = "上传"
+	StatusQueued     = "排队中"
+	StatusRunning    = "运行中"
+	StatusCompleted  = "已完成"
+	StatusFailed     = "失败"
+	StatusCanceled   = "已取消"
+	StatusPaused     = "已暂停"
+	StatusStale      = "陈旧"
 )
 
+// CancelRequested 的取值，执行端对两者的处理相同，区别只在退出后的状态
+const (
+	cancelRequestCancel = 1
+	cancelRequestPause  = 2
+)
+
+// finishedStatuses 已结束的任务状态，可以被 Purge 清理。
+var finishedStatuses = []string{StatusCompleted, StatusFailed, StatusCanceled}
+
+// statusAliases 命令行中可使用的英文状态名。
+var statusAliases = map[string]string{
+	"queued":    StatusQueued,
+	"running":   StatusRunning,
+	"completed": StatusCompleted,
+	"failed":    StatusFailed,
+	"canceled":  StatusCanceled,
+	"paused":    StatusPaused,
+	"stale":     StatusStale,
+}
+
+// ParseStatus 将命令行输入的状态（英文别名或中文原值）转换为库中保存的状态值。
+func ParseStatus(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if v, ok := statusAliases[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	for _, v := range statusAliases {
+		if v == s {
+			return v, nil
+		}
+	}
+	return "", fmt.Errorf("未知的任务状态: %s", s)
+}
+
+// IsStale 判断任务是否标记为运行中但已失去心跳且进程不存在。
+func IsStale(t model.Task) bool {
+	return t.Status == StatusRunning && !isAlive(t)
+}
+
+// DisplayStatus 返回用于展示的状态，运行中但已陈旧的任务展示为陈旧。
+func DisplayStatus(t model.Task) string {
+	if IsStale(t) {
+		return StatusStale
+	}
+	return t.Status
+}
+
+// IsFinished 判断任务是否已结束。
+func IsFinished(t model.Task) bool {
+	return slices.Contains(finishedStatuses, t.Status)
+}
+
 type HeartbeatData struct {
 	DownloadedBytes int64
 	TotalBytes      int64
@@ -52,6 +112,33 @@ type DownloadData struct {
 	OutputDir  string `json:"output_dir,omitempty"`
 	TargetPath string `json:"target_path,omitempty"`
 	IsDir      bool   `json:"is_dir"`
+	// 文件夹任务是否递归下载子文件夹
+	IsRecursion bool `json:"is_recursion,omitempty"`
+	// 文件夹任务的过滤参数
+	Filter *filter.Options `json:"filter,omitempty"`
+	// 本地已存在同名文件时的处理策略
+	OnConflict string `json:"on_conflict,omitempty"`
+	// 下载完成后本地文件修改时间的设置方式
+	PreserveTimes string `json:"preserve_times,omitempty"`
+	// 列表下载（bdpan download -i）的所有条目，此时 Path 为列表文件
+	Items []DownloadItem `json:"items,omitempty"`
+}
+
+// DownloadItem 列表下载中的一个条目
+type DownloadItem struct {
+	Path       string `json:"path"`        // 网盘文件路径
+	TargetPath string `json:"target_path"` // 本地保存路径
+}
+
+// UploadData 上传任务数据
+//
+// 文件夹任务的每个文件通过 model.TaskChild 跟踪（Path 为本地路径），
+// UploadID 只记录单文件任务当前的 uploadid，已上传的分片见 model.UploadSession
+type UploadData struct {
+	LocalPath  string `json:"local_path"`
+	RemotePath string `json:"remote_path"`
+	IsDir      bool   `json:"is_dir"`
+	UploadID   string `json:"upload_id,omitempty"`
 }
 
 // BuildIdentitySHA1 根据输入片段生成幂等用的 identity。
@@ -66,6 +153,7 @@ func BuildIdentitySHA1(parts ...string) string {
 
 // ClaimOrCreate 尝试以 identity 附着到已有运行任务；若不存在或已陈旧则创建/接管。
 // attached==true 表示已存在活跃任务，调用方不应重复启动，而是提示用户查看状态。
+// 当前进程已领取的任务（如 daemon 通过 ClaimQueued 领取）直接接管，不视为附着。
 func ClaimOrCreate(ctx context.Context, typ, identity, id string, totalBytes int64, data any) (taskID string, attached bool, err error) {
 	db := model.GetDB()
 	returnID := id
@@ -84,19 +172,29 @@ func ClaimOrCreate(ctx context.Context, typ, identity, id string, totalBytes int
 		if errq == nil {
 			// found
 			alive := isAlive(task)
-			if task.Status == statusRunning && alive {
+			host, _ := os.Hostname()
+			owned := task.PID == os.Getpid() && task.Hostname == host
+			if task.Status == StatusRunning && alive && !owned {
 				taskID = task.ID
 				attached = true
 				return nil
 			}
 			// stale takeover
-			host, _ := os.Hostname()
+			// 接管时清空上一次的取消标记和错误，否则恢复的任务会在首次心跳时被再次取消
+			// 当前进程已领取的任务保留取消标记，领取后到开始下载之间的取消请求仍然有效
 			now := time.Now().Format(time.RFC3339)
 			updates := map[string]any{
-				"status":      statusRunning,
+				"status":      StatusRunning,
 				"pid":         os.Getpid(),
 				"hostname":    host,
 				"update_time": now,
+				"error":       "",
+			}
+			if !owned {
+				updates["cancel_requested"] = 0
+			}
+			if dataStr != "" {
+				updates["data"] = dataStr
 			}
 			if err := tx.Model(&model.Task{}).Where("id = ?", task.ID).Updates(updates).Error; err != nil {
 				return err
@@ -144,7 +242,7 @@ func Heartbeat(ctx context.Context, taskID string, hb HeartbeatData) (cancelRequ
 	if err := db.First(&t, "id = ?", taskID).Error; err != nil {
 		return false, err
 	}
-	return t.CancelRequested == 1, nil
+	return t.CancelRequested != 0, nil
 }
 
 func Get(ctx context.Context, taskID string) (*model.Task, error) {
@@ -157,21 +255,146 @@ func Get(ctx context.Context, taskID string) (*model.Task, error) {
 
 func ListRunning(ctx context.Context) ([]model.Task, error) {
 	var list []model.Task
-	if err := model.GetDB().Where("status = ?", statusRunning).Order("update_time DESC").Find(&list).Error; err != nil {
+	if err := model.GetDB().Where("status = ?", StatusRunning).Order("update_time DESC").Find(&list).Error; err != nil {
 		return nil, err
 	}
 	return list, nil
 }
 
+// List 按状态列出任务，statuses 为空时返回全部任务，按更新时间倒序。
+// 陈旧状态不落库，查询时从运行中的任务里按心跳与进程存活情况筛选。
+func List(ctx context.Context, statuses ...string) ([]model.Task, error) {
+	var list []model.Task
+	q := model.GetDB().Order("update_time DESC")
+	wantRunning := slices.Contains(statuses, StatusRunning)
+	wantStale := slices.Contains(statuses, StatusStale)
+	if len(statuses) > 0 {
+		if wantStale && !wantRunning {
+			statuses = append(statuses, StatusRunning)
+		}
+		q = q.Where("status IN ?", statuses)
+	}
+	if err := q.Find(&list).Error; err != nil {
+		return nil, err
+	}
+	if wantStale && !wantRunning {
+		list = slices.DeleteFunc(list, func(t model.Task) bool {
+			return t.Status == StatusRunning && !IsStale(t)
+		})
+	}
+	return list, nil
+}
+
+// Find 通过完整 ID 或 ID 前缀查找任务，前缀命中多个任务时返回错误。
+func Find(ctx context.Context, idOrPrefix string) (*model.Task, error) {
+	if t, err := Get(ctx, idOrPrefix); err == nil {
+		return t, nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	var list []model.Task
+	if err := model.GetDB().Where("id LIKE ?", idOrPrefix+"%").Limit(2).Find(&list).Error; err != nil {
+		return nil, err
+	}
+	switch len(list) {
+	case 0:
+		return nil, gorm.ErrRecordNotFound
+	case 1:
+		return &list[0], nil
+	default:
+		return nil, fmt.Errorf("任务 ID 前缀 %s 匹配到多个任务，请输入更长的前缀", idOrPrefix)
+	}
+}
+
+// Cancel 请求协作式取消。
+// 运行中且存活的任务只设置 CancelRequested，由执行端自行退出；
+// 排队中、已暂停和已经失去心跳的陈旧任务没有执行端可以响应，直接置为已取消。
 func Cancel(ctx context.Context, taskID string) error {
+	t, err := Get(ctx, taskID)
+	if err != nil {
+		return err
+	}
+	if t.Status == StatusQueued || t.Status == StatusPaused || (t.Status == StatusRunning && !isAlive(*t)) {
+		return model.GetDB().Model(&model.Task{}).Where("id = ?", taskID).
+			Updates(map[string]any{
+				"cancel_requested": cancelRequestCancel,
+				"status":           StatusCanceled,
+				"update_time":      time.Now().Format(time.RFC3339),
+			}).Error
+	}
 	return model.GetDB().Model(&model.Task{}).Where("id = ?", taskID).
-		Update("cancel_requested", 1).Error
+		Update("cancel_requested", cancelRequestCancel).Error
+}
+
+// Pause 请求暂停任务，执行端与取消一样在下一次心跳时退出，退出后状态为已暂停。
+// 排队中和陈旧任务直接置为已暂停；暂停的任务可以通过 Requeue 放回队列或 bdpan task resume 继续。
+func Pause(ctx context.Context, taskID string) error {
+	t, err := Get(ctx, taskID)
+	if err != nil {
+		return err
+	}
+	switch {
+	case t.Status == StatusQueued || IsStale(*t):
+		return model.GetDB().Model(&model.Task{}).Where("id = ?", taskID).
+			Updates(map[string]any{
+				"status":      StatusPaused,
+				"update_time": time.Now().Format(time.RFC3339),
+			}).Error
+	case t.Status == StatusRunning:
+		return model.GetDB().Model(&model.Task{}).Where("id = ?", taskID).
+			Update("cancel_requested", cancelRequestPause).Error
+	default:
+		return fmt.Errorf("任务%s，无法暂停: %s", t.Status, taskID)
+	}
+}
+
+// IsPauseRequested 判断任务是否已请求暂停。
+func IsPauseRequested(t model.Task) bool {
+	return t.CancelRequested == cancelRequestPause
+}
+
+// Purge 删除已结束（已完成、失败、已取消）的任务及其子项，返回删除的任务数。
+// statuses 为空时清理全部已结束任务，否则只清理指定状态中属于已结束的部分。
+func Purge(ctx context.Context, statuses ...string) (int64, error) {
+	targets := make([]string, 0, len(finishedStatuses))
+	for _, s := range finishedStatuses {
+		if len(statuses) == 0 || slices.Contains(statuses, s) {
+			targets = append(targets, s)
+		}
+	}
+	if len(targets) == 0 {
+		return 0, nil
+	}
+	var purged int64
+	err := model.GetDB().Transaction(func(tx *gorm.DB) error {
+		sub := tx.Model(&model.Task{}).Select("id").Where("status IN ?", targets)
+		if err := tx.Where("task_id IN (?)", sub).Delete(&model.TaskChild{}).Error; err != nil {
+			return err
+		}
+		r := tx.Where("status IN ?", targets).Delete(&model.Task{})
+		if r.Error != nil {
+			return r.Error
+		}
+		purged = r.RowsAffected
+		return nil
+	})
+	return purged, err
+}
+
+// UpdateData 覆盖任务数据，用于记录执行过程中产生的信息（如上传的 uploadid 和已完成分片）
+func UpdateData(ctx context.Context, taskID string, data any) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return model.GetDB().Model(&model.Task{}).Where("id = ?", taskID).
+		Update("data", string(b)).Error
 }
 
 func Complete(ctx context.Context, taskID string) error {
 	return model.GetDB().Model(&model.Task{}).Where("id = ?", taskID).
 		Updates(map[string]any{
-			"status":      statusCompleted,
+			"status":      StatusCompleted,
 			"update_time": time.Now().Format(time.RFC3339),
 		}).Error
 }
@@ -179,16 +402,17 @@ func Complete(ctx context.Context, taskID string) error {
 func Fail(ctx context.Context, taskID string, errMsg string) error {
 	return model.GetDB().Model(&model.Task{}).Where("id = ?", taskID).
 		Updates(map[string]any{
-			"status":      statusFailed,
+			"status":      StatusFailed,
 			"error":       errMsg,
 			"update_time": time.Now().Format(time.RFC3339),
 		}).Error
 }
 
+// SetCanceled 执行端因取消请求退出后调用，请求的是暂停时置为已暂停。
 func SetCanceled(ctx context.Context, taskID string) error {
 	return model.GetDB().Model(&model.Task{}).Where("id = ?", taskID).
 		Updates(map[string]any{
-			"status":      statusCanceled,
+			"status":      gorm.Expr("CASE WHEN cancel_requested = ? THEN ? ELSE ? END", cancelRequestPause, StatusPaused, StatusCanceled),
 			"update_time": time.Now().Format(time.RFC3339),
 		}).Error
 }
@@ -211,3 +435,11 @@ func isAlive(t model.Task) bool {
     }
     return false
 }
+
+// DecodeData 将 Task.Data 中的 JSON 反序列化到 v（如 *DownloadData）。
+func DecodeData(t *model.Task, v any) error {
+	if t.Data == "" {
+		return fmt.Errorf("任务 %s 没有记录任务数据", t.ID)
+	}
+	return json.Unmarshal([]byte(t.Data), v)
+}
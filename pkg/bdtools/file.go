@@ -8,7 +8,9 @@ import (
 	"path/filepath"
 
 	"github.com/charmbracelet/lipgloss"
+	"github.com/wxnacy/bdpan-cli/internal/cache"
 	"github.com/wxnacy/bdpan-cli/internal/config"
+	"github.com/wxnacy/bdpan-cli/internal/httpclient"
 	"github.com/wxnacy/bdpan-cli/internal/logger"
 	"github.com/wxnacy/bdpan-cli/pkg/whitetea"
 	"github.com/wxnacy/go-bdpan"
@@ -61,6 +63,7 @@ func GetFileContentMD5(file *bdpan.FileInfo) (string, error) {
 	}
 	// 创建一个HTTP客户端，允许重定向
 	client := &http.Client{
+		Transport: httpclient.Transport(),
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			// 允许重定向，但限制重定向次数
 			if len(via) >= 10 {
@@ -334,6 +337,7 @@ func DownloadFileToLocal(accessToken string, f *bdpan.FileInfo) (string, error)
 		return "", err
 	}
 	if HasLocalFile(f) {
+		touchCache(p)
 		return p, nil
 	}
 	dir := filepath.Dir(p)
@@ -348,8 +352,17 @@ func DownloadFileToLocal(accessToken string, f *bdpan.FileInfo) (string, error)
 	if err != nil {
 		return "", err
 	}
-	if err != nil {
-		return "", err
+	touchCache(p)
+	// 新文件加入后缓存可能超过 cache.max_size，按最近最少使用清理
+	if err := cache.Enforce(); err != nil {
+		logger.Errorf("清理缓存失败: %v", err)
 	}
 	return p, nil
 }
+
+// touchCache 记录预览文件被访问，用于按最近最少使用清理缓存
+func touchCache(p string) {
+	if err := cache.Touch(p, cache.KindPreview); err != nil {
+		logger.Errorf("记录缓存失败 %s: %v", p, err)
+	}
+}
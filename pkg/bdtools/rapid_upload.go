@@ -0,0 +1,57 @@
+package bdtools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/wxnacy/go-bdpan"
+)
+
+const (
+	// RapidUploadSliceSize 秒传校验的文件头大小，小于该大小的文件不尝试秒传
+	RapidUploadSliceSize = 256 * 1024
+
+	rapidUploadURL = "https://pan.baidu.com/rest/2.0/xpan/file?method=rapidupload"
+)
+
+// errRapidRejected 服务端没有相同内容的文件或拒绝秒传，需要分片上传
+var errRapidRejected = errors.New("秒传未生效")
+
+// rapidUploadRes 秒传接口的返回
+type rapidUploadRes struct {
+	Errno int         `json:"errno"`
+	Info  createdFile `json:"info"`
+}
+
+// rapidUpload 通过文件特征秒传，服务端已有相同内容的文件时不需要上传文件内容
+//
+// 返回：
+// - *bdpan.CreateFileRes: 创建的文件，与分片上传的返回一致
+// - error: 服务端拒绝秒传时包装 errRapidRejected，调用方遇到错误时改为分片上传
+//
+// 实现逻辑：
+//
+// 1. 以表单提交 path、content-length、content-md5、slice-md5 和 content-crc32（十进制）
+// 2. rtype 覆盖时为 3，否则为 0，远程路径已存在时拒绝秒传，由分片上传按原有逻辑处理
+// 3. errno 不为 0 时视为拒绝秒传
+func rapidUpload(ctx context.Context, accessToken, remotePath string, size int64, h *FileHashes, isRewrite bool) (*bdpan.CreateFileRes, error) {
+	form := url.Values{}
+	form.Set("path", remotePath)
+	form.Set("content-length", strconv.FormatInt(size, 10))
+	form.Set("content-md5", h.ContentMD5)
+	form.Set("slice-md5", h.SliceMD5)
+	form.Set("content-crc32", strconv.FormatUint(uint64(h.CRC32), 10))
+	form.Set("rtype", uploadRtype(isRewrite))
+
+	var res rapidUploadRes
+	if err := postFileForm(ctx, rapidUploadURL, accessToken, form, &res); err != nil {
+		return nil, fmt.Errorf("秒传请求失败: %w", err)
+	}
+	if res.Errno != 0 {
+		return nil, fmt.Errorf("%w: errno %d", errRapidRejected, res.Errno)
+	}
+	return res.Info.toCreateFileRes(remotePath, h.ContentMD5, size), nil
+}
@@ -1,246 +1,239 @@
 package bdtools
 
 import (
-	"bufio"
-	"crypto/md5"
-	"encoding/hex"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"slices"
 
 	"github.com/wxnacy/go-bdpan"
 	"github.com/wxnacy/go-tools"
 )
 
 const (
-	// 分片大小，4MB，普通用户的最大分片大小
+	// 分片大小，4MB，普通用户的最大分片大小，会员的分片大小见 PartSize
 	ChunkSize = 4 * 1024 * 1024
+	// DefaultConcurrency 默认同时上传的分片数
+	DefaultConcurrency = 4
+	// DefaultPartRetries 默认单个分片的最大重试次数
+	DefaultPartRetries = 3
 )
 
 type (
 	Printf    func(format string, v ...any)
 	IsRewrite bool
+	// OnUploadID 预上传成功后回调本次上传的 uploadid 和本地文件的分块 md5 列表，续传时不回调
+	OnUploadID func(uploadID string, blockList []string)
+	// OnPartUploaded 分片上传成功后回调分片序号、分片 md5 和分片字节数，并发上传时依次调用，
+	// 续传时之前已上传的分片在开始时依次回调
+	OnPartUploaded func(partseq int, md5 string, size int64)
+	// Concurrency 同时上传的分片数，小于 1 时使用 DefaultConcurrency
+	Concurrency int
+	// PartRetries 单个分片失败后的最大重试次数，小于 0 时使用 DefaultPartRetries
+	PartRetries int
+	// Limiter 上传限速，分片请求体每次读取后按读取的字节数取用令牌
+	Limiter interface {
+		WaitN(ctx context.Context, n int) error
+	}
+	// VipType 用户的会员类型，决定分片大小和单文件上限，见 model.User.VipType
+	VipType int32
+	// ResumeFrom 上次中断的上传，BlockList 与本地文件一致时跳过预上传，只上传 Parts 中缺失的分片
+	ResumeFrom struct {
+		UploadID  string
+		BlockList []string
+		Parts     map[int]string // 已上传的分片，分片序号 => 分片 md5
+	}
 )
 
-// uploadFile 实现文件上传的完整流程
-func UploadFile(accessToken, localFilePath, remoteFilePath string, args ...any) (*bdpan.CreateFileRes, error) {
-	uPrintf := log.Printf
-	var progressBar tools.ProgressBar
-	var isRewrite IsRewrite
+// 会员类型
+const (
+	VipTypeUnknown VipType = -1 // 未知，使用普通用户的分片大小，不检查单文件上限
+	VipTypeNormal  VipType = 0  // 普通用户
+	VipTypeVIP     VipType = 1  // 普通会员
+	VipTypeSVIP    VipType = 2  // 超级会员
+)
 
-	for _, arg := range args {
-		switch val := arg.(type) {
-		case tools.ProgressBar:
-			progressBar = val
-		case Printf:
-			uPrintf = val
-		case IsRewrite:
-			isRewrite = val
-		}
-	}
+// ErrFileTooLarge 文件超过当前用户的单文件上传上限
+var ErrFileTooLarge = errors.New("文件超过单文件上传上限")
 
-	// 1. 打开本地文件
+// ErrUploadIDInvalid 续传时服务端拒绝了分片或创建文件请求，uploadid 已过期或失效，需要重新上传
+var ErrUploadIDInvalid = errors.New("uploadid 已失效")
+
+// UploadFile 上传本地文件，打开文件后由 UploadReader 上传，args 与 UploadReader 相同
+func UploadFile(accessToken, localFilePath, remoteFilePath string, args ...any) (*bdpan.CreateFileRes, error) {
 	file, err := os.Open(localFilePath)
 	if err != nil {
 		return nil, fmt.Errorf("打开文件失败: %w", err)
 	}
 	defer file.Close()
 
-	// 2. 获取文件信息
 	fileInfo, err := file.Stat()
 	if err != nil {
 		return nil, fmt.Errorf("获取文件信息失败: %w", err)
 	}
+	return UploadReader(accessToken, file, fileInfo.Size(), remoteFilePath, args...)
+}
 
-	// 3. 计算文件大小
-	fileSize := fileInfo.Size()
-
-	// 4. 计算文件的MD5分块列表
-	blockList, err := calculateBlockList(file, fileSize)
-	if err != nil {
-		return nil, fmt.Errorf("计算文件MD5失败: %w", err)
-	}
+// UploadReader 实现上传的完整流程，src 为上传的内容，size 为内容的字节数
+//
+// 计算分块 md5 和上传分片都通过 ReadAt 按偏移读取 src，不修改读取位置，也不写临时文件，
+// 因此 src 可以是本地文件，也可以是内存中的数据等任意支持 ReadAt 的来源。
+// 预上传前需要知道大小和全部分块 md5，标准输入这类只能顺序读取一次的流无法直接上传，需要调用方先缓存为 ReaderAt
+//
+// args 支持的参数类型：
+// - tools.ProgressBar: 分片进度条
+// - Printf: 日志输出
+// - IsRewrite: 是否覆盖远程文件
+// - context.Context: 每个分片上传前检查，取消后返回 ctx.Err()
+// - OnUploadID/OnPartUploaded: 上传过程回调，用于记录任务进度
+// - Limiter: 上传限速，分片内容边读边限速
+// - Concurrency: 同时上传的分片数，默认 DefaultConcurrency
+// - PartRetries: 单个分片的最大重试次数，默认 DefaultPartRetries
+// - VipType: 用户的会员类型，按 PartSize 确定分片大小，超过 MaxFileSize 时返回 ErrFileTooLarge，默认 VipTypeUnknown
+// - *FileHashes: 调用方已通过 HashReader 计算的文件特征，大小和分片大小一致时不再读取 src 计算
+// - ResumeFrom: 续传上次中断的上传，只对多于一个分片的文件生效，uploadid 失效时返回 ErrUploadIDInvalid
+//
+// 不小于 RapidUploadSliceSize 的文件先尝试秒传，成功时不上传文件内容，也不回调 OnUploadID 和 OnPartUploaded
+func UploadReader(accessToken string, src io.ReaderAt, fileSize int64, remoteFilePath string, args ...any) (*bdpan.CreateFileRes, error) {
+	uPrintf := log.Printf
+	var progressBar tools.ProgressBar
+	var isRewrite IsRewrite
+	var onUploadID OnUploadID
+	var onPartUploaded OnPartUploaded
+	var limiter Limiter
+	var resume *ResumeFrom
+	var hashes *FileHashes
+	vipType := VipTypeUnknown
+	concurrency := Concurrency(DefaultConcurrency)
+	retries := PartRetries(DefaultPartRetries)
+	ctx := context.Background()
 
-	// 5. 预上传
-	preCreateReq := bdpan.NewPreCreateFileReq(remoteFilePath, int32(fileSize), blockList)
-	if isRewrite {
-		preCreateReq.SetRtype(3)
-	}
-	uPrintf("预上传参数 %#v", preCreateReq)
-	preCreateRes, err := bdpan.PreCreateFile(accessToken, preCreateReq)
-	if err != nil {
-		return nil, fmt.Errorf("预上传失败: %w", err)
+	for _, arg := range args {
+		switch val := arg.(type) {
+		case tools.ProgressBar:
+			progressBar = val
+		case Printf:
+			uPrintf = val
+		case IsRewrite:
+			isRewrite = val
+		case context.Context:
+			ctx = val
+		case OnUploadID:
+			onUploadID = val
+		case OnPartUploaded:
+			onPartUploaded = val
+		case Limiter:
+			limiter = val
+		case Concurrency:
+			if val > 0 {
+				concurrency = val
+			}
+		case PartRetries:
+			if val >= 0 {
+				retries = val
+			}
+		case VipType:
+			vipType = val
+		case *FileHashes:
+			hashes = val
+		case ResumeFrom:
+			resume = &val
+		}
 	}
 
-	if preCreateRes.IsError() {
-		return nil, fmt.Errorf("预上传失败，%s", preCreateRes.Error())
+	// 1. 超过单文件上限时直接返回
+	if err := CheckFileSize(vipType, fileSize); err != nil {
+		return nil, err
 	}
+	partSize := PartSize(vipType)
 
-	uPrintf("预上传成功，uploadid: %s", preCreateRes.Uploadid)
-
-	// 6. 分片上传
-	// 如果文件小于等于4MB，只需要上传一个分片
-	// 如果文件大于4MB，需要按照4MB大小分片上传
-	var remoteBlockList []string
-
-	if fileSize <= ChunkSize {
-		// 小文件上传
-		if _, err := file.Seek(0, 0); err != nil {
-			return nil, fmt.Errorf("文件指针重置失败: %w", err)
+	// 2. 读取一次 src 计算秒传和分片上传需要的文件特征，调用方已计算时直接使用
+	var err error
+	if hashes == nil || hashes.Size != fileSize || hashes.PartSize != partSize {
+		if hashes, err = HashReader(src, fileSize, partSize); err != nil {
+			return nil, fmt.Errorf("计算文件特征失败: %w", err)
 		}
-
-		uploadPartReq := bdpan.NewUploadFilePartReq(remoteFilePath, preCreateRes.Uploadid, 0)
-		uploadPartReq.File = file
-
-		uploadPartRes, err := bdpan.UploadFilePart(accessToken, uploadPartReq)
-		if err != nil {
-			return nil, fmt.Errorf("分片上传失败: %w", err)
+	}
+	blockList := hashes.BlockList
+
+	// 3. 不小于 256KB 的文件先尝试秒传，服务端没有相同内容的文件时分片上传
+	if fileSize >= RapidUploadSliceSize {
+		res, err := rapidUpload(ctx, accessToken, remoteFilePath, fileSize, hashes, bool(isRewrite))
+		if err == nil {
+			uPrintf("秒传成功，fs_id: %d name: %s", res.FSID, res.ServerFilename)
+			return res, nil
 		}
-
-		if uploadPartRes.IsError() {
-			return nil, fmt.Errorf("分片 %d 上传失败，%s", 0, uploadPartRes.Error())
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
 		}
+		uPrintf("%v，改为分片上传", err)
+	}
 
-		remoteBlockList = append(remoteBlockList, uploadPartRes.Md5)
-		uPrintf("分片 0 上传成功，md5: %s", uploadPartRes.Md5)
+	// 4. 预上传，续传且本地文件未变化时沿用上次的 uploadid
+	var uploadID string
+	resumed := resume != nil && resume.UploadID != "" && fileSize > partSize && slices.Equal(resume.BlockList, blockList)
+	if resumed {
+		uploadID = resume.UploadID
+		uPrintf("续传 uploadid: %s，已上传 %d 个分片", uploadID, len(resume.Parts))
 	} else {
-		// 大文件分片上传
-		chunkCount := int(fileSize / ChunkSize)
-		if fileSize%ChunkSize != 0 {
-			chunkCount++
-		}
-		if progressBar != nil {
-			progressBar.Start(chunkCount)
+		uPrintf("预上传 %s 大小: %d 分片: %d", remoteFilePath, fileSize, len(blockList))
+		uploadID, err = preCreate(ctx, accessToken, remoteFilePath, fileSize, blockList, bool(isRewrite))
+		if err != nil {
+			return nil, fmt.Errorf("预上传失败: %w", err)
 		}
-
-		for i := range chunkCount {
-			if _, err := file.Seek(int64(i)*ChunkSize, 0); err != nil {
-				return nil, fmt.Errorf("文件指针定位失败: %w", err)
-			}
-
-			// 创建临时文件存储分片数据
-			tempFile, err := os.CreateTemp("", "upload_chunk_*")
-			if err != nil {
-				return nil, fmt.Errorf("创建临时文件失败: %w", err)
-			}
-			tempFilePath := tempFile.Name()
-			defer os.Remove(tempFilePath)
-
-			// 读取分片数据
-			writer := bufio.NewWriter(tempFile)
-			r := io.LimitReader(file, ChunkSize)
-			if _, err := io.Copy(writer, r); err != nil {
-				tempFile.Close()
-				return nil, fmt.Errorf("写入分片数据失败: %w", err)
-			}
-			writer.Flush()
-			tempFile.Close()
-
-			// 重新打开临时文件用于上传
-			tempFile, err = os.Open(tempFilePath)
-			if err != nil {
-				return nil, fmt.Errorf("打开临时文件失败: %w", err)
-			}
-			defer tempFile.Close()
-
-			// 上传分片
-			uploadPartReq := bdpan.NewUploadFilePartReq(remoteFilePath, preCreateRes.Uploadid, i)
-			uploadPartReq.File = tempFile
-
-			uploadPartRes, err := bdpan.UploadFilePart(accessToken, uploadPartReq)
-			if err != nil {
-				tempFile.Close()
-				return nil, fmt.Errorf("分片 %d 上传失败: %w", i, err)
-			}
-			tempFile.Close()
-
-			if uploadPartRes.IsError() {
-				if progressBar != nil {
-					progressBar.Finish()
-				}
-				return nil, fmt.Errorf("分片 %d 上传失败，%s", i, uploadPartRes.Error())
-			}
-
-			remoteBlockList = append(remoteBlockList, uploadPartRes.Md5)
-			uPrintf("分片 %d/%d 上传成功，md5: %s path: %s", i+1, chunkCount, uploadPartRes.Md5, tempFilePath)
-			if progressBar != nil {
-				progressBar.Increment()
-			}
+		uPrintf("预上传成功，uploadid: %s", uploadID)
+		if onUploadID != nil {
+			onUploadID(uploadID, blockList)
 		}
 	}
 
-	// 7. 创建文件
-	createFileReq := bdpan.NewCreateFileReq(remoteFilePath, int32(fileSize), 0, preCreateRes.Uploadid, remoteBlockList)
-	if isRewrite {
-		createFileReq.SetRtype(3)
+	// 5. 分片上传
+	// 按照会员类型对应的大小分片，由 partUploader 从 src 按偏移读取分片并发上传，小文件只有一个分片
+	chunkCount := max(int((fileSize+partSize-1)/partSize), 1)
+	pu := &partUploader{
+		accessToken:    accessToken,
+		source:         src,
+		fileSize:       fileSize,
+		chunkSize:      partSize,
+		remotePath:     remoteFilePath,
+		uploadID:       uploadID,
+		resumed:        resumed,
+		limiter:        limiter,
+		retries:        int(retries),
+		uPrintf:        uPrintf,
+		onPartUploaded: onPartUploaded,
 	}
-	createFileRes, err := bdpan.CreateFile(accessToken, createFileReq)
-	if err != nil {
-		return nil, fmt.Errorf("创建文件失败: %w", err)
-	}
-
-	if createFileRes.IsError() {
-		if progressBar != nil {
-			progressBar.Finish()
-		}
-		return nil, fmt.Errorf("创建文件失败，%s", createFileRes.Error())
+	if progressBar != nil && chunkCount > 1 {
+		progressBar.Start(chunkCount)
+		pu.progressBar = progressBar
 	}
-	if progressBar != nil {
-		progressBar.Finish()
+	var parts map[int]string
+	if resumed {
+		parts = resume.Parts
 	}
-
-	uPrintf("文件创建成功，fs_id: %d name: %s", createFileRes.FSID, createFileRes.ServerFilename)
-	return createFileRes, nil
-}
-
-// calculateBlockList 计算文件的MD5分块列表
-func calculateBlockList(file *os.File, fileSize int64) ([]string, error) {
-	blockList := make([]string, 0)
-
-	// 如果文件小于等于4MB，只需要计算一个MD5
-	if fileSize <= ChunkSize {
-		hasher := md5.New()
-		if _, err := io.Copy(hasher, file); err != nil {
-			return nil, err
-		}
-		md5Str := hex.EncodeToString(hasher.Sum(nil))
-		blockList = append(blockList, md5Str)
-	} else {
-		// 如果文件大于4MB，需要按照4MB大小分片计算MD5
-		chunkCount := int(fileSize / ChunkSize)
-		if fileSize%ChunkSize != 0 {
-			chunkCount++
-		}
-
-		for i := 0; i < chunkCount; i++ {
-			offset, err := file.Seek(int64(i)*ChunkSize, 0)
-			if err != nil {
-				return nil, err
-			}
-			if offset != int64(i)*ChunkSize {
-				return nil, fmt.Errorf("failed to seek to expected position: got %d, want %d", offset, int64(i)*ChunkSize)
-			}
-
-			hasher := md5.New()
-			r := io.LimitReader(file, ChunkSize)
-			if _, err := io.Copy(hasher, r); err != nil {
-				return nil, err
-			}
-
-			md5Str := hex.EncodeToString(hasher.Sum(nil))
-			blockList = append(blockList, md5Str)
+	remoteBlockList, err := pu.run(ctx, chunkCount, int(concurrency), parts)
+	if err != nil {
+		if pu.progressBar != nil {
+			pu.progressBar.Finish()
 		}
+		return nil, err
 	}
 
-	// 重置文件指针
-	offset, err := file.Seek(0, 0)
-	if err != nil {
-		return nil, err
+	// 6. 创建文件
+	createFileRes, err := createFile(ctx, accessToken, remoteFilePath, fileSize, uploadID, remoteBlockList, bool(isRewrite))
+	if pu.progressBar != nil {
+		pu.progressBar.Finish()
 	}
-	if offset != 0 {
-		return nil, fmt.Errorf("failed to reset file pointer: got offset %d", offset)
+	if err != nil {
+		if resumed && errors.Is(err, errCreateRejected) {
+			return nil, fmt.Errorf("%w: 创建文件失败: %w", ErrUploadIDInvalid, err)
+		}
+		return nil, fmt.Errorf("创建文件失败: %w", err)
 	}
 
-	return blockList, nil
+	uPrintf("文件创建成功，fs_id: %d name: %s", createFileRes.FSID, createFileRes.ServerFilename)
+	return createFileRes, nil
 }
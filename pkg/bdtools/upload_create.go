@@ -0,0 +1,159 @@
+package bdtools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wxnacy/bdpan-cli/internal/httpclient"
+	"github.com/wxnacy/go-bdpan"
+)
+
+// 预上传和创建文件直接请求开放平台接口，go-bdpan 的请求以 int32 传递文件大小，超过 2GB 的文件会溢出
+const (
+	preCreateURL  = "https://pan.baidu.com/rest/2.0/xpan/file?method=precreate"
+	createFileURL = "https://pan.baidu.com/rest/2.0/xpan/file?method=create"
+	createTimeout = 30 * time.Second
+)
+
+// errCreateRejected 服务端拒绝了创建文件请求，如 uploadid 失效或分片不完整
+var errCreateRejected = errors.New("服务端拒绝创建文件")
+
+// createdFile 创建文件和秒传接口返回的文件信息
+type createdFile struct {
+	FSID     uint64 `json:"fs_id"`
+	Path     string `json:"path"`
+	Size     uint64 `json:"size"`
+	Category int32  `json:"category"`
+	MD5      string `json:"md5"`
+	CTime    uint64 `json:"ctime"`
+	MTime    uint64 `json:"mtime"`
+}
+
+// toCreateFileRes 转换为 bdpan.CreateFileRes，接口未返回的路径、md5 和大小使用请求中的值
+func (f createdFile) toCreateFileRes(remotePath, md5 string, size int64) *bdpan.CreateFileRes {
+	if f.Path == "" {
+		f.Path = remotePath
+	}
+	if f.MD5 == "" {
+		f.MD5 = md5
+	}
+	if f.Size == 0 {
+		f.Size = uint64(size)
+	}
+	return &bdpan.CreateFileRes{
+		FSID:           f.FSID,
+		Path:           f.Path,
+		Size:           f.Size,
+		Category:       f.Category,
+		ServerFilename: path.Base(f.Path),
+		Md5:            f.MD5,
+		Ctime:          f.CTime,
+		Mtime:          f.MTime,
+	}
+}
+
+// preCreateRes 预上传接口的返回
+type preCreateRes struct {
+	Errno    int    `json:"errno"`
+	UploadID string `json:"uploadid"`
+}
+
+// createFileRes 创建文件接口的返回
+type createFileRes struct {
+	Errno int `json:"errno"`
+	createdFile
+}
+
+// preCreate 预上传，返回本次上传的 uploadid
+//
+// size 以 int64 提交，blockList 为按分片大小计算的 md5 列表，覆盖时 rtype 为 3
+func preCreate(ctx context.Context, accessToken, remotePath string, size int64, blockList []string, isRewrite bool) (string, error) {
+	form, err := uploadForm(remotePath, size, blockList, isRewrite)
+	if err != nil {
+		return "", err
+	}
+	form.Set("autoinit", "1")
+	var res preCreateRes
+	if err := postFileForm(ctx, preCreateURL, accessToken, form, &res); err != nil {
+		return "", err
+	}
+	if res.Errno != 0 {
+		return "", fmt.Errorf("errno %d", res.Errno)
+	}
+	if res.UploadID == "" {
+		return "", errors.New("未返回 uploadid")
+	}
+	return res.UploadID, nil
+}
+
+// createFile 分片全部上传后合并创建文件，服务端返回错误时包装 errCreateRejected
+func createFile(ctx context.Context, accessToken, remotePath string, size int64, uploadID string, blockList []string, isRewrite bool) (*bdpan.CreateFileRes, error) {
+	form, err := uploadForm(remotePath, size, blockList, isRewrite)
+	if err != nil {
+		return nil, err
+	}
+	form.Set("uploadid", uploadID)
+	var res createFileRes
+	if err := postFileForm(ctx, createFileURL, accessToken, form, &res); err != nil {
+		return nil, err
+	}
+	if res.Errno != 0 {
+		return nil, fmt.Errorf("%w: errno %d", errCreateRejected, res.Errno)
+	}
+	return res.toCreateFileRes(remotePath, "", size), nil
+}
+
+// uploadForm 预上传和创建文件共用的表单参数
+func uploadForm(remotePath string, size int64, blockList []string, isRewrite bool) (url.Values, error) {
+	blocks, err := json.Marshal(blockList)
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{}
+	form.Set("path", remotePath)
+	form.Set("size", strconv.FormatInt(size, 10))
+	form.Set("isdir", "0")
+	form.Set("block_list", string(blocks))
+	form.Set("rtype", uploadRtype(isRewrite))
+	return form, nil
+}
+
+// uploadRtype 文件命名策略，覆盖时为 3，否则为 0，远程路径已存在时返回错误
+func uploadRtype(isRewrite bool) string {
+	if isRewrite {
+		return "3"
+	}
+	return "0"
+}
+
+// postFileForm 以表单请求 xpan 文件接口，将 JSON 返回解析到 v，状态码不为 200 时返回错误
+func postFileForm(ctx context.Context, apiURL, accessToken string, form url.Values, v any) error {
+	reqURL := apiURL + "&access_token=" + url.QueryEscape(accessToken)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, reqURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", "pan.baidu.com")
+
+	resp, err := httpclient.NewClient(createTimeout).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("状态码 %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("解析返回失败: %w", err)
+	}
+	return nil
+}
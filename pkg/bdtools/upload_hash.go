@@ -0,0 +1,105 @@
+package bdtools
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+// FileHashes 上传需要的文件特征，由 HashReader 读取一次内容计算
+//
+// 上传时作为参数传给 UploadFile/UploadReader，大小和分片大小一致时不再重复读取文件
+type FileHashes struct {
+	Size       int64
+	PartSize   int64    // 计算 BlockList 使用的分片大小
+	ContentMD5 string   // 整个文件的 md5
+	SliceMD5   string   // 文件前 256KB 的 md5，秒传使用
+	CRC32      uint32   // 整个文件的 crc32，秒传使用
+	BlockList  []string // 按 PartSize 分片的 md5 列表，空文件也有一个分块
+}
+
+// HashFile 读取一次本地文件，计算上传需要的文件特征
+func HashFile(localFilePath string, partSize int64) (*FileHashes, error) {
+	file, err := os.Open(localFilePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return HashReader(file, info.Size(), partSize)
+}
+
+// HashReader 从头到尾顺序读取一次 src，同时计算 md5、前 256KB 的 md5、crc32 和按 partSize 分片的 md5 列表
+//
+// partSize 必须大于 0，否则返回错误
+func HashReader(src io.ReaderAt, size, partSize int64) (*FileHashes, error) {
+	if partSize <= 0 {
+		return nil, fmt.Errorf("无效的分片大小: %d", partSize)
+	}
+	h := &fileHasher{
+		content:  md5.New(),
+		slice:    md5.New(),
+		crc:      crc32.NewIEEE(),
+		block:    md5.New(),
+		partSize: partSize,
+	}
+	n, err := io.Copy(h, io.NewSectionReader(src, 0, size))
+	if err != nil {
+		return nil, err
+	}
+	if n != size {
+		return nil, fmt.Errorf("读取 %d 字节，文件大小 %d", n, size)
+	}
+	if h.blockLen > 0 || len(h.blockList) == 0 {
+		h.blockList = append(h.blockList, hex.EncodeToString(h.block.Sum(nil)))
+	}
+	return &FileHashes{
+		Size:       size,
+		PartSize:   partSize,
+		ContentMD5: hex.EncodeToString(h.content.Sum(nil)),
+		SliceMD5:   hex.EncodeToString(h.slice.Sum(nil)),
+		CRC32:      h.crc.Sum32(),
+		BlockList:  h.blockList,
+	}, nil
+}
+
+// fileHasher 将顺序写入的内容分发给各个 hash，按 partSize 切分分块 md5
+type fileHasher struct {
+	content   hash.Hash
+	slice     hash.Hash
+	crc       hash.Hash32
+	block     hash.Hash
+	partSize  int64
+	written   int64 // 已写入的字节数
+	blockLen  int64 // 当前分块已写入的字节数
+	blockList []string
+}
+
+func (h *fileHasher) Write(p []byte) (int, error) {
+	n := len(p)
+	h.content.Write(p)
+	h.crc.Write(p)
+	if h.written < RapidUploadSliceSize {
+		h.slice.Write(p[:min(int64(len(p)), RapidUploadSliceSize-h.written)])
+	}
+	h.written += int64(n)
+	for len(p) > 0 {
+		k := min(int64(len(p)), h.partSize-h.blockLen)
+		h.block.Write(p[:k])
+		h.blockLen += k
+		p = p[k:]
+		if h.blockLen == h.partSize {
+			h.blockList = append(h.blockList, hex.EncodeToString(h.block.Sum(nil)))
+			h.block.Reset()
+			h.blockLen = 0
+		}
+	}
+	return n, nil
+}
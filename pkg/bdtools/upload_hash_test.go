@@ -0,0 +1,126 @@
+package bdtools
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// testData 生成 size 字节的测试内容
+func testData(size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i*31 + i>>10)
+	}
+	return data
+}
+
+func md5Hex(b []byte) string {
+	sum := md5.Sum(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestHashReader(t *testing.T) {
+	const slice = RapidUploadSliceSize
+	tests := []struct {
+		name       string
+		size       int
+		partSize   int64
+		wantBlocks int
+	}{
+		{"空文件", 0, 64 << 10, 1},
+		{"小于一个分片", 1000, 64 << 10, 1},
+		{"恰好一个分片", 64 << 10, 64 << 10, 1},
+		{"分片大小的整数倍", 3 * 64 << 10, 64 << 10, 3},
+		{"整数倍多一个字节", 3*64<<10 + 1, 64 << 10, 4},
+		{"整数倍少一个字节", 3*64<<10 - 1, 64 << 10, 3},
+		{"分片边界不与读取缓冲对齐", 350_001, 100_000, 4},
+		{"小于 256KB 时 slice-md5 为整个文件", slice - 1, 4 << 20, 1},
+		{"恰好 256KB", slice, 4 << 20, 1},
+		{"大于 256KB 时 slice-md5 只取文件头", slice + 12345, 4 << 20, 1},
+		{"一个字节的分片", 5, 1, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := testData(tt.size)
+			got, err := HashReader(bytes.NewReader(data), int64(len(data)), tt.partSize)
+			if err != nil {
+				t.Fatalf("HashReader() error = %v", err)
+			}
+
+			// 空文件也有一个分块
+			wantBlocks := []string{md5Hex(nil)}
+			if len(data) > 0 {
+				wantBlocks = wantBlocks[:0]
+				for off := 0; off < len(data); off += int(tt.partSize) {
+					wantBlocks = append(wantBlocks, md5Hex(data[off:min(off+int(tt.partSize), len(data))]))
+				}
+			}
+			if len(wantBlocks) != tt.wantBlocks {
+				t.Fatalf("测试数据错误: %d 个分块, want %d", len(wantBlocks), tt.wantBlocks)
+			}
+			if !slices.Equal(got.BlockList, wantBlocks) {
+				t.Errorf("BlockList = %v, want %v", got.BlockList, wantBlocks)
+			}
+			if want := md5Hex(data); got.ContentMD5 != want {
+				t.Errorf("ContentMD5 = %s, want %s", got.ContentMD5, want)
+			}
+			if want := md5Hex(data[:min(len(data), slice)]); got.SliceMD5 != want {
+				t.Errorf("SliceMD5 = %s, want %s", got.SliceMD5, want)
+			}
+			if want := crc32.ChecksumIEEE(data); got.CRC32 != want {
+				t.Errorf("CRC32 = %d, want %d", got.CRC32, want)
+			}
+			if got.Size != int64(len(data)) || got.PartSize != tt.partSize {
+				t.Errorf("Size, PartSize = %d, %d, want %d, %d", got.Size, got.PartSize, len(data), tt.partSize)
+			}
+		})
+	}
+}
+
+func TestHashReader_Errors(t *testing.T) {
+	data := testData(1000)
+	tests := []struct {
+		name     string
+		size     int64
+		partSize int64
+	}{
+		{"分片大小为 0", 1000, 0},
+		{"分片大小为负数", 1000, -1},
+		{"内容少于文件大小", 2000, 4 << 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := HashReader(bytes.NewReader(data), tt.size, tt.partSize); err == nil {
+				t.Errorf("HashReader() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestHashFile(t *testing.T) {
+	data := testData(200_000)
+	path := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := HashFile(path, 64<<10)
+	if err != nil {
+		t.Fatalf("HashFile() error = %v", err)
+	}
+	want, err := HashReader(bytes.NewReader(data), int64(len(data)), 64<<10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ContentMD5 != want.ContentMD5 || !slices.Equal(got.BlockList, want.BlockList) || got.Size != want.Size {
+		t.Errorf("HashFile() = %+v, want %+v", got, want)
+	}
+	if _, err := HashFile(filepath.Join(t.TempDir(), "missing"), 64<<10); err == nil {
+		t.Errorf("HashFile(missing) error = nil, want error")
+	}
+}
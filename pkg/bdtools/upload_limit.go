@@ -0,0 +1,60 @@
+package bdtools
+
+import (
+	"fmt"
+
+	"github.com/wxnacy/go-tools"
+)
+
+// 各会员类型的单文件上传上限
+const (
+	maxFileSizeNormal = 4 << 30  // 普通用户 4GB
+	maxFileSizeVIP    = 10 << 30 // 普通会员 10GB
+	maxFileSizeSVIP   = 20 << 30 // 超级会员 20GB
+)
+
+// Name 会员类型的名称
+func (v VipType) Name() string {
+	switch v {
+	case VipTypeNormal:
+		return "普通用户"
+	case VipTypeVIP:
+		return "普通会员"
+	case VipTypeSVIP:
+		return "超级会员"
+	}
+	return "未知身份"
+}
+
+// PartSize 会员类型对应的分片大小，普通用户和未知类型为 ChunkSize，普通会员 16MB，超级会员 32MB
+func PartSize(v VipType) int64 {
+	switch v {
+	case VipTypeVIP:
+		return 16 * 1024 * 1024
+	case VipTypeSVIP:
+		return 32 * 1024 * 1024
+	}
+	return ChunkSize
+}
+
+// MaxFileSize 会员类型对应的单文件上传上限，未知类型返回 0，表示不限制
+func MaxFileSize(v VipType) int64 {
+	switch v {
+	case VipTypeNormal:
+		return maxFileSizeNormal
+	case VipTypeVIP:
+		return maxFileSizeVIP
+	case VipTypeSVIP:
+		return maxFileSizeSVIP
+	}
+	return 0
+}
+
+// CheckFileSize 检查文件大小是否超过会员类型的单文件上传上限，超过时返回包装 ErrFileTooLarge 的错误
+func CheckFileSize(v VipType, size int64) error {
+	limit := MaxFileSize(v)
+	if limit <= 0 || size <= limit {
+		return nil
+	}
+	return fmt.Errorf("%w: 文件大小 %s，%s单文件上限 %s", ErrFileTooLarge, tools.FormatSize(size), v.Name(), tools.FormatSize(limit))
+}
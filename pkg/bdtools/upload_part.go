@@ -0,0 +1,257 @@
+package bdtools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/wxnacy/bdpan-cli/internal/httpclient"
+	"github.com/wxnacy/go-tools"
+)
+
+const (
+	// 分片重试等待的初始时间，每次失败翻倍，最长 partRetryMaxDelay
+	partRetryBaseDelay = time.Second
+	partRetryMaxDelay  = 30 * time.Second
+
+	// limitReadSize 限速时单次读取的最大字节数，请求体按小块匀速发送
+	limitReadSize = 32 * 1024
+)
+
+// uploadPartURL 分片上传接口，测试时替换为本地服务
+var uploadPartURL = "https://d.pcs.baidu.com/rest/2.0/pcs/superfile2?method=upload"
+
+// errPartRejected 服务端拒绝了分片，如 uploadid 不存在或参数错误
+var errPartRejected = errors.New("服务端拒绝分片")
+
+// uploadPartRes 分片上传接口的返回
+type uploadPartRes struct {
+	MD5       string `json:"md5"`
+	ErrorCode int    `json:"error_code"`
+	ErrorMsg  string `json:"error_msg"`
+}
+
+// partUploader 并发上传大文件的分片
+//
+// 设计说明：
+// - 固定数量的 worker 从队列中领取分片序号，分片 md5 按序号写入 blockList，创建文件时顺序与分片一致
+// - 分片通过 io.SectionReader 从 source 按偏移读取并以 multipart 请求体流式上传，不写临时文件，内存占用与分片大小无关
+// - 设置限速时请求体每次读取后按读取的字节数等待令牌，上传速度平稳，不会按分片突发占满带宽
+// - 单个分片失败时按指数退避重试，超过重试次数后取消其余分片并返回错误
+// - 续传时跳过已上传的分片，服务端拒绝分片说明 uploadid 已失效，不再重试
+// - 上传成功回调和进度条更新加锁依次执行，调用方不需要处理并发
+type partUploader struct {
+	accessToken    string
+	source         io.ReaderAt // 上传的内容，支持 ReadAt 的任意来源
+	fileSize       int64
+	chunkSize      int64 // 分片大小，见 PartSize
+	remotePath     string
+	uploadID       string
+	resumed        bool // 沿用上次的 uploadid 续传
+	limiter        Limiter
+	retries        int
+	uPrintf        Printf
+	progressBar    tools.ProgressBar
+	onPartUploaded OnPartUploaded
+
+	mu sync.Mutex
+}
+
+// run 使用 concurrency 个 worker 上传 count 个分片中 parts 以外的分片，返回按序号排列的分片 md5
+//
+// parts 为续传时已上传的分片，开始时依次回调 onPartUploaded 并更新进度条
+func (u *partUploader) run(ctx context.Context, count, concurrency int, parts map[int]string) ([]string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	blockList := make([]string, count)
+	pending := make([]int, 0, count)
+	for i := range count {
+		if md5, ok := parts[i]; ok && md5 != "" {
+			blockList[i] = md5
+			u.done(i, count, md5)
+			continue
+		}
+		pending = append(pending, i)
+	}
+
+	var (
+		jobs     = make(chan int)
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for range min(concurrency, len(pending)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				md5, err := u.uploadWithRetry(ctx, i)
+				if err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					return
+				}
+				blockList[i] = md5
+				u.done(i, count, md5)
+			}
+		}()
+	}
+
+feed:
+	for _, i := range pending {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return blockList, nil
+}
+
+// uploadWithRetry 上传第 i 个分片，失败时按指数退避重试，上下文取消时直接返回
+func (u *partUploader) uploadWithRetry(ctx context.Context, i int) (string, error) {
+	size := u.partSize(i)
+	for attempt := 0; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		// 请求体边读边限速，重试同样计入限速
+		var r io.Reader = io.NewSectionReader(u.source, int64(i)*u.chunkSize, size)
+		if u.limiter != nil {
+			r = &limitedReader{ctx: ctx, r: r, limiter: u.limiter}
+		}
+		md5, err := uploadPart(ctx, u.accessToken, u.remotePath, u.uploadID, i, r, size)
+		if err == nil {
+			return md5, nil
+		}
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		if u.resumed && errors.Is(err, errPartRejected) {
+			// 续传时服务端拒绝分片，说明 uploadid 已失效
+			return "", fmt.Errorf("%w: %w", ErrUploadIDInvalid, err)
+		}
+		if attempt >= u.retries {
+			return "", err
+		}
+		delay := min(partRetryBaseDelay<<attempt, partRetryMaxDelay)
+		u.uPrintf("分片 %d 上传失败，%v 后第 %d 次重试: %v", i, delay, attempt+1, err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return "", ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// uploadPart 以 multipart 请求体上传一个分片，r 为分片内容，size 为分片字节数，返回分片 md5
+//
+// 请求体由 multipart 头部、r 和结尾拼接而成，Content-Length 预先计算，不缓存分片内容。
+// 服务端返回错误时包装 errPartRejected
+func uploadPart(ctx context.Context, accessToken, remotePath, uploadID string, partseq int, r io.Reader, size int64) (string, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	if _, err := mw.CreateFormFile("file", path.Base(remotePath)); err != nil {
+		return "", err
+	}
+	head := bytes.Clone(buf.Bytes())
+	buf.Reset()
+	if err := mw.Close(); err != nil {
+		return "", err
+	}
+	tail := buf.Bytes()
+
+	query := url.Values{}
+	query.Set("access_token", accessToken)
+	query.Set("type", "tmpfile")
+	query.Set("path", remotePath)
+	query.Set("uploadid", uploadID)
+	query.Set("partseq", strconv.Itoa(partseq))
+	body := io.MultiReader(bytes.NewReader(head), r, bytes.NewReader(tail))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, uploadPartURL+"&"+query.Encode(), body)
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = int64(len(head)) + size + int64(len(tail))
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("User-Agent", "pan.baidu.com")
+
+	resp, err := httpclient.NewClient(0).Do(req)
+	if err != nil {
+		return "", fmt.Errorf("分片 %d 上传失败: %w", partseq, err)
+	}
+	defer resp.Body.Close()
+	var res uploadPartRes
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return "", fmt.Errorf("分片 %d 上传失败，状态码 %d: %w", partseq, resp.StatusCode, err)
+	}
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return "", fmt.Errorf("分片 %d 上传失败，状态码 %d: %s", partseq, resp.StatusCode, res.ErrorMsg)
+	}
+	if res.ErrorCode != 0 || res.MD5 == "" {
+		return "", fmt.Errorf("%w: 分片 %d，error_code %d %s", errPartRejected, partseq, res.ErrorCode, res.ErrorMsg)
+	}
+	return res.MD5, nil
+}
+
+// done 记录分片上传成功，依次执行回调并更新进度条
+func (u *partUploader) done(i, count int, md5 string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.uPrintf("分片 %d/%d 上传成功，md5: %s", i+1, count, md5)
+	if u.onPartUploaded != nil {
+		u.onPartUploaded(i, md5, u.partSize(i))
+	}
+	if u.progressBar != nil {
+		u.progressBar.Increment()
+	}
+}
+
+// partSize 第 i 个分片的字节数，最后一个分片可能小于 chunkSize
+func (u *partUploader) partSize(i int) int64 {
+	return min(u.chunkSize, u.fileSize-int64(i)*u.chunkSize)
+}
+
+// limitedReader 每次读取后按读取的字节数等待限速令牌，ctx 结束时返回 ctx.Err()
+type limitedReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter Limiter
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if len(p) > limitReadSize {
+		p = p[:limitReadSize]
+	}
+	n, err := l.r.Read(p)
+	if n > 0 {
+		if waitErr := l.limiter.WaitN(l.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,267 @@
+package bdtools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// partServer 模拟分片上传接口，校验分片内容并返回分片 md5
+//
+// 序号越小的分片响应越慢，并发上传时分片完成的顺序与序号相反
+type partServer struct {
+	*httptest.Server
+	data      []byte
+	chunkSize int
+	// fault 返回 true 时表示已经写入了模拟的异常响应，attempt 为该分片第几次请求，从 1 开始
+	fault func(w http.ResponseWriter, partseq, attempt int) bool
+
+	mu        sync.Mutex
+	attempts  map[int]int // 每个分片收到的请求数
+	active    int
+	maxActive int // 同时处理的最大请求数
+}
+
+func newPartServer(t *testing.T, data []byte, chunkSize int, fault func(w http.ResponseWriter, partseq, attempt int) bool) *partServer {
+	s := &partServer{data: data, chunkSize: chunkSize, fault: fault, attempts: make(map[int]int)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	old := uploadPartURL
+	uploadPartURL = s.URL + "/superfile2?method=upload"
+	t.Cleanup(func() { uploadPartURL = old })
+	return s
+}
+
+func (s *partServer) serve(w http.ResponseWriter, r *http.Request) {
+	partseq, err := strconv.Atoi(r.URL.Query().Get("partseq"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	s.attempts[partseq]++
+	attempt := s.attempts[partseq]
+	s.active++
+	s.maxActive = max(s.maxActive, s.active)
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.active--
+		s.mu.Unlock()
+	}()
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	content, err := io.ReadAll(file)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	time.Sleep(time.Duration(len(s.data)/s.chunkSize-partseq) * 10 * time.Millisecond)
+	if s.fault != nil && s.fault(w, partseq, attempt) {
+		return
+	}
+	start := partseq * s.chunkSize
+	if start >= len(s.data) || !bytes.Equal(content, s.data[start:min(start+s.chunkSize, len(s.data))]) {
+		json.NewEncoder(w).Encode(uploadPartRes{ErrorCode: 31299, ErrorMsg: "分片内容不一致"})
+		return
+	}
+	json.NewEncoder(w).Encode(uploadPartRes{MD5: md5Hex(content)})
+}
+
+func TestPartUploader_Run(t *testing.T) {
+	const chunkSize = 1000
+	data := testData(5*chunkSize + 500)
+	count := 6
+	var wantBlocks []string
+	for i := range count {
+		wantBlocks = append(wantBlocks, md5Hex(data[i*chunkSize:min((i+1)*chunkSize, len(data))]))
+	}
+	serverError := func(w http.ResponseWriter) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(uploadPartRes{ErrorMsg: "busy"})
+	}
+
+	tests := []struct {
+		name         string
+		fault        func(w http.ResponseWriter, partseq, attempt int) bool
+		parts        map[int]string // 续传时已上传的分片
+		retries      int
+		wantErr      bool
+		wantErrIs    error
+		wantAttempts map[int]int
+	}{
+		{
+			name:         "并发上传按序号返回",
+			retries:      2,
+			wantAttempts: map[int]int{0: 1, 1: 1, 2: 1, 3: 1, 4: 1, 5: 1},
+		},
+		{
+			name: "临时失败后重试",
+			fault: func(w http.ResponseWriter, partseq, attempt int) bool {
+				if partseq == 2 && attempt == 1 {
+					serverError(w)
+					return true
+				}
+				return false
+			},
+			retries:      2,
+			wantAttempts: map[int]int{0: 1, 1: 1, 2: 2, 3: 1, 4: 1, 5: 1},
+		},
+		{
+			name:         "续传跳过已上传的分片",
+			parts:        map[int]string{0: wantBlocks[0], 3: wantBlocks[3], 5: wantBlocks[5]},
+			retries:      2,
+			wantAttempts: map[int]int{1: 1, 2: 1, 4: 1},
+		},
+		{
+			name: "重试次数用完",
+			fault: func(w http.ResponseWriter, partseq, attempt int) bool {
+				if partseq == 1 {
+					serverError(w)
+					return true
+				}
+				return false
+			},
+			retries: 0,
+			wantErr: true,
+		},
+		{
+			name: "续传时服务端拒绝分片",
+			fault: func(w http.ResponseWriter, partseq, attempt int) bool {
+				if partseq == 1 {
+					json.NewEncoder(w).Encode(uploadPartRes{ErrorCode: 31363, ErrorMsg: "uploadid not exist"})
+					return true
+				}
+				return false
+			},
+			parts:     map[int]string{0: wantBlocks[0]},
+			retries:   2,
+			wantErr:   true,
+			wantErrIs: ErrUploadIDInvalid,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newPartServer(t, data, chunkSize, tt.fault)
+			var (
+				mu       sync.Mutex
+				uploaded = make(map[int]string)
+				sizes    int64
+			)
+			u := &partUploader{
+				accessToken: "token",
+				source:      bytes.NewReader(data),
+				fileSize:    int64(len(data)),
+				chunkSize:   chunkSize,
+				remotePath:  "/apps/bdpan/file",
+				uploadID:    "upload-id",
+				resumed:     tt.parts != nil,
+				retries:     tt.retries,
+				uPrintf:     t.Logf,
+				onPartUploaded: func(partseq int, md5 string, size int64) {
+					mu.Lock()
+					defer mu.Unlock()
+					if _, ok := uploaded[partseq]; ok {
+						t.Errorf("分片 %d 重复回调", partseq)
+					}
+					uploaded[partseq] = md5
+					sizes += size
+				},
+			}
+
+			blockList, err := u.run(context.Background(), count, 3, tt.parts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErrIs != nil && !errors.Is(err, tt.wantErrIs) {
+				t.Errorf("run() error = %v, want %v", err, tt.wantErrIs)
+			}
+			if err != nil {
+				return
+			}
+			if !slices.Equal(blockList, wantBlocks) {
+				t.Errorf("blockList = %v, want %v", blockList, wantBlocks)
+			}
+			if len(uploaded) != count || sizes != int64(len(data)) {
+				t.Errorf("回调 %d 个分片共 %d 字节, want %d 个分片共 %d 字节", len(uploaded), sizes, count, len(data))
+			}
+			for i, md5 := range uploaded {
+				if md5 != wantBlocks[i] {
+					t.Errorf("分片 %d 回调 md5 = %s, want %s", i, md5, wantBlocks[i])
+				}
+			}
+
+			srv.mu.Lock()
+			defer srv.mu.Unlock()
+			if !maps.Equal(srv.attempts, tt.wantAttempts) {
+				t.Errorf("分片请求次数 = %v, want %v", srv.attempts, tt.wantAttempts)
+			}
+			if len(tt.wantAttempts) > 1 && srv.maxActive < 2 {
+				t.Errorf("同时上传的分片数 = %d, want >= 2", srv.maxActive)
+			}
+		})
+	}
+}
+
+func TestPartSize(t *testing.T) {
+	tests := []struct {
+		vip  VipType
+		want int64
+	}{
+		{VipTypeUnknown, ChunkSize},
+		{VipTypeNormal, 4 << 20},
+		{VipTypeVIP, 16 << 20},
+		{VipTypeSVIP, 32 << 20},
+		{VipType(9), ChunkSize},
+	}
+	for _, tt := range tests {
+		t.Run(tt.vip.Name(), func(t *testing.T) {
+			if got := PartSize(tt.vip); got != tt.want {
+				t.Errorf("PartSize(%d) = %d, want %d", tt.vip, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckFileSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		vip     VipType
+		size    int64
+		wantErr bool
+	}{
+		{"普通用户上限以内", VipTypeNormal, 4 << 30, false},
+		{"普通用户超过上限", VipTypeNormal, 4<<30 + 1, true},
+		{"普通会员上限以内", VipTypeVIP, 10 << 30, false},
+		{"普通会员超过上限", VipTypeVIP, 10<<30 + 1, true},
+		{"超级会员上限以内", VipTypeSVIP, 20 << 30, false},
+		{"超级会员超过上限", VipTypeSVIP, 20<<30 + 1, true},
+		{"未知身份不限制", VipTypeUnknown, 100 << 30, false},
+		{"空文件", VipTypeNormal, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckFileSize(tt.vip, tt.size)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckFileSize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrFileTooLarge) {
+				t.Errorf("CheckFileSize() error = %v, want ErrFileTooLarge", err)
+			}
+		})
+	}
+}